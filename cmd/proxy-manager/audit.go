@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/audit"
+)
+
+// runAudit implements `proxy-manager audit [--since D] [--node ID] [--json]`:
+// 查询 audit.log (见 internal/audit)。
+func runAudit(args []string) {
+	if flagPresent(args, "-h") || flagPresent(args, "--help") {
+		fmt.Println(auditHelp())
+		return
+	}
+	checkRoot()
+
+	var f audit.Filter
+	if v := flagValue(args, "--since"); v != "" {
+		since, err := parseSince(v, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		f.Since = since
+	}
+	f.Node = flagValue(args, "--node")
+
+	entries, err := audit.Read(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 %s 失败: %v\n", audit.LogPath, err)
+		os.Exit(1)
+	}
+	if flagPresent(args, "--json") {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			_ = enc.Encode(e)
+		}
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("(没有匹配的审计记录)")
+		return
+	}
+	for _, e := range entries {
+		printAuditEntry(e)
+	}
+}
+
+func auditHelp() string {
+	return fmt.Sprintf(`用法: proxy-manager audit [--since D] [--node ID] [--json]
+
+  查询配置变更审计日志 (%s)。只记字段名, 不记值。
+
+  --since D   只看这之后的记录: 7d / 12h / 2026-01-02
+  --node ID   只看某节点 (ID 前缀, 如 vless-reality)
+  --json      原样输出 JSON lines`, audit.LogPath)
+}
+
+func printAuditEntry(e audit.Entry) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s  %-8s %-16s", e.Time.Local().Format("2006-01-02 15:04:05"), e.User, e.Action)
+	if e.Node != "" {
+		sb.WriteString(" " + e.Node)
+	}
+	if len(e.Fields) > 0 {
+		sb.WriteString(" [" + strings.Join(e.Fields, ", ") + "]")
+	}
+	if e.Detail != "" {
+		sb.WriteString(" " + e.Detail)
+	}
+	fmt.Fprintf(&sb, "  (%s)", e.Command)
+	if e.Outcome != audit.OutcomeOK {
+		sb.WriteString(" " + paint(e.Outcome+": "+e.Error, false))
+	}
+	fmt.Println(sb.String())
+}
+
+// parseSince 跟 parseExpiry 同样的格式，只是往回算。
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(v, "d") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(v, "d")); err == nil && n > 0 {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("--since 格式错误: %s (示例: 7d / 12h / 2026-01-02)", v)
+}
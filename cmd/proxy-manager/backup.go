@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/backup"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// runBackup implements `proxy-manager backup [--out FILE]`.
+//
+// 打包 nodes.json + 内核 config / 证书 + autocert 缓存 +
+// cloudflare.env，换 VPS 时配合 `proxy-manager restore` 用。
+func runBackup(args []string) {
+	checkRoot()
+	out := flagValue(args, "--out")
+	if out == "" {
+		out = fmt.Sprintf("pm-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	m, err := backup.Create(out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "备份失败: %v\n", err)
+		os.Exit(1)
+	}
+	utils.PrintSuccess("已备份 %d 个节点到 %s", m.Nodes, out)
+	fmt.Println("注意: 备份包含私钥和全部凭据，传输/保存时当密码对待 (scp 后删掉本地副本)")
+	fmt.Println("新机器上: proxy-manager restore " + out)
+}
+
+// runRestore implements `proxy-manager restore <file> [--ip IP] [--force]`.
+func runRestore(args []string) {
+	checkRoot()
+	file := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--ip" {
+			i++ // 跳过 flag 值
+			continue
+		}
+		if !strings.HasPrefix(args[i], "--") {
+			file = args[i]
+			break
+		}
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "用法: proxy-manager restore <backup.tar.gz> [--ip 新机器公网IP] [--force]")
+		os.Exit(2)
+	}
+
+	if s, err := store.Load(); err == nil && len(s.Nodes) > 0 && !flagPresent(args, "--force") {
+		fmt.Fprintf(os.Stderr, "本机已有 %d 个节点，restore 会覆盖 nodes.json 和各协议配置。\n", len(s.Nodes))
+		fmt.Fprintln(os.Stderr, "确认要覆盖请加 --force (当前 nodes.json 会先存一份快照: proxy-manager store snapshots)")
+		os.Exit(1)
+	}
+
+	res, err := backup.Restore(file, backup.RestoreOptions{ServerIP: flagValue(args, "--ip")})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "恢复失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	utils.PrintSuccess("已从 %s 的备份恢复 (%s, %s)",
+		emptyDash(res.Manifest.Hostname), res.Manifest.CreatedAt.Local().Format("2006-01-02 15:04"), res.Manifest.Version)
+	if res.OldIP != "" && res.OldIP != res.NewIP {
+		fmt.Printf("  IP: %s → %s\n", res.OldIP, res.NewIP)
+	}
+	for _, id := range res.Restored {
+		fmt.Printf("  %s %s\n", goodIcon, id)
+	}
+	failedIDs := make([]string, 0, len(res.Failed))
+	for id := range res.Failed {
+		failedIDs = append(failedIDs, id)
+	}
+	sort.Strings(failedIDs)
+	for _, id := range failedIDs {
+		fmt.Printf("  %s %s: %s\n", badIcon, id, res.Failed[id])
+	}
+	if len(res.Domains) > 0 {
+		fmt.Println()
+		fmt.Printf("请把以下域名的 DNS A/AAAA 记录改指向 %s:\n", res.NewIP)
+		for _, d := range res.Domains {
+			fmt.Println("  " + d)
+		}
+	}
+	fmt.Println()
+	fmt.Println("凭据未变，客户端刷新订阅即可。证书自动续签需要 acme.sh，建议稍后在菜单里重新申请一次证书。")
+	if len(res.Failed) > 0 {
+		os.Exit(1)
+	}
+}
@@ -39,10 +39,15 @@ func runDoctor(args []string) {
 	if len(s.Nodes) == 0 {
 		fmt.Println("  (尚未安装任何协议)")
 	} else {
+		// params 在 LoadOrMigrate 里已经校验过 (store.Store.Invalid)
+		invalid := map[string]error{}
+		for _, e := range s.Invalid {
+			invalid[e.ID] = e.Err
+		}
 		nodes := append([]store.Node{}, s.Nodes...)
 		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
 		for _, n := range nodes {
-			printProtocolRow(n)
+			printProtocolRow(n, invalid[n.ID])
 		}
 	}
 
@@ -63,6 +68,9 @@ func runDoctor(args []string) {
 		fmt.Printf("    Domain: %s\n", s.Subscribe.Domain)
 		fmt.Printf("    Port:   %d\n", s.Subscribe.Port)
 		printAutocertCert(s.Subscribe.Domain)
+		if warn := printSyncHistory(s.Subscribe, "    "); warn != "" {
+			fmt.Printf("    %s %s\n", warnIcon, warn)
+		}
 	} else {
 		fmt.Println("    (未配置 — proxy-manager subscribe enable 启用)")
 	}
@@ -71,13 +79,40 @@ func runDoctor(args []string) {
 	fmt.Println()
 	fmt.Printf("%s[General]%s\n", utils.ColorCyan, utils.ColorReset)
 	fmt.Printf("  Config:  %s (%d nodes)\n", store.FilePath(), len(s.Nodes))
+	if len(s.Invalid) > 0 {
+		ids := make([]string, 0, len(s.Invalid))
+		for _, e := range s.Invalid {
+			ids = append(ids, e.ID)
+		}
+		fmt.Printf("  Params:  %s %d 个节点 params 无效，订阅/导出会跳过: %s\n", badIcon, len(ids), strings.Join(ids, ", "))
+	}
+	printSchemaStatus()
 	legacyCount := countLegacyFiles()
 	if legacyCount > 0 {
-		fmt.Printf("  Legacy:  %d 旧 .txt 文件存在 (兼容存留，可忽略)\n", legacyCount)
+		fmt.Printf("  Legacy:  %s %d 个旧 .txt 还没导入 nodes.json (用 root 重跑 doctor 即导入并删除)\n", warnIcon, legacyCount)
 	}
 	fmt.Println()
 }
 
+// printSchemaStatus 报告 nodes.json 的 schema 版本。LoadOrMigrate 已经尝试
+// 过落盘升级，这里还有 pending 说明写失败了 (一般是非 root 跑 doctor)。
+func printSchemaStatus() {
+	st, err := store.ReadSchemaStatus()
+	if err != nil {
+		fmt.Printf("  Schema:  %s 读取失败: %v\n", badIcon, err)
+		return
+	}
+	switch {
+	case st.OnDisk > st.Current:
+		fmt.Printf("  Schema:  %s v%d (本 binary 只认识到 v%d，请先 proxy-manager update)\n", warnIcon, st.OnDisk, st.Current)
+	case len(st.Pending) > 0:
+		fmt.Printf("  Schema:  %s v%d → v%d 待升级 (用 root 重跑 doctor 落盘): %s\n",
+			warnIcon, st.OnDisk, st.Current, strings.Join(st.Pending, "; "))
+	default:
+		fmt.Printf("  Schema:  %s v%d\n", goodIcon, st.OnDisk)
+	}
+}
+
 // --- protocol row helpers --------------------------------------------------
 
 const (
@@ -105,12 +140,29 @@ var protocolMap = map[store.NodeType]protocolDescriptor{
 	store.TypeAnyTLSReality: {"AnyTLS + Reality", "anytls-reality", ""},
 }
 
-func printProtocolRow(n store.Node) {
+func printProtocolRow(n store.Node, invalid error) {
 	desc, ok := protocolMap[n.Type]
 	if !ok {
 		fmt.Printf("  ? %-40s 未知协议类型 (%s)\n", n.Name, n.Type)
 		return
 	}
+	if n.External {
+		// node import 进来的，不在本机：没有 unit 可查
+		icon, state := goodIcon, "external"
+		if n.Disabled {
+			icon, state = warnIcon, "disabled"
+		}
+		fmt.Printf("  %s %-22s %-24s %-12s %s:%d\n",
+			icon, n.Name, "-", state, n.Server, n.Port)
+		printInvalidParams(invalid)
+		return
+	}
+	if n.Disabled {
+		fmt.Printf("  %s %-22s %-24s %-12s :%d\n",
+			warnIcon, n.Name, desc.serviceName, "disabled", n.Port)
+		printInvalidParams(invalid)
+		return
+	}
 	state := systemctlIsActive(desc.serviceName)
 	icon := badIcon
 	colored := false
@@ -122,11 +174,18 @@ func printProtocolRow(n store.Node) {
 	}
 	fmt.Printf("  %s %-22s %-24s %-12s :%d\n",
 		icon, n.Name, desc.serviceName, paint(state, colored), n.Port)
+	printInvalidParams(invalid)
 	if desc.certPath != "" {
 		printCertExpiry(desc.certPath, "    ")
 	}
 }
 
+func printInvalidParams(err error) {
+	if err != nil {
+		fmt.Printf("    %s %s (订阅/导出会跳过此节点)\n", badIcon, paint(err.Error(), false))
+	}
+}
+
 // systemctlIsActive shells out to systemctl. Uses Output (not Run) so we
 // capture the literal state string regardless of exit code.
 func systemctlIsActive(unit string) string {
@@ -72,7 +72,7 @@ func pickInstalledProtocol(nodes []store.Node) string {
 	}
 	var opts []opt
 	for _, n := range nodes {
-		if name, ok := supported[n.Type]; ok {
+		if name, ok := supported[n.Type]; ok && !n.External {
 			opts = append(opts, opt{key: string(n.Type), desc: name + "  (port :" + fmt.Sprint(n.Port) + ")"})
 		}
 	}
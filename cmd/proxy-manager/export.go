@@ -1,24 +1,35 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
 
 	"github.com/Mamaaz/proxy-manager/internal/format"
 	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
 )
 
-// runExport implements `proxy-manager export --format=<surge|clash|singbox|xray|json>`.
+// runExport implements `proxy-manager export --format=<name>`, name being any
+// format in the format registry (the same set /s/{format}/{token} serves).
 //
 // Output is written to stdout. The command runs LoadOrMigrate so the first
 // invocation on an old install transparently builds nodes.json from existing
 // .txt configs.
 func runExport(args []string) {
 	formatName := "json"
+	var tags []string
+	params := url.Values{}
+	addParam := func(kv string) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			fmt.Fprintf(os.Stderr, "--param 需要 key=value: %s\n", kv)
+			os.Exit(2)
+		}
+		params.Add(k, v)
+	}
 	for i := 0; i < len(args); i++ {
 		a := args[i]
 		switch {
@@ -27,8 +38,20 @@ func runExport(args []string) {
 			i++
 		case strings.HasPrefix(a, "--format="):
 			formatName = strings.TrimPrefix(a, "--format=")
+		case a == "--tag" && i+1 < len(args):
+			tags = append(tags, splitList(args[i+1])...)
+			i++
+		case strings.HasPrefix(a, "--tag="):
+			tags = append(tags, splitList(strings.TrimPrefix(a, "--tag="))...)
+		case a == "--param" && i+1 < len(args):
+			addParam(args[i+1])
+			i++
+		case strings.HasPrefix(a, "--param="):
+			addParam(strings.TrimPrefix(a, "--param="))
 		case a == "-h" || a == "--help":
-			fmt.Println("Usage: proxy-manager export [--format=json|surge|clash|mihomo|singbox|xray|qx]")
+			fmt.Printf("Usage: proxy-manager export [--format=%s] [--tag TAG[,TAG]] [--param k=v]...\n", strings.Join(formatNames(), "|"))
+			fmt.Println("  已 disable 的节点不导出；--tag 只导出带该 tag (或该协议类型) 的节点")
+			fmt.Println("  --param 等同订阅 URL 的查询参数, e.g. singbox-profile: --param version=1.11 --param tun=1")
 			return
 		default:
 			fmt.Fprintf(os.Stderr, "未知参数: %s\n", a)
@@ -36,118 +59,56 @@ func runExport(args []string) {
 		}
 	}
 
+	f, ok := format.Lookup(formatName)
+	if !ok {
+		fmt.Fprintln(os.Stderr, format.UnknownFormatError(formatName))
+		os.Exit(2)
+	}
+
 	s, err := store.LoadOrMigrate()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "读取节点失败: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Stable order helps deterministic output for diffing/audits.
-	sort.SliceStable(s.Nodes, func(i, j int) bool {
-		return s.Nodes[i].ID < s.Nodes[j].ID
-	})
-
-	if err := writeFormat(os.Stdout, formatName, s); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	nodes, invalid := store.SplitValid(store.Published(s.Nodes, tags))
+	for _, e := range invalid {
+		fmt.Fprintf(os.Stderr, "  # skip %v\n", e)
 	}
-}
 
-func writeFormat(w io.Writer, name string, s *store.Store) error {
-	switch name {
-	case "json":
-		return writeJSON(w, s)
-	case "surge":
-		return writeSurge(w, s)
-	case "clash", "mihomo":
-		return writeClash(w, s)
-	case "singbox", "sing-box":
-		return writeSingbox(w, s)
-	case "xray":
-		return writeXray(w, s)
-	case "qx", "quantumultx":
-		return writeQX(w, s)
-	}
-	return fmt.Errorf("未知格式: %s (支持: json, surge, clash, mihomo, singbox, xray, qx)", name)
-}
+	// Stable order helps deterministic output for diffing/audits.
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
 
-func writeQX(w io.Writer, s *store.Store) error {
-	for _, n := range s.Nodes {
-		line, err := format.ToQX(&n)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  # skip %s: %v\n", n.ID, err)
-			continue
+	// 订阅服务开着的话，自更新的 profile (surge-profile) 指回对应的订阅 URL。
+	opts := format.Options{Params: params}
+	if u := subscribe.Urls(s)[f.Name()]; u != "" {
+		q := url.Values{}
+		for k, v := range params {
+			q[k] = v
 		}
-		fmt.Fprintln(w, line)
-	}
-	return nil
-}
-
-func writeJSON(w io.Writer, s *store.Store) error {
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(s)
-}
-
-func writeSurge(w io.Writer, s *store.Store) error {
-	for _, n := range s.Nodes {
-		line, err := format.ToSurge(&n)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  # skip %s: %v\n", n.ID, err)
-			continue
+		if len(tags) > 0 {
+			q.Set("tag", strings.Join(tags, ","))
 		}
-		fmt.Fprintln(w, line)
-	}
-	return nil
-}
-
-func writeClash(w io.Writer, s *store.Store) error {
-	proxies := make([]map[string]any, 0, len(s.Nodes))
-	for _, n := range s.Nodes {
-		entry, err := format.ToClash(&n)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  # skip %s: %v\n", n.ID, err)
-			continue
+		opts.URL = u
+		if len(q) > 0 {
+			opts.URL += "?" + q.Encode()
 		}
-		proxies = append(proxies, entry)
 	}
-	out := map[string]any{"proxies": proxies}
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(out)
-}
 
-func writeSingbox(w io.Writer, s *store.Store) error {
-	outbounds := make([]map[string]any, 0, len(s.Nodes)*2)
-	for _, n := range s.Nodes {
-		entries, err := format.ToSingbox(&n)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  # skip %s: %v\n", n.ID, err)
-			continue
-		}
-		outbounds = append(outbounds, entries...)
+	if err := f.Render(os.Stdout, nodes, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	out := map[string]any{"outbounds": outbounds}
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(out)
 }
 
-func writeXray(w io.Writer, s *store.Store) error {
-	outbounds := make([]map[string]any, 0, len(s.Nodes))
-	for _, n := range s.Nodes {
-		if !format.NeedsBridge(&n) {
-			continue
-		}
-		entries, err := format.ToXray(&n)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "  # skip %s: %v\n", n.ID, err)
-			continue
-		}
-		outbounds = append(outbounds, entries...)
+// formatNames lists every registered format with its aliases, for help text.
+func formatNames() []string {
+	var out []string
+	for _, f := range format.All() {
+		out = append(out, f.Name())
+		out = append(out, f.Aliases()...)
 	}
-	out := map[string]any{"outbounds": outbounds}
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(out)
+	return out
 }
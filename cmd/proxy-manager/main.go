@@ -50,6 +50,29 @@ func main() {
 		case "kernel":
 			runKernel(os.Args[2:])
 			return
+		case "user":
+			runUser(os.Args[2:])
+			return
+		case "node":
+			runNode(os.Args[2:])
+			return
+		case "store":
+			runStore(os.Args[2:])
+			return
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "audit":
+			runAudit(os.Args[2:])
+			return
+		case "admin-helper":
+			// /api/v1 的 root helper (proxy-manager-admin 服务调用，不给人用)
+			checkRoot()
+			runAdminHelper()
+			return
 		case "service-rebuild":
 			checkRoot()
 			runServiceRebuild(os.Args[2:])
@@ -106,10 +129,11 @@ func showHelp() {
   proxy-manager --help       显示此帮助信息
   proxy-manager --version    显示版本信息
   proxy-manager update       更新到最新版
-  proxy-manager export --format=<json|surge|clash|mihomo|singbox|xray|qx>
-                             导出已安装节点为指定格式 (输出到 stdout)
+  proxy-manager export --format=<json|surge|clash|singbox|xray|qx|...>
+                             导出已安装节点为指定格式 (输出到 stdout,
+                             全部格式见 proxy-manager export --help)
   proxy-manager subscribe <command>
-                             订阅 HTTPS 服务: enable / disable / status / url / rotate-token
+                             订阅 HTTPS 服务: enable / disable / status / url / rotate-token / token
                              (详细: proxy-manager subscribe --help)
   proxy-manager doctor       一键诊断: 协议服务/证书/订阅服务状态
   proxy-manager sni-test <host>
@@ -119,6 +143,20 @@ func showHelp() {
                              RealiTLScanner CSV 或 hostname list (stdin / args)
   proxy-manager edit         修改已安装协议的可变字段，无需 reinstall:
                              - reality: port/uuid/short-id/sni
+  proxy-manager user <list|add|remove|disable|enable> <node> [name]
+                             一个节点多个用户，各自独立凭据；增删只重启该节点
+  proxy-manager node <list|disable|enable|tag|note|import> [node]
+                             节点下线维护 (凭据保留) / tags / 备注 / 导入外部节点
+  proxy-manager store <snapshots|diff <id>|restore <id>>
+                             nodes.json 历史快照: 查看 / 对比 / 回滚
+  proxy-manager store <encrypt|rekey|decrypt>
+                             nodes.json 私钥/凭据/token 静态加密
+  proxy-manager backup [--out FILE]
+                             打包全部节点/配置/证书，迁移 VPS 用
+  proxy-manager restore <FILE> [--ip IP] [--force]
+                             在新 VPS 上按备份恢复所有协议，凭据不变，改写为新 IP
+  proxy-manager audit [--since 7d] [--node ID]
+                             配置变更审计日志: 谁在什么时候改了哪些字段
   proxy-manager kernel       管理底层内核 (xray-core / sing-box)
                              list (default) | upgrade [name|--all]
   proxy-manager service-rebuild
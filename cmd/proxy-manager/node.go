@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/format"
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// runNode dispatches `proxy-manager node <command>`.
+//
+//	list
+//	disable <node>
+//	enable  <node>
+//	tag     <node> [tag ...]
+//	note    <node> [text ...]
+//	import  <uri|file|-> [--tag TAG[,TAG]] [--dry-run]
+//
+// disable/enable 是维护用的下线开关：凭据、config、证书全保留。import 收编
+// 不归本工具管的节点 (External)，只进订阅，本机不装任何东西。
+func runNode(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list", "ls":
+		runNodeList()
+	case "disable", "enable":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "用法: proxy-manager node %s <node>\n", args[0])
+			os.Exit(2)
+		}
+		checkRoot()
+		runNodeToggle(args[1], args[0] == "disable")
+	case "tag", "note":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "用法: proxy-manager node %s <node> [...]\n", args[0])
+			os.Exit(2)
+		}
+		checkRoot()
+		runNodeLabel(args[0], args[1], args[2:])
+	case "import":
+		if len(args) < 2 || strings.HasPrefix(args[1], "--") {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager node import <uri|file|-> [--tag TAG[,TAG]] [--dry-run]")
+			os.Exit(2)
+		}
+		if !flagPresent(args, "--dry-run") {
+			checkRoot()
+		}
+		runNodeImport(args[1], splitList(flagValue(args, "--tag")), flagPresent(args, "--dry-run"))
+	case "-h", "--help", "help":
+		fmt.Println(nodeHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", args[0], nodeHelp())
+		os.Exit(2)
+	}
+}
+
+func nodeHelp() string {
+	return `用法: proxy-manager node <command>
+
+  list                   列出节点 (状态 / tags / 备注)
+  disable <node>         下线维护: 订阅和导出不再下发, 停掉 systemd unit
+                         (凭据 / config 全保留, 客户端不用重新导入)
+  enable  <node>         恢复上线
+  tag     <node> [tag..] 设置 tags (替换原有; 不给 tag 即清空), 如 region=hk tier=backup
+  note    <node> [text]  设置备注 (不给 text 即清空)
+  import  <uri|file|->   导入外部节点 (不在本机, 只进订阅): vless:// hysteria2:// hy2://
+                         anytls:// 分享链接, base64 订阅, Clash / sing-box 配置
+                         [--tag TAG[,TAG]] 给导入的节点打 tag; [--dry-run] 只打印不写入
+
+订阅 URL 加 ?tag=region=hk、export 加 --tag region=hk 只取带该 tag 的节点。
+<node> 可以是节点 ID，或唯一的协议名: reality / hysteria2 / anytls / anytls-reality
+(外部节点只能用 ID: ext-...)`
+}
+
+func runNodeList() {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 nodes.json 失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(s.Nodes) == 0 {
+		fmt.Println("(尚未安装任何协议)")
+		return
+	}
+	nodes := append([]store.Node{}, s.Nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	fmt.Printf("%-32s %-9s %-8s %-6s %s\n", "ID", "STATUS", "WHERE", "PORT", "TAGS")
+	for _, n := range nodes {
+		status := "enabled"
+		if n.Disabled {
+			status = "disabled"
+		}
+		where := "local"
+		if n.External {
+			where = "external"
+		}
+		fmt.Printf("%-32s %-9s %-8s %-6d %s\n", n.ID, status, where, n.Port, dashIfEmpty(strings.Join(n.Tags, ",")))
+		if n.Note != "" {
+			fmt.Printf("  # %s\n", n.Note)
+		}
+	}
+}
+
+func runNodeToggle(ref string, disable bool) {
+	n, err := install.SetNodeDisabled(ref, disable)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "操作失败: %v\n", err)
+		os.Exit(1)
+	}
+	if disable {
+		utils.PrintSuccess("%s 已下线: 订阅不再下发, 服务已停止 (凭据保留)", n.ID)
+		return
+	}
+	utils.PrintSuccess("%s 已恢复上线", n.ID)
+}
+
+func runNodeLabel(what, ref string, rest []string) {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 nodes.json 失败: %v\n", err)
+		os.Exit(1)
+	}
+	target, err := s.FindNode(ref)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var n store.Node
+	if what == "tag" {
+		var tags []string
+		for _, a := range rest {
+			tags = append(tags, splitList(a)...)
+		}
+		n, err = store.SetNodeTags(target.ID, tags)
+	} else {
+		n, err = store.SetNodeNote(target.ID, strings.Join(rest, " "))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "操作失败: %v\n", err)
+		os.Exit(1)
+	}
+	if what == "tag" {
+		fmt.Printf("%s tags: %s\n", n.ID, dashIfEmpty(strings.Join(n.Tags, ",")))
+		return
+	}
+	fmt.Printf("%s note: %s\n", n.ID, dashIfEmpty(n.Note))
+}
+
+// runNodeImport 解析 src (分享链接 / 文件 / - 读 stdin) 里的节点，作为外部
+// 节点写进 nodes.json。解析失败的条目逐条报出来，其余照常导入。
+func runNodeImport(src string, tags []string, dryRun bool) {
+	var data []byte
+	var err error
+	switch {
+	case src == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case strings.Contains(src, "://"):
+		data = []byte(src)
+	default:
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 %s 失败: %v\n", src, err)
+		os.Exit(1)
+	}
+
+	nodes, errs := format.ParseNodes(data)
+	for _, e := range errs {
+		utils.PrintWarn("跳过: %v", e)
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintln(os.Stderr, "没有可导入的节点")
+		os.Exit(1)
+	}
+	for i := range nodes {
+		nodes[i].Tags = tags
+		fmt.Printf("  %-40s %-16s %s:%d  %s\n", nodes[i].ID, nodes[i].Type, nodes[i].Server, nodes[i].Port, nodes[i].Name)
+	}
+	if dryRun {
+		fmt.Printf("(dry-run) 解析出 %d 个节点，未写入\n", len(nodes))
+		return
+	}
+	added, err := store.ImportExternal(nodes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "导入失败: %v\n", err)
+		os.Exit(1)
+	}
+	utils.PrintSuccess("已导入 %d 个外部节点 (新增 %d, 更新 %d)，订阅 / 导出里立即可见", len(nodes), added, len(nodes)-added)
+}
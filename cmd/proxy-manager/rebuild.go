@@ -18,7 +18,7 @@ import (
 // restarts the affected services.
 //
 // Safe to run on a fresh / partially-installed VPS — protocols without a
-// .txt config file are silently skipped.
+// node in nodes.json are silently skipped.
 func runServiceRebuild(args []string) {
 	_ = args
 	utils.PrintInfo("正在重建已安装协议的 systemd 单元...")
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// runStore dispatches `proxy-manager store <command>`.
+//
+//	snapshots        列出 nodes.json 历史快照
+//	diff <id>        快照 → 当前 之间变了什么 (只列字段名，不打印值)
+//	restore <id>     回滚到快照并重建受影响的协议
+//	encrypt / rekey / decrypt   nodes.json 静态加密 (store/crypt.go)
+func runStore(args []string) {
+	if len(args) == 0 {
+		fmt.Println(storeHelp())
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "snapshots", "ls":
+		checkRoot()
+		runStoreSnapshots()
+	case "diff":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager store diff <id>")
+			os.Exit(2)
+		}
+		checkRoot()
+		runStoreDiff(args[1])
+	case "restore":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager store restore <id>")
+			os.Exit(2)
+		}
+		checkRoot()
+		runStoreRestore(args[1])
+	case "encrypt", "rekey", "decrypt":
+		checkRoot()
+		runStoreCrypt(args[0])
+	case "-h", "--help", "help":
+		fmt.Println(storeHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", args[0], storeHelp())
+		os.Exit(2)
+	}
+}
+
+func storeHelp() string {
+	return fmt.Sprintf(`用法: proxy-manager store <command>
+
+  snapshots      列出 nodes.json 快照 (每次修改前自动保存, 保留最近 %d 份)
+  diff <id>      对比快照和当前 nodes.json: 增删了哪些节点、改了哪些字段
+  restore <id>   回滚到快照, 并重写/重装受影响协议的内核配置
+                 (restore 本身也会先存一份快照, 可以再 restore 回来)
+  encrypt        加密 nodes.json 里的私钥 / 凭据 / token (key 在 %s)
+  rekey          换新 key 重新加密 nodes.json 和全部快照
+  decrypt        关闭加密, 写回明文并删除 key`, store.MaxSnapshots, store.MasterKeyPath)
+}
+
+func runStoreSnapshots() {
+	snaps, err := store.ListSnapshots()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取快照失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(snaps) == 0 {
+		fmt.Println("(还没有快照 — nodes.json 第一次被修改时自动生成)")
+		return
+	}
+	fmt.Printf("%-20s %-20s %s\n", "ID", "TIME", "NODES")
+	for _, sn := range snaps {
+		nodes := "?"
+		if s, err := store.LoadSnapshot(sn.ID); err == nil {
+			nodes = fmt.Sprint(len(s.Nodes))
+		}
+		fmt.Printf("%-20s %-20s %s\n", sn.ID, sn.Time.Format("2006-01-02 15:04:05"), nodes)
+	}
+}
+
+func runStoreDiff(id string) {
+	snap, err := store.LoadSnapshot(id)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cur, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 nodes.json 失败: %v\n", err)
+		os.Exit(1)
+	}
+	d := store.Diff(snap, cur)
+	if d.Empty() {
+		fmt.Println("快照与当前 nodes.json 一致")
+		return
+	}
+	fmt.Printf("快照 %s → 当前:\n", id)
+	printStoreDiff(d)
+}
+
+func runStoreRestore(id string) {
+	before, err := store.RestoreSnapshot(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore 失败: %v\n", err)
+		os.Exit(1)
+	}
+	after, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 nodes.json 失败: %v\n", err)
+		os.Exit(1)
+	}
+	d := store.Diff(before, after)
+	utils.PrintSuccess("nodes.json 已回滚到 %s", id)
+	if d.Empty() {
+		fmt.Println("(内容与回滚前一致，无需重建)")
+		return
+	}
+	printStoreDiff(d)
+	fmt.Println()
+
+	affected := append([]string{}, d.Added...)
+	for nodeID := range d.Changed {
+		affected = append(affected, nodeID)
+	}
+	sort.Strings(affected)
+	failed := false
+	for _, nodeID := range affected {
+		n, err := after.FindNode(nodeID)
+		if err != nil || n.External {
+			continue // 外部节点只有 nodes.json 里的记录，没东西可重建
+		}
+		if install.NodeUnitInstalled(n.Type) {
+			utils.PrintInfo("重写 %s 内核配置...", n.ID)
+			err = install.ApplyNode(*n)
+		} else {
+			utils.PrintInfo("%s 已被卸载，按快照重新安装 (凭据不变)...", n.ID)
+			err = install.ReinstateNode(*n)
+		}
+		if err != nil {
+			utils.PrintError("%s: %v", n.ID, err)
+			failed = true
+		}
+	}
+	for _, nodeID := range d.Removed {
+		if n, err := before.FindNode(nodeID); err == nil && n.External {
+			continue
+		}
+		utils.PrintWarn("%s 不在快照里，但协议仍装在本机——不需要的话从菜单卸载", nodeID)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printStoreDiff(d store.StoreDiff) {
+	for _, id := range d.Added {
+		fmt.Printf("  + %s\n", id)
+	}
+	for _, id := range d.Removed {
+		fmt.Printf("  - %s\n", id)
+	}
+	ids := make([]string, 0, len(d.Changed))
+	for id := range d.Changed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("  ~ %s: %s\n", id, strings.Join(d.Changed[id], ", "))
+	}
+	if len(d.Subscribe) > 0 {
+		fmt.Printf("  ~ subscribe: %s\n", strings.Join(d.Subscribe, ", "))
+	}
+}
+
+func runStoreCrypt(action string) {
+	var err error
+	switch action {
+	case "encrypt":
+		err = store.EnableEncryption()
+	case "rekey":
+		err = store.Rekey()
+	case "decrypt":
+		err = store.DisableEncryption()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s 失败: %v\n", action, err)
+		os.Exit(1)
+	}
+	switch action {
+	case "encrypt":
+		utils.PrintSuccess("nodes.json 已加密")
+		fmt.Printf("  私钥用 %s (仅 root 可读)\n", store.MasterKeyPath)
+		fmt.Printf("  客户端凭据 / token 用 %s (订阅服务可读)\n", store.SubscribeKeyPath)
+		fmt.Println("  注意: 丢了 master.key 就解不开了——proxy-manager backup 会一并打包")
+	case "rekey":
+		utils.PrintSuccess("已换新 key，nodes.json 和快照均已重新加密")
+	case "decrypt":
+		utils.PrintSuccess("已关闭加密，nodes.json 恢复明文")
+	}
+	if subscribe.Status() == "active" {
+		// daemon 每次请求都重新 load，但 subscribe.key 的属组要对
+		if err := subscribe.Rebuild(); err != nil {
+			utils.PrintWarn("重建订阅服务失败: %v (proxy-manager service-rebuild 重试)", err)
+		}
+	}
+}
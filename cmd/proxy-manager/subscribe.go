@@ -21,6 +21,11 @@ import (
 //	status
 //	rotate-token
 //	url
+//	token add|list|revoke|rotate   (subscribe_token.go)
+//	upstream add|list|remove       (subscribe_upstream.go)
+//	quota [--token L] [--total ...] (subscribe_quota.go)
+//	metrics token|listen|disable   (subscribe_metrics.go)
+//	admin enable|token|disable     (subscribe_admin.go)
 //	serve [--domain X --port N --email Y]   (used by the systemd unit; not for direct human use)
 func runSubscribe(args []string) {
 	if len(args) == 0 {
@@ -38,6 +43,16 @@ func runSubscribe(args []string) {
 		runSubscribeRotate()
 	case "url":
 		runSubscribeURL()
+	case "token":
+		runSubscribeToken(args[1:])
+	case "upstream":
+		runSubscribeUpstream(args[1:])
+	case "quota":
+		runSubscribeQuota(args[1:])
+	case "metrics":
+		runSubscribeMetrics(args[1:])
+	case "admin":
+		runSubscribeAdmin(args[1:])
 	case "serve":
 		runSubscribeServe(args[1:])
 	case "-h", "--help", "help":
@@ -57,7 +72,16 @@ func subscribeHelp() string {
   disable        停止并删除订阅服务 (保留 token, 配置可恢复)
   status         查看订阅服务状态
   url            打印当前订阅 URL (5 种格式)
-  rotate-token   生成新 token, 旧 URL 立即失效
+  rotate-token   生成新主 token, 旧 URL 在宽限期后失效
+  token ...      按人发放只看部分节点的 token: add / list / revoke / rotate
+                 (详细: proxy-manager subscribe token --help)
+  upstream ...   合并其他服务器的节点, 一个 URL 覆盖整个机群: add / list / remove
+                 (详细: proxy-manager subscribe upstream --help)
+  quota ...      客户端显示的流量额度 / 到期日 / 更新间隔 (Subscription-Userinfo)
+                 (详细: proxy-manager subscribe quota --help)
+  metrics ...    Prometheus /metrics: token / listen 127.0.0.1:PORT / disable
+  admin ...      /api/v1 管理接口 (admin token / mTLS): enable / token / disable
+                 (详细: proxy-manager subscribe admin --help)
   serve ...      作为前台进程运行订阅服务 (供 systemd 调用, 一般不需要手动跑)`
 }
 
@@ -134,6 +158,13 @@ func runSubscribeStatus() {
 		// 截短显示，避免日志泄露完整 token
 		fmt.Printf("token:   %s...%s\n", s.Subscribe.Token[:4], s.Subscribe.Token[len(s.Subscribe.Token)-4:])
 	}
+	if len(s.Subscribe.Tokens) > 0 {
+		fmt.Printf("labelled tokens: %d (proxy-manager subscribe token list)\n", len(s.Subscribe.Tokens))
+	}
+	fmt.Println()
+	if warn := printSyncHistory(s.Subscribe, ""); warn != "" {
+		fmt.Printf("\n警告: %s\n", warn)
+	}
 }
 
 func runSubscribeURL() {
@@ -248,17 +279,17 @@ func emptyDash(s string) string {
 }
 
 func printURLs(urls map[string]string) {
-	for _, k := range []string{"surge", "clash", "mihomo", "singbox", "xray", "qx", "json"} {
+	for _, k := range subscribe.URLNames() {
 		if v, ok := urls[k]; ok {
-			fmt.Printf("  %-8s %s\n", k+":", v)
+			fmt.Printf("  %-16s %s\n", k+":", v)
 		}
 	}
-	// QR for the JSON URL — that's the one Mac client and most subscription
-	// readers consume. Surge can subscribe by pasting the surge URL by hand.
-	if json, ok := urls["json"]; ok {
+	// QR for the auto URL — the server picks the format from the scanning
+	// client's User-Agent, so one code works for every app.
+	if auto, ok := urls[subscribe.AutoFormat]; ok {
 		fmt.Println()
-		fmt.Println("  扫码导入 (json):")
-		printQR(json)
+		fmt.Println("  扫码导入 (auto, 按客户端自动选格式):")
+		printQR(auto)
 	}
 }
 
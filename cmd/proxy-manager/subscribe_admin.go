@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/Mamaaz/proxy-manager/internal/admin"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+)
+
+// runSubscribeAdmin dispatches `proxy-manager subscribe admin <command>`.
+//
+//	status                    (默认) 显示 /api/v1 的认证方式和 helper 状态
+//	enable [--client-ca FILE] 装 root helper，生成 admin token (或配 mTLS CA)
+//	token                     更换 admin token
+//	disable                   关掉 /api/v1 并卸载 helper
+func runSubscribeAdmin(args []string) {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "status":
+		printAdminStatus()
+	case "enable":
+		checkRoot()
+		runSubscribeAdminEnable(args[1:])
+	case "token":
+		checkRoot()
+		token, err := store.RotateAdminToken()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("admin token 已更换 (旧 token 立即失效):")
+		fmt.Println()
+		fmt.Printf("  Authorization: Bearer %s\n", token)
+		printAdminStatus()
+	case "disable":
+		checkRoot()
+		if err := store.DisableAdmin(); err != nil {
+			fmt.Fprintf(os.Stderr, "关闭失败: %v\n", err)
+			os.Exit(1)
+		}
+		if err := admin.Uninstall(); err != nil {
+			fmt.Fprintf(os.Stderr, "卸载 admin helper 失败: %v\n", err)
+			os.Exit(1)
+		}
+		_ = os.Remove(store.AdminClientCAPath)
+		restartSubscribeDaemon() // 去掉 TLS 里的 ClientCAs
+		fmt.Println("/api/v1 已关闭")
+	case "-h", "--help", "help":
+		fmt.Println(subscribeAdminHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", cmd, subscribeAdminHelp())
+		os.Exit(2)
+	}
+}
+
+func subscribeAdminHelp() string {
+	return `用法: proxy-manager subscribe admin <command>
+
+  status                显示 /api/v1 管理接口的状态 (默认)
+  enable [--client-ca FILE]
+                        开启 /api/v1: 安装 root helper (proxy-manager-admin 服务),
+                        生成 admin token. --client-ca 另外接受该 CA 签发的客户端证书 (mTLS)
+  token                 更换 admin token
+  disable               关闭 /api/v1, 删除 token / CA, 卸载 helper
+
+接口:
+  GET  /api/v1/nodes[/ID]                 节点列表 / 单个节点
+  POST /api/v1/nodes/ID/disable|enable    下线维护 / 恢复
+  POST /api/v1/subscribe/rotate-token     轮换主 token
+  POST /api/v1/tokens/LABEL/rotate        轮换 labelled token
+  GET  /api/v1/doctor                     doctor 结果 (JSON)
+  POST /api/v1/kernels/check              检查内核更新`
+}
+
+func runSubscribeAdminEnable(args []string) {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if s.Subscribe.Domain == "" {
+		fmt.Fprintln(os.Stderr, "订阅服务未启用，先运行 proxy-manager subscribe enable")
+		os.Exit(1)
+	}
+
+	if caFile := flagValue(args, "--client-ca"); caFile != "" {
+		if err := installClientCA(caFile); err != nil {
+			fmt.Fprintf(os.Stderr, "安装客户端 CA 失败: %v\n", err)
+			os.Exit(1)
+		}
+		if err := store.SetAdminClientCA(store.AdminClientCAPath); err != nil {
+			fmt.Fprintf(os.Stderr, "设置失败: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if s.Subscribe.AdminToken == "" {
+		token, err := store.RotateAdminToken()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("admin token: %s\n", token)
+		fmt.Println("(只显示这一次完整值，忘了用 proxy-manager subscribe admin token 换新的)")
+	}
+
+	if err := admin.Install(); err != nil {
+		fmt.Fprintf(os.Stderr, "安装 admin helper 失败: %v\n", err)
+		os.Exit(1)
+	}
+	// ClientCAs 在 daemon 启动时加载
+	restartSubscribeDaemon()
+	printAdminStatus()
+}
+
+// installClientCA copies the CA bundle to store.AdminClientCAPath, readable
+// by the subscribe daemon (root:proxy-manager 0640).
+func installClientCA(src string) error {
+	if _, err := store.LoadClientCAs(src); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(store.AdminClientCAPath, data, 0640); err != nil {
+		return err
+	}
+	u, err := user.Lookup(subscribe.ServiceUser)
+	if err != nil {
+		return fmt.Errorf("找不到 %s 用户: %w", subscribe.ServiceUser, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return err
+	}
+	return os.Chown(store.AdminClientCAPath, 0, gid)
+}
+
+func printAdminStatus() {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	cfg := s.Subscribe
+	fmt.Println()
+	if cfg.AdminToken == "" && cfg.AdminClientCA == "" {
+		fmt.Println("/api/v1: 未启用 (proxy-manager subscribe admin enable)")
+		return
+	}
+	if cfg.Domain != "" && cfg.Port > 0 {
+		fmt.Printf("/api/v1: https://%s:%d/api/v1/\n", cfg.Domain, cfg.Port)
+	}
+	token := "-"
+	if cfg.AdminToken != "" {
+		token = maskToken(cfg.AdminToken)
+	}
+	fmt.Printf("token:   %s\n", token)
+	fmt.Printf("mTLS CA: %s\n", dashIfEmpty(cfg.AdminClientCA))
+	state := dashIfEmpty(admin.Status())
+	if state == "active" {
+		fmt.Printf("helper:  %s\n", paint(state, true))
+	} else {
+		fmt.Printf("helper:  %s  (特权操作会返回 503)\n", paint(state, false))
+	}
+}
+
+func runAdminHelper() {
+	if err := admin.Serve(subscribe.AdminSocketPath); err != nil {
+		fmt.Fprintf(os.Stderr, "admin helper: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// runSubscribeMetrics dispatches `proxy-manager subscribe metrics <command>`.
+//
+//	status                 (默认) 显示 /metrics 的访问方式
+//	token                  生成 / 更换 HTTPS 端口上 /metrics 的 bearer token
+//	listen 127.0.0.1:9101  开 loopback 明文端口 (off 关闭)
+//	disable                两种都关
+func runSubscribeMetrics(args []string) {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "status":
+		printMetricsStatus()
+	case "token":
+		token, err := store.RotateMetricsToken()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("metrics token 已生成 (旧 token 立即失效)，Prometheus 配置:")
+		fmt.Println()
+		fmt.Println("  scheme: https")
+		fmt.Println("  metrics_path: /metrics")
+		fmt.Println("  authorization:")
+		fmt.Printf("    credentials: %s\n", token)
+		printMetricsStatus()
+	case "listen":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager subscribe metrics listen <127.0.0.1:PORT|off>")
+			os.Exit(2)
+		}
+		addr := args[1]
+		if addr == "off" {
+			addr = ""
+		}
+		if err := store.SetMetricsListen(addr); err != nil {
+			fmt.Fprintf(os.Stderr, "设置失败: %v\n", err)
+			os.Exit(1)
+		}
+		restartSubscribeDaemon()
+		printMetricsStatus()
+	case "disable":
+		if err := store.DisableMetrics(); err != nil {
+			fmt.Fprintf(os.Stderr, "关闭失败: %v\n", err)
+			os.Exit(1)
+		}
+		restartSubscribeDaemon()
+		fmt.Println("/metrics 已关闭")
+	case "-h", "--help", "help":
+		fmt.Println(subscribeMetricsHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", cmd, subscribeMetricsHelp())
+		os.Exit(2)
+	}
+}
+
+func subscribeMetricsHelp() string {
+	return `用法: proxy-manager subscribe metrics <command>
+
+  status         显示 /metrics 的访问方式 (默认)
+  token          生成 / 更换 bearer token, 订阅 HTTPS 端口上的 /metrics 凭它访问
+  listen ADDR    另开只监听 loopback 的明文端口 (如 127.0.0.1:9101), 不需要 token
+                 listen off 关闭
+  disable        token 和明文端口都关掉`
+}
+
+func printMetricsStatus() {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	cfg := s.Subscribe
+	fmt.Println()
+	if cfg.MetricsToken == "" {
+		fmt.Println("token:  (未启用, proxy-manager subscribe metrics token)")
+	} else if cfg.Domain != "" && cfg.Port > 0 {
+		fmt.Printf("token:  %s  https://%s:%d/metrics\n", maskToken(cfg.MetricsToken), cfg.Domain, cfg.Port)
+	} else {
+		fmt.Printf("token:  %s  (订阅服务未启用)\n", maskToken(cfg.MetricsToken))
+	}
+	if cfg.MetricsListen == "" {
+		fmt.Println("listen: -")
+	} else {
+		fmt.Printf("listen: http://%s/metrics\n", cfg.MetricsListen)
+	}
+}
+
+// 明文端口在 daemon 启动时才开，改了要重启；token 每次请求现读，不用。
+func restartSubscribeDaemon() {
+	if subscribe.Status() != "active" {
+		return
+	}
+	if err := utils.ServiceRestart(subscribe.ServiceName); err != nil {
+		fmt.Fprintf(os.Stderr, "重启订阅服务失败: %v\n", err)
+		os.Exit(1)
+	}
+}
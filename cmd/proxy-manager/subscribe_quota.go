@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// runSubscribeQuota handles `proxy-manager subscribe quota`.
+//
+//	quota                                  列出主 token 和各 token 的额度 / 到期
+//	quota [--token LABEL] [--total 200G|0] [--expire 2026-12-31|30d|none] [--interval 12]
+//
+// 这些值通过 Subscription-Userinfo / Profile-Update-Interval 响应头给客户端
+// 展示。主 token 的 --expire 只是展示 (VPS 到期日)；labelled token 的
+// --expire 跟 `token add --expires` 是同一个字段，到期 URL 失效。
+//
+// 头里的已用流量 (upload / download) 只有主 token 有，而且是内核 unit 本次
+// 启动以来的计数，不是按账期累计；labelled token 只有 total / expire。
+func runSubscribeQuota(args []string) {
+	if flagPresent(args, "-h") || flagPresent(args, "--help") {
+		fmt.Println(subscribeQuotaHelp())
+		return
+	}
+	var c store.QuotaChange
+	if v := flagValue(args, "--total"); v != "" {
+		n, err := parseSize(v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		c.QuotaBytes = &n
+	}
+	if v := flagValue(args, "--expire"); v != "" {
+		var exp time.Time
+		if v != "none" {
+			var err error
+			if exp, err = parseExpiry(v, time.Now()); err != nil {
+				fmt.Fprintln(os.Stderr, strings.Replace(err.Error(), "--expires", "--expire", 1))
+				os.Exit(2)
+			}
+		}
+		c.ExpiresAt = &exp
+	}
+	if v := flagValue(args, "--interval"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "--interval 需要小时数: %s\n", v)
+			os.Exit(2)
+		}
+		c.UpdateIntervalHours = &n
+	}
+
+	if c.QuotaBytes == nil && c.ExpiresAt == nil && c.UpdateIntervalHours == nil {
+		printQuotas()
+		return
+	}
+	label := flagValue(args, "--token")
+	if err := store.SetQuota(label, c); err != nil {
+		fmt.Fprintf(os.Stderr, "设置失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("已更新，客户端下次更新订阅时生效")
+	printQuotas()
+}
+
+func subscribeQuotaHelp() string {
+	return `用法: proxy-manager subscribe quota [--token LABEL] [--total SIZE] [--expire DATE] [--interval HOURS]
+
+  不带参数        列出主 token 和各 token 的流量额度 / 到期日
+  --token LABEL   改某个 labelled token (不加 = 主 token)
+  --total SIZE    流量额度, 如 200G / 1.5T, 0 = 不限 (只展示, 不限速)
+  --expire DATE   到期日 YYYY-MM-DD 或 30d, none = 清除
+                  主 token: 只展示 (VPS 到期日); labelled token: 到期 URL 失效
+  --interval N    客户端自动更新间隔 (小时, 全局, 0 = 默认 24)
+
+已用流量:
+  只在主 token 的订阅里显示, labelled token 只显示额度和到期日 (计数分不到人)。
+  取自各内核 unit 的 systemd IPAccounting, 是 unit 本次启动以来的总量:
+  重启 / 重装 / VPS 重启都会清零, 不是按账期累计的用量。`
+}
+
+func printQuotas() {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("更新间隔: %dh\n", s.Subscribe.UpdateInterval())
+	fmt.Printf("%-12s %-10s %s\n", "TOKEN", "TOTAL", "EXPIRE")
+	fmt.Printf("%-12s %-10s %s\n", "(主 token)", formatSize(s.Subscribe.QuotaBytes), formatDate(s.Subscribe.PlanExpiresAt))
+	for _, t := range s.Subscribe.Tokens {
+		fmt.Printf("%-12s %-10s %s\n", t.Label, formatSize(t.QuotaBytes), formatDate(t.ExpiresAt))
+	}
+}
+
+var sizeUnits = []string{"K", "M", "G", "T"}
+
+// parseSize 接受 200G / 1.5T / 500M / 0 (1024 进制，客户端按这个显示)。
+func parseSize(v string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(v)), "B")
+	mult := 1.0
+	for i, u := range sizeUnits {
+		if strings.HasSuffix(s, u) {
+			s = strings.TrimSuffix(s, u)
+			mult = float64(int64(1) << (10 * (i + 1)))
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("--total 格式错误: %s (示例: 200G / 1.5T / 0)", v)
+	}
+	return int64(f * mult), nil
+}
+
+func formatSize(n int64) string {
+	if n <= 0 {
+		return "-"
+	}
+	v, unit := float64(n), ""
+	for _, u := range sizeUnits {
+		if v < 1024 {
+			break
+		}
+		v, unit = v/1024, u
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0") + unit
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+)
+
+// printSyncHistory 打印每个 token 最后一次被拉取的记录 (daemon 写的
+// sync.json)，subscribe status 和 doctor 共用。返回旧 token 告警 (没有为空)。
+func printSyncHistory(cfg store.SubscribeConfig, indent string) string {
+	st, err := subscribe.LoadSyncState()
+	if err != nil {
+		fmt.Printf("%s同步记录读取失败: %v\n", indent, err)
+		return ""
+	}
+	if len(st) == 0 {
+		fmt.Printf("%s同步记录: (还没有客户端拉过订阅)\n", indent)
+		return ""
+	}
+	now := time.Now()
+	rotated := subscribe.RotatedAt(cfg)
+	fmt.Printf("%s同步记录:\n", indent)
+	for _, key := range st.Keys() {
+		rec := st[key]
+		note := ""
+		switch key {
+		case subscribe.SyncMainToken:
+			if rec.At.Before(rotated) {
+				note = " (rotate 之前，用的是现在的旧 token)"
+			}
+		case subscribe.SyncPreviousToken:
+			if !rec.At.After(rotated) {
+				continue // 更早一代的旧 token，已经没意义
+			}
+		default:
+			if _, ok := cfg.FindAccessToken(key); !ok {
+				note = " (token 已删除)"
+			}
+		}
+		fmt.Printf("%s  %-12s %s  %-20s %-15s %s%s\n", indent, key, agoString(now.Sub(rec.At)),
+			rec.Format, rec.IP, emptyDash(rec.UserAgent), note)
+	}
+	return subscribe.PreviousTokenWarning(cfg, st, now)
+}
+
+// agoString 把时长压成 "3m前" / "5h前" / "2d前"，固定宽度好对齐。
+func agoString(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%6s", "刚刚")
+	case d < time.Hour:
+		return fmt.Sprintf("%4dm前", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%4dh前", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%4dd前", int(d.Hours()/24))
+	}
+}
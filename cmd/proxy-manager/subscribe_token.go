@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+)
+
+// runSubscribeToken dispatches `proxy-manager subscribe token <command>`.
+//
+//	add <label> [--nodes id,id] [--tags hysteria2,...] [--user NAME] [--expires 30d|2006-01-02] [--quota 100G]
+//	list
+//	revoke <label>
+//	rotate <label>
+//
+// 每个 label 一个独立 URL，只暴露圈定的节点；revoke / rotate 不影响主
+// token 和其他人。
+func runSubscribeToken(args []string) {
+	if len(args) == 0 {
+		fmt.Println(subscribeTokenHelp())
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "list", "ls":
+		runSubscribeTokenList()
+	case "add":
+		runSubscribeTokenAdd(args[1:])
+	case "revoke", "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager subscribe token revoke <label>")
+			os.Exit(2)
+		}
+		if err := store.RevokeAccessToken(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "revoke 失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("token %s 已吊销，对应 URL 立即失效\n", args[1])
+	case "rotate":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager subscribe token rotate <label>")
+			os.Exit(2)
+		}
+		t, err := store.RotateAccessToken(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotate 失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("token %s 已更换，旧 URL 立即失效。新 URL:\n", t.Label)
+		printTokenURLs(t)
+	case "-h", "--help", "help":
+		fmt.Println(subscribeTokenHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", args[0], subscribeTokenHelp())
+		os.Exit(2)
+	}
+}
+
+func subscribeTokenHelp() string {
+	return `用法: proxy-manager subscribe token <command>
+
+  add <label> [--nodes ID,ID] [--tags TAG,TAG] [--user NAME] [--expires 30d|YYYY-MM-DD] [--quota 100G]
+                 新建只看得到部分节点的订阅 token (不加 --nodes/--tags = 全部节点)
+                 --tags 按协议类型或节点 tag 匹配, 如 hysteria2 / region=hk
+                 --user 用 'proxy-manager user add' 建的用户凭据渲染
+                 --quota 客户端显示的流量额度 (之后改用 subscribe quota --token)
+  list           列出所有 token (截短显示)
+  revoke <label> 删除 token, URL 立即失效
+  rotate <label> 换新 token, 范围不变, 旧 URL 立即失效`
+}
+
+func runSubscribeTokenAdd(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "--") {
+		fmt.Fprintln(os.Stderr, "用法: proxy-manager subscribe token add <label> [...]")
+		os.Exit(2)
+	}
+	t := store.AccessToken{
+		Label: args[0],
+		Nodes: splitList(flagValue(args, "--nodes")),
+		Tags:  splitList(flagValue(args, "--tags")),
+		User:  flagValue(args, "--user"),
+	}
+	if v := flagValue(args, "--expires"); v != "" {
+		exp, err := parseExpiry(v, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		t.ExpiresAt = exp
+	}
+	if v := flagValue(args, "--quota"); v != "" {
+		n, err := parseSize(v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, strings.Replace(err.Error(), "--total", "--quota", 1))
+			os.Exit(1)
+		}
+		t.QuotaBytes = n
+	}
+
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(t.Scope(s.Nodes)) == 0 {
+		fmt.Fprintln(os.Stderr, "警告: 该 token 当前范围内没有任何节点 (检查 --nodes / --tags / --user)")
+	}
+
+	t, err = store.AddAccessToken(t)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "添加失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("已创建 token %s\n", t.Label)
+	printTokenURLs(t)
+}
+
+func runSubscribeTokenList() {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(s.Subscribe.Tokens) == 0 {
+		fmt.Println("(没有 labelled token，主 token 见 subscribe status)")
+		return
+	}
+	// sync.json 读不了 (daemon 没跑过) 就都显示 -
+	syncState, _ := subscribe.LoadSyncState()
+	now := time.Now()
+	fmt.Printf("%-12s %-13s %-28s %-10s %-16s %s\n", "LABEL", "TOKEN", "SCOPE", "USER", "EXPIRES", "LAST USED")
+	for _, t := range s.Subscribe.Tokens {
+		expires := "-"
+		if !t.ExpiresAt.IsZero() {
+			expires = t.ExpiresAt.Local().Format("2006-01-02")
+			if t.Expired(now) {
+				expires += " (过期)"
+			}
+		}
+		lastUsed := "-"
+		if rec, ok := syncState.LastUsed(t); ok {
+			lastUsed = rec.At.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-12s %-13s %-28s %-10s %-16s %s\n",
+			t.Label, maskToken(t.Token), tokenScopeString(t), emptyDash(t.User), expires, lastUsed)
+	}
+}
+
+func printTokenURLs(t store.AccessToken) {
+	s, err := store.Load()
+	if err != nil {
+		return
+	}
+	urls := subscribe.UrlsFor(s, t.Token)
+	if urls == nil {
+		fmt.Println("订阅服务未启用，URL 暂不可用. 先运行: proxy-manager subscribe enable")
+		return
+	}
+	printURLs(urls)
+}
+
+func tokenScopeString(t store.AccessToken) string {
+	var parts []string
+	if len(t.Nodes) > 0 {
+		parts = append(parts, "nodes="+strings.Join(t.Nodes, ","))
+	}
+	if len(t.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(t.Tags, ","))
+	}
+	if len(parts) == 0 {
+		return "(全部)"
+	}
+	return strings.Join(parts, " ")
+}
+
+func maskToken(t string) string {
+	if len(t) < 8 {
+		return "***"
+	}
+	return t[:4] + "..." + t[len(t)-4:]
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parseExpiry 接受 "30d" / "12h" 这种相对时长或 YYYY-MM-DD 绝对日期。
+func parseExpiry(v string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(v, "d") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(v, "d")); err == nil && n > 0 {
+			return now.Add(time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("--expires 格式错误: %s (示例: 30d / 12h / 2026-12-31)", v)
+}
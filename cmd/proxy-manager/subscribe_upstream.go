@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+)
+
+// runSubscribeUpstream dispatches `proxy-manager subscribe upstream <command>`.
+//
+//	add <url> [--label L]
+//	list
+//	remove <label>
+//
+// 上游是其他服务器的 json 订阅 URL，本机订阅会把它们的节点一并下发。
+func runSubscribeUpstream(args []string) {
+	if len(args) == 0 {
+		fmt.Println(subscribeUpstreamHelp())
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "list", "ls":
+		runSubscribeUpstreamList()
+	case "add":
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager subscribe upstream add <url> [--label L]")
+			os.Exit(2)
+		}
+		checkRoot()
+		runSubscribeUpstreamAdd(args[1], flagValue(args[2:], "--label"))
+	case "remove", "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager subscribe upstream remove <label>")
+			os.Exit(2)
+		}
+		checkRoot()
+		if err := store.RemoveUpstream(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "删除失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("upstream %s 已删除 (订阅服务下次请求生效)\n", args[1])
+	case "-h", "--help", "help":
+		fmt.Println(subscribeUpstreamHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", args[0], subscribeUpstreamHelp())
+		os.Exit(2)
+	}
+}
+
+func subscribeUpstreamHelp() string {
+	return fmt.Sprintf(`用法: proxy-manager subscribe upstream <command>
+
+  add <url> [--label L]  合并另一台服务器的节点进本机订阅
+                         url 是对方的 json 订阅 URL: https://host:port/s/json/<token>
+                         (label 缺省用对方域名)
+  list                   列出上游并实时拉取一次, 显示节点数 / 错误
+  remove <label>         删除上游
+
+订阅服务对每个上游缓存 %s, 上游不可用时继续用缓存 (最多 %s)。
+节点 ID 冲突时本机节点优先。`, subscribe.UpstreamCacheTTL, subscribe.UpstreamStaleLimit)
+}
+
+func runSubscribeUpstreamAdd(rawURL, label string) {
+	up, err := store.AddUpstream(label, rawURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "添加失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("已添加 upstream %s\n", up.Label)
+	nodes, err := subscribe.CheckUpstream(up)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 现在拉取失败: %v (订阅服务会持续重试)\n", err)
+		return
+	}
+	fmt.Printf("  拉到 %d 个节点\n", len(nodes))
+}
+
+func runSubscribeUpstreamList() {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(s.Subscribe.Upstreams) == 0 {
+		fmt.Println("(没有 upstream — proxy-manager subscribe upstream add <url> 添加)")
+		return
+	}
+	fmt.Printf("%-20s %-44s %s\n", "LABEL", "URL", "STATUS")
+	for _, up := range s.Subscribe.Upstreams {
+		status := ""
+		if nodes, err := subscribe.CheckUpstream(up); err != nil {
+			status = paint("✗ "+err.Error(), false)
+		} else {
+			status = paint(fmt.Sprintf("✓ %d 个节点", len(nodes)), true)
+		}
+		fmt.Printf("%-20s %-44s %s\n", up.Label, maskUpstreamURL(up.URL), status)
+	}
+}
+
+// maskUpstreamURL 把 URL 末尾的 token 截短显示。
+func maskUpstreamURL(u string) string {
+	i := strings.LastIndex(u, "/")
+	if i < 0 {
+		return u
+	}
+	return u[:i+1] + maskToken(u[i+1:])
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Mamaaz/proxy-manager/internal/format"
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// runUser dispatches `proxy-manager user <command>`.
+//
+//	list    <node>
+//	add     <node> <name>
+//	remove  <node> <name>
+//	disable <node> <name>
+//	enable  <node> <name>
+//
+// <node> 是节点 ID 或协议名 (reality / hysteria2 / anytls / anytls-reality)。
+// 增删改只重建该节点的内核 config 并重启这一个 unit，其他用户凭据不变。
+func runUser(args []string) {
+	if len(args) == 0 {
+		fmt.Println(userHelp())
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "list", "ls":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "用法: proxy-manager user list <node>")
+			os.Exit(2)
+		}
+		runUserList(args[1])
+	case "add", "remove", "rm", "disable", "enable":
+		if len(args) < 3 {
+			fmt.Fprintf(os.Stderr, "用法: proxy-manager user %s <node> <name>\n", args[0])
+			os.Exit(2)
+		}
+		checkRoot()
+		runUserMutate(args[0], args[1], args[2])
+	case "-h", "--help", "help":
+		fmt.Println(userHelp())
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n%s\n", args[0], userHelp())
+		os.Exit(2)
+	}
+}
+
+func userHelp() string {
+	return `用法: proxy-manager user <command> <node> [name]
+
+  list    <node>         列出节点的用户 (default = 安装时生成的凭据)
+  add     <node> <name>  新增用户，生成独立 uuid / password 并打印客户端配置
+  remove  <node> <name>  删除用户，其凭据立即失效
+  disable <node> <name>  暂停用户 (凭据保留，enable 后原配置可用)
+  enable  <node> <name>
+
+<node> 可以是节点 ID，或唯一的协议名: reality / hysteria2 / anytls / anytls-reality`
+}
+
+func runUserList(ref string) {
+	s, err := store.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 nodes.json 失败: %v\n", err)
+		os.Exit(1)
+	}
+	n, err := s.FindNode(ref)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s  (%s)\n", n.Name, n.ID)
+	fmt.Printf("  %-16s %-10s %s\n", "NAME", "STATUS", "CREATED")
+	fmt.Printf("  %-16s %-10s %s\n", store.DefaultUserName, "active", n.CreatedAt.Format("2006-01-02"))
+	for _, u := range n.Users {
+		status := "active"
+		if u.Disabled {
+			status = "disabled"
+		}
+		fmt.Printf("  %-16s %-10s %s\n", u.Name, status, u.CreatedAt.Format("2006-01-02"))
+	}
+}
+
+func runUserMutate(action, ref, name string) {
+	switch action {
+	case "add":
+		n, u, err := install.AddNodeUser(ref, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "添加用户失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("已添加用户 %s 到 %s\n\n", u.Name, n.ID)
+		printUserClientConfig(n.ForUser(u))
+	case "remove", "rm":
+		if _, err := install.RemoveNodeUser(ref, name); err != nil {
+			fmt.Fprintf(os.Stderr, "删除用户失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("已删除用户 %s，其凭据已失效\n", name)
+	case "disable", "enable":
+		if _, err := install.SetNodeUserDisabled(ref, name, action == "disable"); err != nil {
+			fmt.Fprintf(os.Stderr, "操作失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("用户 %s 已%s\n", name, map[string]string{"disable": "禁用", "enable": "启用"}[action])
+	}
+}
+
+// printUserClientConfig 给新用户打印可直接发出去的客户端配置：Surge 行 +
+// 分享 URL；Surge 不支持的组合再补一行 QX。
+func printUserClientConfig(n store.Node) {
+	if err := n.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "节点参数不完整，无法生成客户端配置: %v\n", err)
+		return
+	}
+	line, err := format.ToSurge(&n)
+	if err == nil {
+		fmt.Println("Surge:")
+		fmt.Println("  " + line)
+	}
+	if share, err := format.ShareURL(&n); err == nil {
+		fmt.Println("分享链接:")
+		fmt.Println("  " + share)
+	}
+	if err != nil {
+		if line, err := format.ToQX(&n); err == nil {
+			fmt.Println("QuantumultX:")
+			fmt.Println("  " + line)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/services"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+)
+
+// Report is GET /api/v1/doctor: the same checks as `proxy-manager doctor`,
+// as data instead of colored text.
+type Report struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Nodes       []NodeStatus    `json:"nodes"`
+	Subscribe   SubscribeStatus `json:"subscribe"`
+	Schema      SchemaStatus    `json:"schema"`
+	Warnings    []string        `json:"warnings,omitempty"`
+}
+
+// NodeStatus is one protocol row.
+type NodeStatus struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Port         int        `json:"port"`
+	Unit         string     `json:"unit,omitempty"`    // external 节点没有
+	State        string     `json:"state"`             // systemctl is-active，或 disabled / external
+	Invalid      string     `json:"invalid,omitempty"` // Validate 的报错，订阅会跳过该节点
+	CertNotAfter *time.Time `json:"cert_not_after,omitempty"`
+}
+
+// SubscribeStatus describes the subscription daemon.
+type SubscribeStatus struct {
+	State        string              `json:"state"`
+	Domain       string              `json:"domain,omitempty"`
+	Port         int                 `json:"port,omitempty"`
+	CertNotAfter *time.Time          `json:"cert_not_after,omitempty"`
+	LastSync     subscribe.SyncState `json:"last_sync,omitempty"`
+}
+
+// SchemaStatus mirrors store.SchemaStatus with JSON names.
+type SchemaStatus struct {
+	OnDisk  int      `json:"on_disk"`
+	Current int      `json:"current"`
+	Pending []string `json:"pending,omitempty"`
+}
+
+// nodeCertPaths 是自签 / ACME 证书落在本机的协议 (Reality 没有证书)。
+var nodeCertPaths = map[store.NodeType]string{
+	store.TypeHysteria2: install.Hysteria2CertPath,
+	store.TypeAnyTLS:    install.AnyTLSCertPath,
+}
+
+// Doctor collects the report. Read-only, like the CLI doctor.
+func Doctor(now time.Time) Report {
+	rep := Report{GeneratedAt: now.UTC(), Nodes: []NodeStatus{}}
+	s, err := store.Load()
+	if err != nil {
+		rep.Warnings = append(rep.Warnings, "读取 nodes.json 失败: "+err.Error())
+		s = &store.Store{}
+	}
+
+	invalid := map[string]error{}
+	for _, e := range s.Invalid {
+		invalid[e.ID] = e.Err
+	}
+	nodes := append([]store.Node{}, s.Nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	for _, n := range nodes {
+		row := NodeStatus{ID: n.ID, Name: n.Name, Type: string(n.Type), Port: n.Port}
+		if !n.External {
+			if svc, ok := services.ForType(n.Type); ok {
+				row.Unit = svc.SystemdName
+			}
+			if p := nodeCertPaths[n.Type]; p != "" {
+				if t, err := certNotAfter(p); err == nil {
+					row.CertNotAfter = &t
+				}
+			}
+		}
+		switch {
+		case n.Disabled:
+			row.State = "disabled"
+		case n.External:
+			row.State = "external"
+		default:
+			row.State = isActive(row.Unit)
+		}
+		if err := invalid[n.ID]; err != nil {
+			row.Invalid = err.Error()
+			rep.Warnings = append(rep.Warnings, n.ID+": "+err.Error())
+		}
+		rep.Nodes = append(rep.Nodes, row)
+	}
+
+	rep.Subscribe = SubscribeStatus{State: subscribe.Status(), Domain: s.Subscribe.Domain, Port: s.Subscribe.Port}
+	if rep.Subscribe.State == "" {
+		rep.Subscribe.State = "inactive"
+	}
+	if s.Subscribe.Domain != "" {
+		if t, err := subscribe.CertExpiry(s.Subscribe.Domain); err == nil {
+			rep.Subscribe.CertNotAfter = &t
+		}
+	}
+	if st, err := subscribe.LoadSyncState(); err == nil {
+		rep.Subscribe.LastSync = st
+		if warn := subscribe.PreviousTokenWarning(s.Subscribe, st, now); warn != "" {
+			rep.Warnings = append(rep.Warnings, warn)
+		}
+	}
+
+	if st, err := store.ReadSchemaStatus(); err == nil {
+		rep.Schema = SchemaStatus{OnDisk: st.OnDisk, Current: st.Current, Pending: st.Pending}
+		if len(st.Pending) > 0 {
+			rep.Warnings = append(rep.Warnings, "nodes.json schema 待升级: "+strings.Join(st.Pending, "; "))
+		}
+	}
+	return rep
+}
+
+func isActive(unit string) string {
+	if unit == "" {
+		return "unknown"
+	}
+	out, _ := exec.Command("systemctl", "is-active", unit).Output()
+	if state := strings.TrimSpace(string(out)); state != "" {
+		return state
+	}
+	return "unknown"
+}
+
+func certNotAfter(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return time.Time{}, errors.New("no certificate")
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return cert.NotAfter, nil
+		}
+	}
+}
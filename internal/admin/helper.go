@@ -0,0 +1,234 @@
+// Package admin is the root side of the /api/v1 admin API.
+//
+// 订阅 daemon 以 proxy-manager 用户跑，停 / 起 systemd unit、写快照和审计
+// 日志都做不了。所以 /api/v1 分两半：
+//
+//   - daemon (subscribe/api.go) 负责公网这一侧：TLS、admin token / mTLS
+//     认证、rate limit，只读的节点列表自己答；
+//   - 其余请求原样转给这里的 root helper (`proxy-manager admin-helper`，
+//     独立 systemd unit)，它只监听 Unix socket，socket 属组 proxy-manager
+//     0660，别的本机用户连不上。
+//
+// helper 不再做认证：能连上 socket (subscribe.AdminSocketPath) 的只有 root
+// 和 daemon。daemon 在 subscribe.AdminCallerHeader 里带上是谁认证进来的，
+// helper 记进审计日志。
+//
+// Endpoints (helper 侧)
+//
+//	POST /api/v1/subscribe/rotate-token   主 token 轮换 (旧 token 进宽限期)
+//	POST /api/v1/tokens/{label}/rotate    labelled token 轮换 (旧 URL 立即失效)
+//	POST /api/v1/nodes/{id}/disable       下线维护 (同 node disable)
+//	POST /api/v1/nodes/{id}/enable
+//	GET  /api/v1/doctor                   doctor 的结构化结果
+//	POST /api/v1/kernels/check            查各内核当前 / 最新版本
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/audit"
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+)
+
+// Handler returns the helper's routes.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/subscribe/rotate-token", rotateMainToken)
+	mux.HandleFunc("POST /api/v1/tokens/{label}/rotate", rotateAccessToken)
+	mux.HandleFunc("POST /api/v1/nodes/{id}/disable", setNodeDisabled(true))
+	mux.HandleFunc("POST /api/v1/nodes/{id}/enable", setNodeDisabled(false))
+	mux.HandleFunc("GET /api/v1/doctor", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Doctor(time.Now()))
+	})
+	mux.HandleFunc("POST /api/v1/kernels/check", checkKernels)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	})
+	return mux
+}
+
+func rotateMainToken(w http.ResponseWriter, r *http.Request) {
+	token, err := store.RotateToken()
+	record(r, "api.subscribe.rotate-token", "", err)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s, err := store.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token":                     token,
+		"previous_token_expires_at": s.Subscribe.PreviousTokenExpiresAt,
+	})
+}
+
+func rotateAccessToken(w http.ResponseWriter, r *http.Request) {
+	label := r.PathValue("label")
+	s, err := store.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if _, ok := s.Subscribe.FindAccessToken(label); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("token %s 不存在", label))
+		return
+	}
+	t, err := store.RotateAccessToken(label)
+	record(r, "api.token.rotate", label, err)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func setNodeDisabled(disabled bool) http.HandlerFunc {
+	action := "api.node.enable"
+	if disabled {
+		action = "api.node.disable"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := store.Load()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		// 只认完整 ID：API 调用方拿的是 GET /nodes 的结果，不需要 CLI 的类型别名
+		n, err := s.FindNode(r.PathValue("id"))
+		if err != nil || n.ID != r.PathValue("id") {
+			writeError(w, http.StatusNotFound, fmt.Errorf("节点 %s 不存在", r.PathValue("id")))
+			return
+		}
+		updated, err := install.SetNodeDisabled(n.ID, disabled)
+		record(r, action, n.ID, err)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated.ClientCopy())
+	}
+}
+
+// KernelStatus is one row of POST /api/v1/kernels/check.
+type KernelStatus struct {
+	Name             string   `json:"name"`
+	Current          string   `json:"current"`
+	Latest           string   `json:"latest"`
+	UpgradeAvailable bool     `json:"upgrade_available"`
+	UsedBy           []string `json:"used_by"`
+}
+
+func checkKernels(w http.ResponseWriter, r *http.Request) {
+	out := []KernelStatus{}
+	for _, k := range install.ListKernels() {
+		cur, latest := k.CurrentVersion(), k.LatestVersion()
+		out = append(out, KernelStatus{
+			Name:    k.Name,
+			Current: cur,
+			Latest:  latest,
+			// 和 kernel list 一样按去掉 v 前缀的字符串比
+			UpgradeAvailable: cur != "" && latest != "" && strings.TrimPrefix(cur, "v") != strings.TrimPrefix(latest, "v"),
+			UsedBy:           k.UsedBy,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// record writes an audit entry for a privileged API call. store 落盘时自己
+// 也会记字段变化；这一条说明是谁通过 API 发起的。
+func record(r *http.Request, action, node string, err error) {
+	caller := r.Header.Get(subscribe.AdminCallerHeader)
+	if caller == "" {
+		caller = "unknown"
+	}
+	audit.Record(audit.Entry{Command: "api " + r.Method + " " + r.URL.Path, User: "api:" + caller, Action: action, Node: node}, err)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// Serve runs the helper on socketPath until SIGINT/SIGTERM.
+func Serve(socketPath string) error {
+	if os.Geteuid() != 0 {
+		return errors.New("admin helper 必须以 root 运行")
+	}
+	l, err := listenSocket(socketPath)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: Handler(), ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("admin helper: listening on %s", socketPath)
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err = <-errCh:
+	case sig := <-stop:
+		log.Printf("admin helper: received %s, shutting down", sig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	return err
+}
+
+// listenSocket creates the socket as root:proxy-manager 0660 inside a 0750
+// directory of the same group, so only root and the daemon can connect.
+func listenSocket(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("创建 %s 失败: %w", dir, err)
+	}
+	if err := os.Chmod(dir, 0750); err != nil { // MkdirAll 不改已存在目录的权限
+		return nil, err
+	}
+	_ = os.Remove(path) // 上次异常退出留下的
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range []string{dir, path} {
+		if out, err := exec.Command("chown", "root:"+subscribe.ServiceUser, p).CombinedOutput(); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("chown %s 失败: %v (%s)", p, err, strings.TrimSpace(string(out)))
+		}
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
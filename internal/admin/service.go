@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// ServiceName is the systemd unit of the root helper. 和订阅 daemon 分开：
+// daemon 以 proxy-manager 用户跑，helper 必须是 root。
+const ServiceName = "proxy-manager-admin"
+
+// SystemdUnitPath sits next to the subscribe unit (subscribe.SystemdUnitPath).
+const SystemdUnitPath = "/lib/systemd/system/proxy-manager-admin.service"
+
+// Install writes and starts the helper unit. 幂等：已经装过就重写 unit 并重启
+// (binary 路径可能变了)。
+func Install() error {
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("找不到当前可执行文件路径: %w", err)
+	}
+	unit := fmt.Sprintf(`[Unit]
+Description=Proxy Manager admin API helper
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s admin-helper
+Restart=always
+RestartSec=10s
+
+# 只监听 /run/proxy-manager/admin.sock，不碰网络；以 root 跑是因为要
+# start/stop 协议 unit、写 nodes.json 快照和审计日志。
+PrivateTmp=true
+ProtectHome=true
+
+[Install]
+WantedBy=multi-user.target
+`, binary)
+
+	if err := os.WriteFile(SystemdUnitPath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("写入 systemd 单元失败: %w", err)
+	}
+	if err := utils.DaemonReload(); err != nil {
+		return err
+	}
+	if err := utils.ServiceEnable(ServiceName); err != nil {
+		return fmt.Errorf("enable 服务失败: %w", err)
+	}
+	if err := utils.ServiceRestart(ServiceName); err != nil {
+		return fmt.Errorf("启动服务失败: %w", err)
+	}
+	if !utils.VerifyServiceStarted(ServiceName, 10) {
+		return fmt.Errorf("服务启动验证失败 (检查 journalctl -u %s)", ServiceName)
+	}
+	return nil
+}
+
+// Uninstall stops the helper and removes its unit. Missing unit is not an
+// error.
+func Uninstall() error {
+	_ = utils.ServiceStop(ServiceName)
+	_ = utils.ServiceDisable(ServiceName)
+	if err := os.Remove(SystemdUnitPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return utils.DaemonReload()
+}
+
+// Status is systemctl is-active for the helper unit.
+func Status() string {
+	out, _ := exec.Command("systemctl", "is-active", ServiceName).Output()
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,156 @@
+// Package audit appends one JSON line per configuration change to
+// /var/log/proxy-manager/audit.log, so "who rotated that token, and when"
+// can be answered after the fact.
+//
+// 写入方是 store (nodes.json 每次改动的字段变化，被拒绝 / 没写成的尝试记
+// failed) 和 install (内核升级、卸载、EditReality 这类不全落在 nodes.json
+// 里的操作)。只记字段名不记值——
+// 大部分字段本身就是凭据。
+//
+// 写失败不影响主流程：审计是旁路，非 root 跑的只读命令本来就写不了。
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogPath is the audit log. Append-only; nothing in this tool rewrites it.
+const LogPath = "/var/log/proxy-manager/audit.log"
+
+// logPath 是实际读写的文件；测试里换成临时目录。
+var logPath = LogPath
+
+// 结果取值
+const (
+	OutcomeOK     = "ok"
+	OutcomeFailed = "failed"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`          // CLI 调用，如 "subscribe rotate-token"
+	User    string    `json:"user"`             // SUDO_USER，直接 root 登录时是 root
+	Action  string    `json:"action"`           // 哪一层做了什么，如 "store.update" / "kernel.upgrade"
+	Node    string    `json:"node,omitempty"`   // 节点 ID；subscribe 配置变化为空
+	Fields  []string  `json:"fields,omitempty"` // 变化的字段名 (永远不含值)
+	Detail  string    `json:"detail,omitempty"` // 非字段类的补充，如内核版本号
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Record appends e, filling Time / Command / User / Outcome when unset.
+// err non-nil marks the entry failed.
+func Record(e Entry, err error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Command == "" {
+		e.Command = Command()
+	}
+	if e.User == "" {
+		e.User = invokingUser()
+	}
+	if err != nil {
+		e.Outcome, e.Error = OutcomeFailed, err.Error()
+	} else if e.Outcome == "" {
+		e.Outcome = OutcomeOK
+	}
+	line, mErr := json.Marshal(e)
+	if mErr != nil {
+		return
+	}
+	_ = appendLine(line)
+}
+
+func appendLine(line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0750); err != nil {
+		return err
+	}
+	// O_APPEND 单次 write 一整行：多个进程同时写也不会交错。
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Command renders the current CLI invocation for the log: the leading
+// subcommand words, without flags or their values (which may be secrets,
+// e.g. `edit reality --field uuid --value ...`).
+func Command() string {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		return "menu"
+	}
+	if args[0] == "--action" && len(args) > 1 {
+		return "menu " + args[1] // TUI 子进程
+	}
+	var words []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") {
+			break
+		}
+		words = append(words, a)
+	}
+	if len(words) == 0 {
+		return "menu"
+	}
+	return strings.Join(words, " ")
+}
+
+func invokingUser() string {
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return u
+	}
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return fmt.Sprintf("uid:%d", os.Getuid())
+}
+
+// Filter selects entries for Read. Zero values match everything.
+type Filter struct {
+	Since time.Time
+	Node  string // 节点 ID 前缀，"hysteria2" 匹配 hysteria2-1.2.3.4
+}
+
+// Read returns the entries matching f, oldest first. A missing log is an
+// empty result, not an error. Unparseable lines are skipped.
+func Read(f Filter) ([]Entry, error) {
+	file, err := os.Open(logPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []Entry
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			continue
+		}
+		if f.Node != "" && !strings.HasPrefix(e.Node, f.Node) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
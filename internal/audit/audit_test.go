@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordLineShape(t *testing.T) {
+	defer func(old string) { logPath = old }(logPath)
+	logPath = filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("SUDO_USER", "alice")
+
+	at := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	Record(Entry{Time: at, Command: "edit reality", Action: "node.update", Node: "vless-reality-1.2.3.4",
+		Fields: []string{"params.short_id"}}, nil)
+	Record(Entry{Time: at.Add(time.Minute), Command: "subscribe token add", Action: "store.update"},
+		errors.New("token friend 已存在"))
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want one JSON object per line, got:\n%s", data)
+	}
+	var first map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("line 1 is not JSON: %v", err)
+	}
+	want := map[string]any{
+		"time": "2026-05-01T12:00:00Z", "command": "edit reality", "user": "alice",
+		"action": "node.update", "node": "vless-reality-1.2.3.4",
+		"fields": []any{"params.short_id"}, "outcome": OutcomeOK,
+	}
+	if len(first) != len(want) {
+		t.Errorf("line 1 keys = %v, want %v", first, want)
+	}
+	for k, v := range want {
+		got, _ := json.Marshal(first[k])
+		exp, _ := json.Marshal(v)
+		if string(got) != string(exp) {
+			t.Errorf("%s = %s, want %s", k, got, exp)
+		}
+	}
+
+	entries, err := Read(Filter{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Read = %+v, %v", entries, err)
+	}
+	if e := entries[1]; e.Outcome != OutcomeFailed || e.Error != "token friend 已存在" || e.Node != "" || e.Fields != nil {
+		t.Errorf("failed entry = %+v", e)
+	}
+	if got, _ := Read(Filter{Node: "vless"}); len(got) != 1 {
+		t.Errorf("node filter = %+v", got)
+	}
+	if got, _ := Read(Filter{Since: at.Add(30 * time.Second)}); len(got) != 1 || got[0].Action != "store.update" {
+		t.Errorf("since filter = %+v", got)
+	}
+}
+
+func TestCommandDropsFlags(t *testing.T) {
+	defer func(old []string) { os.Args = old }(os.Args)
+	os.Args = []string{"proxy-manager", "edit", "reality", "--field", "uuid", "--value", "secret-uuid"}
+	if got := Command(); got != "edit reality" {
+		t.Errorf("Command() = %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+// Package backup bundles everything needed to move a proxy-manager server to
+// a new VPS into one tar.gz, and restores it on the other side with the same
+// UUIDs / passwords / keys so clients only need a subscription refresh.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+	"github.com/Mamaaz/proxy-manager/internal/version"
+)
+
+// ManifestName 是包内的元数据文件，不对应任何系统路径。
+const ManifestName = "proxy-manager-backup.json"
+
+// Manifest records where and when a backup was taken.
+type Manifest struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	Hostname  string    `json:"hostname"`
+	ServerIP  string    `json:"server_ip,omitempty"`
+	Version   string    `json:"version"`
+	Nodes     int       `json:"nodes"`
+}
+
+const manifestFormat = 1
+
+// Paths 是备份覆盖的系统路径：nodes.json (含 store encrypt 的 key)、各协议内核 config
+// 目录 (Hysteria2 / AnyTLS 的证书也在里面)、subscribe 的 autocert 缓存、
+// Cloudflare token。同时也是 restore 的白名单——包里不在这些路径下的
+// 条目一律拒绝解压。
+func Paths() []string {
+	paths := []string{store.StorePath, store.MasterKeyPath, store.SubscribeKeyPath}
+	// legacy .txt 新机器上早已导入删除，留在白名单里是为了还能 restore 老备份
+	paths = append(paths, store.LegacyPaths...)
+	return append(paths,
+		install.RealityConfigDir,
+		install.Hysteria2ConfigDir,
+		install.AnyTLSConfigDir,
+		install.AnyTLSRealityConfigDir,
+		subscribe.CertCacheDir,
+		install.CloudflareTokenPath,
+	)
+}
+
+// Create writes a backup of every existing path in Paths() to out. The file
+// contains private keys and credentials and is created 0600.
+func Create(out string) (Manifest, error) {
+	s, err := store.LoadOrMigrate()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("读取 nodes.json 失败: %w", err)
+	}
+	host, _ := os.Hostname()
+	m := Manifest{
+		Format:    manifestFormat,
+		CreatedAt: time.Now().UTC(),
+		Hostname:  host,
+		Version:   version.Version,
+		Nodes:     len(s.Nodes),
+	}
+	for _, n := range s.Nodes {
+		if !n.External {
+			m.ServerIP = n.Server
+			break
+		}
+	}
+
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	mdata, _ := json.MarshalIndent(m, "", "  ")
+	if err := tw.WriteHeader(&tar.Header{
+		Name: ManifestName, Mode: 0600, Size: int64(len(mdata)), ModTime: m.CreatedAt, Typeflag: tar.TypeReg,
+	}); err != nil {
+		return Manifest{}, err
+	}
+	if _, err := tw.Write(mdata); err != nil {
+		return Manifest{}, err
+	}
+
+	for _, root := range Paths() {
+		if _, err := os.Lstat(root); os.IsNotExist(err) {
+			continue
+		}
+		if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return addFile(tw, path, info)
+		}); err != nil {
+			return Manifest{}, fmt.Errorf("打包 %s 失败: %w", root, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return m, f.Close()
+}
+
+// addFile 只收目录和普通文件；符号链接 / 设备文件在这些目录里不该出现。
+func addFile(tw *tar.Writer, path string, info os.FileInfo) error {
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		return nil
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = strings.TrimPrefix(path, "/")
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	// uid/gid 在新机器上没有意义，restore 时按服务用户重新 chown
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,332 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/install"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/subscribe"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// RestoreOptions controls Restore.
+type RestoreOptions struct {
+	// ServerIP 是新机器的公网 IP；空则 utils.GetServerIP 自动探测。
+	ServerIP string
+}
+
+// RestoreResult summarises what Restore did, for the CLI to print.
+type RestoreResult struct {
+	Manifest Manifest
+	OldIP    string
+	NewIP    string
+	Restored []string          // 成功恢复的节点 ID (新 ID)
+	Failed   map[string]string // 节点 ID → 错误
+	Domains  []string          // 需要把 DNS 改指向新 IP 的域名
+}
+
+// Restore unpacks a backup made by Create onto this machine:
+//
+//  1. 整包读进内存：白名单 (= Paths()) 以外的条目、解析不了或节点校验不过
+//     的 nodes.json 都直接拒绝，这时本机一个文件都还没动
+//  2. 其余文件先解到临时目录，全部写成功再挪到原路径
+//  3. nodes.json 里所有节点的 Server 改成新 IP；ID / Name 里嵌的旧 IP 一并
+//     替换，labelled token 的节点范围跟着改，避免后续 edit 按新 IP 算出
+//     另一个 ID 变成重复节点
+//  4. 每个节点走 install.ReinstateNode：内核、系统用户、证书、config、unit
+//  5. 订阅服务已配置时重建 unit 并启动 (autocert 缓存已恢复，不用重签)
+//
+// 凭据全部沿用，客户端刷新订阅拿到新 IP 即可。
+func Restore(file string, opts RestoreOptions) (*RestoreResult, error) {
+	a, err := readArchive(file)
+	if err != nil {
+		return nil, err
+	}
+	backed, err := parseStore(a.storeData)
+	if err != nil {
+		return nil, err
+	}
+	res := &RestoreResult{Manifest: a.manifest, Failed: map[string]string{}}
+
+	newIP := opts.ServerIP
+	if newIP == "" {
+		if newIP, _, err = utils.GetServerIP(); err != nil {
+			return res, fmt.Errorf("探测本机公网 IP 失败 (可用 --ip 指定): %w", err)
+		}
+	}
+	res.NewIP = newIP
+	for _, n := range backed.Nodes {
+		if !n.External {
+			res.OldIP = n.Server
+			break
+		}
+	}
+	rewriteServerIP(backed, newIP)
+	if err := checkStore(a, newIP); err != nil {
+		return res, err
+	}
+
+	if err := a.install(); err != nil {
+		return res, err
+	}
+	// 走 Update 而不是直接解压覆盖：本机原有的 nodes.json 先进快照。
+	// backed 里的密文 (store encrypt 过的) 原样写回，key 文件已随备份解压。
+	if err := store.Update(func(s *store.Store) error {
+		*s = *backed
+		return nil
+	}); err != nil {
+		return res, fmt.Errorf("改写 nodes.json 失败: %w", err)
+	}
+	// 老备份里带着 .txt：LoadOrMigrate 把其中 nodes.json 缺的字段补进来再删掉
+	restored, err := store.LoadOrMigrate()
+	if err != nil {
+		return res, fmt.Errorf("读取恢复后的 nodes.json 失败: %w", err)
+	}
+
+	for _, n := range restored.Nodes {
+		if n.External {
+			continue // 外部节点跟着 nodes.json 走，本机不用装
+		}
+		if err := install.ReinstateNode(n); err != nil {
+			res.Failed[n.ID] = err.Error()
+			continue
+		}
+		res.Restored = append(res.Restored, n.ID)
+		if d, ok := n.Params["domain"].(string); ok && d != "" {
+			res.Domains = append(res.Domains, d)
+		}
+	}
+
+	s, err := store.Load()
+	if err != nil {
+		return res, err
+	}
+	if s.Subscribe.Domain != "" {
+		res.Domains = append(res.Domains, s.Subscribe.Domain)
+		if err := subscribe.Rebuild(); err != nil {
+			res.Failed[subscribe.ServiceName] = err.Error()
+		}
+	}
+	return res, nil
+}
+
+func parseStore(data []byte) (*store.Store, error) {
+	var s store.Store
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("备份里的 nodes.json 损坏: %w", err)
+	}
+	store.Migrate(&s)
+	return &s, nil
+}
+
+// checkStore validates the store as it will look after LoadOrMigrate on this
+// machine: 老备份的 .txt 补齐字段、换成新 IP 之后，本机节点都要能渲染。
+// 外部节点不装，坏了订阅里跳过即可，不挡 restore。密文字段 Validate 只
+// 看非空，不用解密。
+func checkStore(a *archive, newIP string) error {
+	s, err := parseStore(a.storeData)
+	if err != nil {
+		return err
+	}
+	legacy := map[string][]byte{}
+	for _, e := range a.entries {
+		legacy[e.path] = e.data
+	}
+	store.ImportLegacyData(s, legacy)
+	rewriteServerIP(s, newIP)
+	var bad []string
+	for _, n := range s.Nodes {
+		if n.External {
+			continue
+		}
+		if err := n.Validate(); err != nil {
+			bad = append(bad, n.ID+": "+err.Error())
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("备份里的节点无效，未做任何改动:\n  %s", strings.Join(bad, "\n  "))
+	}
+	return nil
+}
+
+// rewriteServerIP 把本机节点迁到新 IP (外部节点不动)。只替换 ID / Name 里确实嵌着旧 IP 的部分
+// (`<type>-<ip>` / `<Name>@<ip>`)，用户自己改过的名字不动。
+func rewriteServerIP(s *store.Store, newIP string) {
+	renamed := map[string]string{}
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		old := n.Server
+		if n.External || old == "" || old == newIP {
+			continue
+		}
+		if strings.HasSuffix(n.ID, "-"+old) {
+			newID := strings.TrimSuffix(n.ID, old) + newIP
+			renamed[n.ID] = newID
+			n.ID = newID
+		}
+		if strings.HasSuffix(n.Name, "@"+old) {
+			n.Name = strings.TrimSuffix(n.Name, old) + newIP
+		}
+		n.Server = newIP
+	}
+	for i := range s.Subscribe.Tokens {
+		for j, id := range s.Subscribe.Tokens[i].Nodes {
+			if newID, ok := renamed[id]; ok {
+				s.Subscribe.Tokens[i].Nodes[j] = newID
+			}
+		}
+	}
+}
+
+type archiveEntry struct {
+	path string // 清理过的绝对路径
+	dir  bool
+	mode os.FileMode
+	data []byte
+}
+
+// archive is a backup read fully into memory. nodes.json 单独拿出来，经
+// store.Update 写入而不是直接覆盖。
+type archive struct {
+	manifest  Manifest
+	storeData []byte
+	entries   []archiveEntry
+}
+
+// readArchive 读取并校验整个备份，不写盘：不会解到一半才发现非法条目。
+func readArchive(file string) (*archive, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("不是 gzip 文件: %w", err)
+	}
+	a := &archive{}
+	m := &a.manifest
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取备份失败: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == ManifestName {
+			if err := json.Unmarshal(data, m); err != nil {
+				return nil, fmt.Errorf("manifest 损坏: %w", err)
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		if !allowedPath("/" + hdr.Name) {
+			return nil, fmt.Errorf("备份包含非法路径: %s", hdr.Name)
+		}
+		path := filepath.Clean("/" + hdr.Name)
+		if path == store.StorePath {
+			a.storeData = data
+			continue
+		}
+		a.entries = append(a.entries, archiveEntry{
+			path: path,
+			dir:  hdr.Typeflag == tar.TypeDir,
+			mode: os.FileMode(hdr.Mode).Perm(),
+			data: data,
+		})
+	}
+	if m.Format == 0 {
+		return nil, fmt.Errorf("%s 不是 proxy-manager 备份 (缺少 %s)", file, ManifestName)
+	}
+	if m.Format > manifestFormat {
+		return nil, fmt.Errorf("备份格式 v%d 比当前 binary 新，请先 proxy-manager update", m.Format)
+	}
+	if a.storeData == nil {
+		return nil, fmt.Errorf("备份里没有 nodes.json")
+	}
+	return a, nil
+}
+
+// install writes the entries to their paths: 先全部写进临时目录，写盘出错
+// (磁盘满之类) 时原路径一个没动；再逐个 rename 过去。
+func (a *archive) install() error {
+	stage, err := os.MkdirTemp("", "proxy-manager-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+	for _, e := range a.entries {
+		if e.dir {
+			continue
+		}
+		tmp := filepath.Join(stage, e.path)
+		if err := os.MkdirAll(filepath.Dir(tmp), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(tmp, e.data, e.mode); err != nil {
+			return fmt.Errorf("解压 %s 失败: %w", e.path, err)
+		}
+	}
+	for _, e := range a.entries {
+		if e.dir {
+			if err := os.MkdirAll(e.path, e.mode); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(e.path), 0755); err != nil {
+			return err
+		}
+		if err := moveFile(filepath.Join(stage, e.path), e.path, e.mode); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", e.path, err)
+		}
+	}
+	return nil
+}
+
+// moveFile renames src to dst. 临时目录和目标不在同一个文件系统
+// (/tmp 是 tmpfs) 时 rename 会失败，改成先写到 dst 旁边再 rename，
+// 目标文件仍然是原子替换。
+func moveFile(src, dst string, mode os.FileMode) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".restore-tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func allowedPath(p string) bool {
+	p = filepath.Clean(p)
+	for _, root := range Paths() {
+		if p == root || strings.HasPrefix(p, root+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestAllowedPath(t *testing.T) {
+	for p, ok := range map[string]bool{
+		"/etc/proxy-manager/nodes.json":        true,
+		"/etc/proxy-manager/master.key":        true,
+		"/etc/hysteria2/config.yaml":           true,
+		"/etc/hysteria2":                       true,
+		"/var/lib/proxy-manager/autocert/x":    true,
+		"/etc/reality-proxy-config.txt":        true,
+		"/etc/passwd":                          false,
+		"/etc/hysteria2/../passwd":             false,
+		"/etc/hysteria2/../../root/.ssh/keys":  false,
+		"/../../etc/shadow":                    false,
+		"/etc/hysteria2-evil/config.yaml":      false, // 前缀相同但不是子目录
+		"/var/lib/proxy-manager/sync.json":     false,
+		"/etc/proxy-manager/nodes.json/../x":   false,
+		"/etc/proxy-manager/./nodes.json":      true,
+		"/var/lib/proxy-manager/autocert/../x": false,
+	} {
+		if got := allowedPath(p); got != ok {
+			t.Errorf("allowedPath(%q) = %v, want %v", p, got, ok)
+		}
+	}
+}
+
+func TestRewriteServerIP(t *testing.T) {
+	s := &store.Store{
+		Nodes: []store.Node{
+			{ID: "hysteria2-1.2.3.4", Name: "HY2@1.2.3.4", Server: "1.2.3.4"},
+			{ID: "reality-hk", Name: "香港", Server: "1.2.3.4"},
+			{ID: "ext-9.9.9.9", Name: "ext@9.9.9.9", Server: "9.9.9.9", External: true},
+		},
+		Subscribe: store.SubscribeConfig{Tokens: []store.AccessToken{
+			{Label: "bob", Nodes: []string{"hysteria2-1.2.3.4", "ext-9.9.9.9"}},
+		}},
+	}
+	rewriteServerIP(s, "5.6.7.8")
+
+	want := []store.Node{
+		{ID: "hysteria2-5.6.7.8", Name: "HY2@5.6.7.8", Server: "5.6.7.8"},
+		{ID: "reality-hk", Name: "香港", Server: "5.6.7.8"}, // 用户改过的 ID / 名字不动
+		{ID: "ext-9.9.9.9", Name: "ext@9.9.9.9", Server: "9.9.9.9", External: true},
+	}
+	for i, n := range s.Nodes {
+		if n.ID != want[i].ID || n.Name != want[i].Name || n.Server != want[i].Server {
+			t.Errorf("node %d = %s / %s / %s, want %s / %s / %s",
+				i, n.ID, n.Name, n.Server, want[i].ID, want[i].Name, want[i].Server)
+		}
+	}
+	if got := strings.Join(s.Subscribe.Tokens[0].Nodes, ","); got != "hysteria2-5.6.7.8,ext-9.9.9.9" {
+		t.Errorf("token scope = %s", got)
+	}
+}
+
+func TestCheckStore(t *testing.T) {
+	good := `{"nodes":[{"id":"hysteria2-1.2.3.4","type":"hysteria2","server":"1.2.3.4","port":443,
+		"params":{"password":"pw","domain":"hy.example.com"}}]}`
+	if err := checkStore(&archive{storeData: []byte(good)}, "5.6.7.8"); err != nil {
+		t.Errorf("valid store rejected: %v", err)
+	}
+
+	bad := `{"nodes":[{"id":"hysteria2-1.2.3.4","type":"hysteria2","server":"1.2.3.4","port":443,
+		"params":{"domain":"hy.example.com"}}]}`
+	if err := checkStore(&archive{storeData: []byte(bad)}, "5.6.7.8"); err == nil {
+		t.Error("node without password accepted")
+	}
+	if err := checkStore(&archive{storeData: []byte("{")}, "5.6.7.8"); err == nil {
+		t.Error("corrupt nodes.json accepted")
+	}
+}
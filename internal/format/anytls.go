@@ -6,50 +6,38 @@ import (
 	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
-func anytlsToSurge(n *store.Node) string {
-	p := n.Params
-	domain := str(p, "domain")
-	if domain == "" {
-		domain = n.Server
-	}
+func anytlsToSurge(n *store.Node, p *store.AnyTLSParams) string {
+	domain, sni := p.Endpoint(n.Server)
 	return fmt.Sprintf("%s = anytls, %s, %d, password=%s, sni=%s",
-		n.Name, domain, n.Port, str(p, "password"), domain)
+		n.Name, domain, n.Port, p.Password, sni)
 }
 
-func anytlsToClash(n *store.Node) map[string]any {
-	p := n.Params
-	domain := str(p, "domain")
-	if domain == "" {
-		domain = n.Server
-	}
+func anytlsToClash(n *store.Node, p *store.AnyTLSParams) map[string]any {
+	domain, sni := p.Endpoint(n.Server)
 	return map[string]any{
 		"name":     n.Name,
 		"type":     "anytls",
 		"server":   domain,
 		"port":     n.Port,
-		"password": str(p, "password"),
-		"sni":      domain,
+		"password": p.Password,
+		"sni":      sni,
 	}
 }
 
-func anytlsToSingbox(n *store.Node) map[string]any {
-	p := n.Params
-	domain := str(p, "domain")
-	if domain == "" {
-		domain = n.Server
-	}
+func anytlsToSingbox(n *store.Node, p *store.AnyTLSParams) map[string]any {
+	domain, sni := p.Endpoint(n.Server)
 	out := map[string]any{
 		"type":        "anytls",
 		"tag":         n.ID,
 		"server":      domain,
 		"server_port": n.Port,
-		"password":    str(p, "password"),
+		"password":    p.Password,
 		"tls": map[string]any{
 			"enabled":     true,
-			"server_name": domain,
+			"server_name": sni,
 		},
 	}
-	if name := str(p, "padding_name"); name != "" {
+	if name := p.PaddingName; name != "" {
 		out["padding_scheme"] = name
 	}
 	return out
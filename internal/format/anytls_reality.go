@@ -15,49 +15,42 @@ import (
 	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
-func anytlsRealityToSurge(_ *store.Node) string {
-	// Surge 至今不支持 AnyTLS+Reality；返回注释行不出错。
-	return "# AnyTLS+Reality not supported by Surge"
-}
-
-func anytlsRealityToClash(n *store.Node) map[string]any {
-	p := n.Params
+func anytlsRealityToClash(n *store.Node, p *store.AnyTLSRealityParams) map[string]any {
 	return map[string]any{
 		"name":               n.Name,
 		"type":               "anytls",
 		"server":             n.Server,
 		"port":               n.Port,
-		"password":           str(p, "password"),
+		"password":           p.Password,
 		"udp":                true,
 		"tls":                true,
-		"servername":         str(p, "server_name"),
+		"servername":         p.ServerName,
 		"client-fingerprint": "chrome",
 		"reality-opts": map[string]any{
-			"public-key": str(p, "public_key"),
-			"short-id":   str(p, "short_id"),
+			"public-key": p.PublicKey,
+			"short-id":   p.ShortID,
 		},
 	}
 }
 
-func anytlsRealityToSingbox(n *store.Node) map[string]any {
-	p := n.Params
+func anytlsRealityToSingbox(n *store.Node, p *store.AnyTLSRealityParams) map[string]any {
 	return map[string]any{
 		"type":        "anytls",
 		"tag":         n.ID,
 		"server":      n.Server,
 		"server_port": n.Port,
-		"password":    str(p, "password"),
+		"password":    p.Password,
 		"tls": map[string]any{
 			"enabled":     true,
-			"server_name": str(p, "server_name"),
+			"server_name": p.ServerName,
 			"utls": map[string]any{
 				"enabled":     true,
 				"fingerprint": "chrome",
 			},
 			"reality": map[string]any{
 				"enabled":    true,
-				"public_key": str(p, "public_key"),
-				"short_id":   str(p, "short_id"),
+				"public_key": p.PublicKey,
+				"short_id":   p.ShortID,
 			},
 		},
 	}
@@ -67,15 +60,14 @@ func anytlsRealityToSingbox(n *store.Node) map[string]any {
 //
 //	anytls=host:port, password=, over-tls=true, tls-host=apple.com,
 //	reality-base64-pubkey=..., reality-hex-shortid=..., udp-relay=true, tag=
-func anytlsRealityToQX(n *store.Node) string {
-	p := n.Params
+func anytlsRealityToQX(n *store.Node, p *store.AnyTLSRealityParams) string {
 	parts := []string{
 		fmt.Sprintf("anytls=%s:%d", n.Server, n.Port),
-		"password=" + str(p, "password"),
+		"password=" + p.Password,
 		"over-tls=true",
-		"tls-host=" + str(p, "server_name"),
-		"reality-base64-pubkey=" + str(p, "public_key"),
-		"reality-hex-shortid=" + str(p, "short_id"),
+		"tls-host=" + p.ServerName,
+		"reality-base64-pubkey=" + p.PublicKey,
+		"reality-hex-shortid=" + p.ShortID,
 		"udp-relay=true",
 		"tag=" + n.Name,
 	}
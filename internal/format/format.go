@@ -1,14 +1,19 @@
 // Package format renders Node entries from the store into client-facing
-// configuration in different formats: Surge, Clash Meta, sing-box, and xray.
+// configuration in different formats: Surge, Clash Meta, sing-box, and xray,
+// plus whole-profile outputs (mihomo-profile, surge-profile, singbox-profile)
+// built on the same per-node generators, and the mobile clients QuantumultX,
+// Shadowrocket, Stash, Loon and Surfboard.
 //
 // Surge / Clash / sing-box cover all five protocols this tool installs.
 // xray is implemented for VLESS-Reality only (the one protocol that needs a
-// client-side bridge for Surge users).
+// client-side bridge for Surge users). The mobile clients each miss some
+// combinations; their To* return ErrUnsupportedFormat for those.
 package format
 
 import (
 	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/Mamaaz/proxy-manager/internal/store"
 )
@@ -22,45 +27,57 @@ var ErrUnknownNodeType = errors.New("unknown node type")
 
 // ToSurge returns one [Proxy] line (no trailing newline).
 func ToSurge(n *store.Node) (string, error) {
-	switch n.Type {
-	case store.TypeVLESSReality:
-		return vlessRealityToSurge(n), nil
-	case store.TypeHysteria2:
-		return hysteria2ToSurge(n), nil
-	case store.TypeAnyTLS:
-		return anytlsToSurge(n), nil
-	case store.TypeAnyTLSReality:
-		return anytlsRealityToSurge(n), nil
+	p, err := decode(n)
+	if err != nil {
+		return "", err
+	}
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return vlessRealityToSurge(n, p), nil
+	case *store.Hysteria2Params:
+		return hysteria2ToSurge(n, p), nil
+	case *store.AnyTLSParams:
+		return anytlsToSurge(n, p), nil
+	case *store.AnyTLSRealityParams:
+		return "", fmt.Errorf("%w: surge has no anytls-reality", ErrUnsupportedFormat)
 	}
 	return "", fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 }
 
 // ToClash returns a Clash Meta proxy entry as map[string]any.
 func ToClash(n *store.Node) (map[string]any, error) {
-	switch n.Type {
-	case store.TypeVLESSReality:
-		return vlessRealityToClash(n), nil
-	case store.TypeHysteria2:
-		return hysteria2ToClash(n), nil
-	case store.TypeAnyTLS:
-		return anytlsToClash(n), nil
-	case store.TypeAnyTLSReality:
-		return anytlsRealityToClash(n), nil
+	p, err := decode(n)
+	if err != nil {
+		return nil, err
+	}
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return vlessRealityToClash(n, p), nil
+	case *store.Hysteria2Params:
+		return hysteria2ToClash(n, p), nil
+	case *store.AnyTLSParams:
+		return anytlsToClash(n, p), nil
+	case *store.AnyTLSRealityParams:
+		return anytlsRealityToClash(n, p), nil
 	}
 	return nil, fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 }
 
 // ToSingbox returns one or more sing-box outbound entries for the node.
 func ToSingbox(n *store.Node) ([]map[string]any, error) {
-	switch n.Type {
-	case store.TypeVLESSReality:
-		return []map[string]any{vlessRealityToSingbox(n)}, nil
-	case store.TypeHysteria2:
-		return []map[string]any{hysteria2ToSingbox(n)}, nil
-	case store.TypeAnyTLS:
-		return []map[string]any{anytlsToSingbox(n)}, nil
-	case store.TypeAnyTLSReality:
-		return []map[string]any{anytlsRealityToSingbox(n)}, nil
+	p, err := decode(n)
+	if err != nil {
+		return nil, err
+	}
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return []map[string]any{vlessRealityToSingbox(n, p)}, nil
+	case *store.Hysteria2Params:
+		return []map[string]any{hysteria2ToSingbox(n, p)}, nil
+	case *store.AnyTLSParams:
+		return []map[string]any{anytlsToSingbox(n, p)}, nil
+	case *store.AnyTLSRealityParams:
+		return []map[string]any{anytlsRealityToSingbox(n, p)}, nil
 	}
 	return nil, fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 }
@@ -69,10 +86,14 @@ func ToSingbox(n *store.Node) ([]map[string]any, error) {
 // since xray is the canonical Reality client and the bridge is only needed
 // for that one protocol.
 func ToXray(n *store.Node) ([]map[string]any, error) {
-	if n.Type == store.TypeVLESSReality {
-		return []map[string]any{vlessRealityToXray(n)}, nil
+	if n.Type != store.TypeVLESSReality {
+		return nil, fmt.Errorf("%w: xray only renders vless-reality (use surge/sing-box for %q)", ErrUnsupportedFormat, n.Type)
+	}
+	p, err := decode(n)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("%w: xray only renders vless-reality (use surge/sing-box for %q)", ErrUnsupportedFormat, n.Type)
+	return []map[string]any{vlessRealityToXray(n, p.(*store.RealityParams))}, nil
 }
 
 // ToQX returns one QuantumultX server_local 配置行 (no trailing newline)。
@@ -81,57 +102,128 @@ func ToXray(n *store.Node) ([]map[string]any, error) {
 // 至今不支持)，所以 QX format 对 iOS Reality 用户是必需的。每协议字段名跟
 // Surge / Clash / sing-box 都不同，单独实现。
 func ToQX(n *store.Node) (string, error) {
-	switch n.Type {
-	case store.TypeVLESSReality:
-		return vlessRealityToQX(n), nil
-	case store.TypeHysteria2:
-		return hysteria2ToQX(n), nil
-	case store.TypeAnyTLS:
-		return anytlsToQX(n), nil
-	case store.TypeAnyTLSReality:
-		return anytlsRealityToQX(n), nil
+	p, err := decode(n)
+	if err != nil {
+		return "", err
+	}
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return vlessRealityToQX(n, p), nil
+	case *store.Hysteria2Params:
+		return hysteria2ToQX(n, p), nil
+	case *store.AnyTLSParams:
+		return anytlsToQX(n, p), nil
+	case *store.AnyTLSRealityParams:
+		return anytlsRealityToQX(n, p), nil
 	}
 	return "", fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 }
 
-// NeedsBridge reports whether a node must be reached via a local proxy bridge
-// (xray) to be usable by Surge. Currently only VLESS-Reality.
-func NeedsBridge(n *store.Node) bool {
-	return n.Type == store.TypeVLESSReality
+// ToShadowrocket returns the node's share URI in the form Shadowrocket
+// imports; see shadowrocket.go for what it can't express.
+func ToShadowrocket(n *store.Node) (string, error) {
+	p, err := decode(n)
+	if err != nil {
+		return "", err
+	}
+	u, err := shadowrocketURL(n, p)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// ToStash returns a Stash proxy entry (Clash-style YAML map, Stash field
+// names).
+func ToStash(n *store.Node) (map[string]any, error) {
+	p, err := decode(n)
+	if err != nil {
+		return nil, err
+	}
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return vlessRealityToStash(n, p), nil
+	case *store.Hysteria2Params:
+		return hysteria2ToStash(n, p)
+	case *store.AnyTLSParams:
+		return anytlsToStash(n, p), nil
+	case *store.AnyTLSRealityParams:
+		return nil, fmt.Errorf("%w: stash has no anytls-reality", ErrUnsupportedFormat)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 }
 
-// --- typed param accessors -------------------------------------------------
-// JSON numbers always decode to float64. These helpers normalize back to the
-// expected Go types so callers don't sprinkle type assertions everywhere.
+// ToLoon returns one Loon [Proxy] line (no trailing newline).
+func ToLoon(n *store.Node) (string, error) {
+	p, err := decode(n)
+	if err != nil {
+		return "", err
+	}
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return vlessRealityToLoon(n, p), nil
+	case *store.Hysteria2Params:
+		return hysteria2ToLoon(n, p), nil
+	case *store.AnyTLSParams:
+		return anytlsToLoon(n, p), nil
+	case *store.AnyTLSRealityParams:
+		return "", fmt.Errorf("%w: loon has no anytls-reality", ErrUnsupportedFormat)
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
+}
+
+// ToSurfboard returns one Surfboard [Proxy] line. Only Hysteria2 without
+// obfs is expressible.
+func ToSurfboard(n *store.Node) (string, error) {
+	p, err := decode(n)
+	if err != nil {
+		return "", err
+	}
+	switch p := p.(type) {
+	case *store.Hysteria2Params:
+		return hysteria2ToSurfboard(n, p)
+	case *store.RealityParams, *store.AnyTLSParams, *store.AnyTLSRealityParams:
+		return "", fmt.Errorf("%w: surfboard only renders hysteria2 (no %q)", ErrUnsupportedFormat, n.Type)
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
+}
 
-func str(p map[string]any, key string) string {
-	if v, ok := p[key]; ok {
-		if s, ok := v.(string); ok {
-			return s
-		}
+// ShareURL returns the node's single-line share URI (vless:// /
+// hysteria2:// / anytls://): scan or paste it into v2rayN, NekoBox,
+// Shadowrocket, Hiddify... to import one node without a subscription.
+func ShareURL(n *store.Node) (string, error) {
+	p, err := decode(n)
+	if err != nil {
+		return "", err
+	}
+	var u url.URL
+	switch p := p.(type) {
+	case *store.RealityParams:
+		u = vlessRealityShareURL(n, p)
+	case *store.Hysteria2Params:
+		u = hysteria2ShareURL(n, p)
+	case *store.AnyTLSParams:
+		u = anytlsShareURL(n, p)
+	case *store.AnyTLSRealityParams:
+		u = anytlsRealityShareURL(n, p)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 	}
-	return ""
+	return u.String(), nil
 }
 
-func num(p map[string]any, key string) int {
-	if v, ok := p[key]; ok {
-		switch x := v.(type) {
-		case int:
-			return x
-		case int64:
-			return int(x)
-		case float64:
-			return int(x)
-		}
-	}
-	return 0
+// NeedsBridge reports whether a node must be reached via a local proxy bridge
+// (xray) to be usable by Surge. Currently only VLESS-Reality.
+func NeedsBridge(n *store.Node) bool {
+	return n.Type == store.TypeVLESSReality
 }
 
-func boolean(p map[string]any, key string) bool {
-	if v, ok := p[key]; ok {
-		if b, ok := v.(bool); ok {
-			return b
-		}
+// decode returns the node's typed, validated params. Incomplete nodes fail
+// here (wrapping store.ErrInvalidParams) instead of rendering empty fields.
+func decode(n *store.Node) (store.Params, error) {
+	switch n.Type {
+	case store.TypeVLESSReality, store.TypeHysteria2, store.TypeAnyTLS, store.TypeAnyTLSReality:
+		return n.DecodeParams()
 	}
-	return false
+	return nil, fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
 }
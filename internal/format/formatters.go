@@ -0,0 +1,237 @@
+package format
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// 内置格式。注册顺序就是 `subscribe url` 等处的展示顺序。
+func init() {
+	Register(formatter{
+		name: "surge", contentType: contentTypeText,
+		supports: converts(ToSurge),
+		render:   renderLines(ToSurge),
+	})
+	Register(formatter{
+		name: "surge-profile", contentType: contentTypeText,
+		supports: converts(ToSurge),
+		render:   renderSurgeProfile,
+	})
+	Register(formatter{
+		// Mihomo (formerly Clash.Meta) is the active fork; it accepts standard
+		// Clash YAML/JSON. Same output — alias for discoverability.
+		name: "clash", aliases: []string{"mihomo"}, contentType: contentTypeJSON,
+		supports: converts(ToClash),
+		render: func(w io.Writer, nodes []store.Node, _ Options) error {
+			proxies := make([]map[string]any, 0, len(nodes))
+			for i := range nodes {
+				if entry, err := ToClash(&nodes[i]); err == nil {
+					proxies = append(proxies, entry)
+				}
+			}
+			return writeJSON(w, map[string]any{"proxies": proxies})
+		},
+	})
+	Register(formatter{
+		// 完整 Mihomo 配置 (策略组 / 规则 / DNS) 和只含 proxies 的
+		// proxy-providers 版本，见 mihomo.go。
+		name: "mihomo-profile", aliases: []string{"clash-profile"}, contentType: contentTypeYAML,
+		supports: converts(ToClash),
+		render: func(w io.Writer, nodes []store.Node, _ Options) error {
+			return writeYAML(w, mihomoProfile(nodes))
+		},
+	})
+	Register(formatter{
+		name: "mihomo-provider", aliases: []string{"clash-provider"}, contentType: contentTypeYAML,
+		supports: converts(ToClash),
+		render: func(w io.Writer, nodes []store.Node, _ Options) error {
+			proxies, _ := mihomoProxies(nodes)
+			return writeYAML(w, yamlMap{{"proxies", proxies}})
+		},
+	})
+	Register(formatter{
+		name: "singbox", aliases: []string{"sing-box"}, contentType: contentTypeJSON,
+		supports: converts(ToSingbox),
+		render:   renderOutbounds(ToSingbox),
+	})
+	Register(formatter{
+		name: "singbox-profile", aliases: []string{"sing-box-profile"}, contentType: contentTypeJSON,
+		supports: converts(ToSingbox),
+		render:   renderSingboxProfile,
+	})
+	Register(formatter{
+		name: "xray", contentType: contentTypeJSON,
+		supports: bridgeable,
+		render:   renderOutbounds(ToXray),
+	})
+	Register(formatter{
+		name: "xray-bridge", contentType: contentTypeJSON,
+		supports: bridgeable,
+		render:   renderXrayBridge,
+	})
+	Register(formatter{
+		name: "qx", aliases: []string{"quantumultx"}, contentType: contentTypeText,
+		supports: converts(ToQX),
+		render:   renderLines(ToQX),
+	})
+	Register(formatter{
+		name: "shadowrocket", contentType: contentTypeText,
+		supports: converts(ToShadowrocket),
+		render:   renderBase64Lines(ToShadowrocket),
+	})
+	Register(formatter{
+		name: "stash", contentType: contentTypeYAML,
+		supports: converts(ToStash),
+		render: func(w io.Writer, nodes []store.Node, _ Options) error {
+			return writeYAML(w, stashProfile(nodes))
+		},
+	})
+	Register(formatter{
+		name: "loon", contentType: contentTypeText,
+		supports: converts(ToLoon),
+		render:   renderLines(ToLoon),
+	})
+	Register(formatter{
+		name: "surfboard", contentType: contentTypeText,
+		supports: converts(ToSurfboard),
+		render:   surfboardProfile.render,
+	})
+	Register(formatter{
+		// 分享 URI 一行一个再整体 base64：v2rayN / NekoBox / Shadowrocket /
+		// Hiddify 的「订阅」就是这个格式。
+		name: "uri", aliases: []string{"base64", "v2rayn"}, contentType: contentTypeText,
+		supports: converts(ShareURL),
+		render:   renderBase64Lines(ShareURL),
+	})
+	Register(formatter{
+		// 节点列表本身：XSurge 和 subscribe upstream 读的就是这个。只带
+		// version + nodes，订阅配置 (token / 上游 URL) 和 private_key 不出去。
+		name: "json", contentType: contentTypeJSON,
+		render: func(w io.Writer, nodes []store.Node, _ Options) error {
+			out := make([]store.Node, 0, len(nodes))
+			for _, n := range nodes {
+				out = append(out, n.ClientCopy())
+			}
+			return writeJSON(w, struct {
+				Version int          `json:"version"`
+				Nodes   []store.Node `json:"nodes"`
+			}{store.StoreVersion, out})
+		},
+	})
+}
+
+const (
+	contentTypeJSON = "application/json; charset=utf-8"
+	contentTypeText = "text/plain; charset=utf-8"
+	contentTypeYAML = "text/yaml; charset=utf-8"
+)
+
+// formatter is the Formatter most formats need: static metadata plus a
+// render func. supports nil = every node.
+type formatter struct {
+	name        string
+	aliases     []string
+	contentType string
+	supports    func(n *store.Node) bool
+	render      func(w io.Writer, nodes []store.Node, opts Options) error
+}
+
+func (f formatter) Name() string        { return f.name }
+func (f formatter) Aliases() []string   { return f.aliases }
+func (f formatter) ContentType() string { return f.contentType }
+
+func (f formatter) Supports(n *store.Node) bool {
+	return f.supports == nil || f.supports(n)
+}
+
+func (f formatter) Render(w io.Writer, nodes []store.Node, opts Options) error {
+	supported := make([]store.Node, 0, len(nodes))
+	for i := range nodes {
+		if f.Supports(&nodes[i]) {
+			supported = append(supported, nodes[i])
+		}
+	}
+	return f.render(w, supported, opts)
+}
+
+// converts is Supports for a per-node generator: the node is supported iff
+// the generator accepts it (Surge has no AnyTLS+Reality, for example).
+func converts[T any](gen func(*store.Node) (T, error)) func(*store.Node) bool {
+	return func(n *store.Node) bool {
+		_, err := gen(n)
+		return err == nil
+	}
+}
+
+// bridgeable is Supports for the xray formats: only what Surge needs the
+// bridge for, and only what ToXray can render.
+func bridgeable(n *store.Node) bool {
+	return NeedsBridge(n) && converts(ToXray)(n)
+}
+
+// uniqueName returns name, or "name 2", "name 3"... if an earlier node in
+// the same document already took it. Profiles reference proxies by name in
+// their groups, and merged upstream nodes can easily share one.
+func uniqueName(seen map[string]bool, name string) string {
+	unique := name
+	for k := 2; seen[unique]; k++ {
+		unique = fmt.Sprintf("%s %d", name, k)
+	}
+	seen[unique] = true
+	return unique
+}
+
+// renderLines is the body of the one-line-per-node text formats.
+func renderLines(line func(*store.Node) (string, error)) func(io.Writer, []store.Node, Options) error {
+	return func(w io.Writer, nodes []store.Node, _ Options) error {
+		for i := range nodes {
+			l, err := line(&nodes[i])
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintln(w, l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// renderBase64Lines is renderLines, base64-encoded as a whole: the
+// subscription body v2rayN-style clients expect.
+func renderBase64Lines(line func(*store.Node) (string, error)) func(io.Writer, []store.Node, Options) error {
+	return func(w io.Writer, nodes []store.Node, opts Options) error {
+		var b strings.Builder
+		if err := renderLines(line)(&b, nodes, opts); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, base64.StdEncoding.EncodeToString([]byte(b.String())))
+		return err
+	}
+}
+
+// renderOutbounds is the body of the {"outbounds": [...]} JSON formats.
+func renderOutbounds(entries func(*store.Node) ([]map[string]any, error)) func(io.Writer, []store.Node, Options) error {
+	return func(w io.Writer, nodes []store.Node, _ Options) error {
+		outbounds := make([]map[string]any, 0, len(nodes))
+		for i := range nodes {
+			e, err := entries(&nodes[i])
+			if err != nil {
+				continue
+			}
+			outbounds = append(outbounds, e...)
+		}
+		return writeJSON(w, map[string]any{"outbounds": outbounds})
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -7,63 +7,51 @@ import (
 	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
-func hysteria2ToSurge(n *store.Node) string {
-	p := n.Params
-	domain := str(p, "domain")
-	if domain == "" {
-		domain = n.Server
-	}
+func hysteria2ToSurge(n *store.Node, p *store.Hysteria2Params) string {
+	domain, sni := p.Endpoint(n.Server)
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "%s = hysteria2, %s, %d, password=%s, sni=%s",
-		n.Name, domain, n.Port, str(p, "password"), domain)
-	if boolean(p, "enable_obfs") {
-		fmt.Fprintf(&sb, ", obfs=salamander, obfs-password=%s", str(p, "obfs_password"))
+		n.Name, domain, n.Port, p.Password, sni)
+	if p.EnableObfs {
+		fmt.Fprintf(&sb, ", obfs=salamander, obfs-password=%s", p.ObfsPassword)
 	}
 	return sb.String()
 }
 
-func hysteria2ToClash(n *store.Node) map[string]any {
-	p := n.Params
-	domain := str(p, "domain")
-	if domain == "" {
-		domain = n.Server
-	}
+func hysteria2ToClash(n *store.Node, p *store.Hysteria2Params) map[string]any {
+	domain, sni := p.Endpoint(n.Server)
 	out := map[string]any{
 		"name":     n.Name,
 		"type":     "hysteria2",
 		"server":   domain,
 		"port":     n.Port,
-		"password": str(p, "password"),
-		"sni":      domain,
+		"password": p.Password,
+		"sni":      sni,
 	}
-	if boolean(p, "enable_obfs") {
+	if p.EnableObfs {
 		out["obfs"] = "salamander"
-		out["obfs-password"] = str(p, "obfs_password")
+		out["obfs-password"] = p.ObfsPassword
 	}
 	return out
 }
 
-func hysteria2ToSingbox(n *store.Node) map[string]any {
-	p := n.Params
-	domain := str(p, "domain")
-	if domain == "" {
-		domain = n.Server
-	}
+func hysteria2ToSingbox(n *store.Node, p *store.Hysteria2Params) map[string]any {
+	domain, sni := p.Endpoint(n.Server)
 	out := map[string]any{
 		"type":        "hysteria2",
 		"tag":         n.ID,
 		"server":      domain,
 		"server_port": n.Port,
-		"password":    str(p, "password"),
+		"password":    p.Password,
 		"tls": map[string]any{
 			"enabled":     true,
-			"server_name": domain,
+			"server_name": sni,
 		},
 	}
-	if boolean(p, "enable_obfs") {
+	if p.EnableObfs {
 		out["obfs"] = map[string]any{
 			"type":     "salamander",
-			"password": str(p, "obfs_password"),
+			"password": p.ObfsPassword,
 		}
 	}
 	return out
@@ -0,0 +1,52 @@
+// Loon [Proxy] 行生成。Loon 的语法是 `名字 = 协议,地址,端口,"凭据",k=v...`，
+// 逗号两边不留空格，凭据加双引号；TLS 的 SNI 叫 tls-name，Hysteria2 的
+// salamander 混淆只要一个 salamander-password。Loon 没有 AnyTLS+Reality。
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func vlessRealityToLoon(n *store.Node, p *store.RealityParams) string {
+	parts := []string{
+		fmt.Sprintf("%s = VLESS,%s,%d,%q", n.Name, n.Server, n.Port, p.UUID),
+		"transport=tcp",
+	}
+	if flow := p.Flow; flow != "" {
+		parts = append(parts, "flow="+flow)
+	}
+	parts = append(parts,
+		fmt.Sprintf("public-key=%q", p.PublicKey),
+		"short-id="+p.ShortID,
+		"udp=true",
+		"over-tls=true",
+		"sni="+p.ServerName,
+	)
+	return strings.Join(parts, ",")
+}
+
+// Loon Hysteria2: 连接地址和 tls-name 都用域名 (LE 证书绑域名)。
+func hysteria2ToLoon(n *store.Node, p *store.Hysteria2Params) string {
+	host, sni := p.Endpoint(n.Server)
+	parts := []string{
+		fmt.Sprintf("%s = Hysteria2,%s,%d,%q", n.Name, host, n.Port, p.Password),
+		"tls-name=" + sni,
+		"udp=true",
+	}
+	if p.EnableObfs {
+		parts = append(parts, "salamander-password="+p.ObfsPassword)
+	}
+	return strings.Join(parts, ",")
+}
+
+func anytlsToLoon(n *store.Node, p *store.AnyTLSParams) string {
+	host, sni := p.Endpoint(n.Server)
+	return strings.Join([]string{
+		fmt.Sprintf("%s = AnyTLS,%s,%d,%q", n.Name, host, n.Port, p.Password),
+		"tls-name=" + sni,
+		"udp=true",
+	}, ",")
+}
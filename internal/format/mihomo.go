@@ -0,0 +1,118 @@
+package format
+
+import "github.com/Mamaaz/proxy-manager/internal/store"
+
+// mihomo-profile 是一份能直接在 Mihomo (Clash.Meta) 里当配置用的完整
+// YAML：proxies + 三个策略组 + DNS + 默认规则 (局域网 / 国内直连，其余走
+// 代理)。节点一项一项都来自 ToClash，和 /s/clash 的 proxies 完全一致。
+//
+// mihomo-provider 只有 proxies，给已经有自己规则的用户在 proxy-providers
+// 里引用 (type: http, url: .../s/mihomo-provider/<token>)。
+
+// 策略组名。Proxy 是 MATCH 落到的那个，用户在客户端里手选节点也是它。
+const (
+	mihomoGroupProxy    = "Proxy"
+	mihomoGroupAuto     = "Auto"
+	mihomoGroupFallback = "Fallback"
+
+	mihomoTestURL      = "https://www.gstatic.com/generate_204"
+	mihomoTestInterval = 300
+)
+
+// mihomoProxies converts nodes with ToClash and makes names unique — Mihomo
+// refuses a profile with two proxies of the same name, which merged upstream
+// nodes can easily produce.
+func mihomoProxies(nodes []store.Node) (proxies []any, names []string) {
+	seen := map[string]bool{}
+	for i := range nodes {
+		entry, err := ToClash(&nodes[i])
+		if err != nil {
+			continue
+		}
+		name := uniqueName(seen, nodes[i].Name)
+		entry["name"] = name
+		proxies = append(proxies, entry)
+		names = append(names, name)
+	}
+	return proxies, names
+}
+
+func mihomoProfile(nodes []store.Node) yamlMap {
+	proxies, names := mihomoProxies(nodes)
+	// url-test / fallback 组不能是空的，没有节点时放一个 DIRECT 占位。
+	members := names
+	if len(members) == 0 {
+		members = []string{"DIRECT"}
+	}
+	selectable := append([]string{mihomoGroupAuto, mihomoGroupFallback}, names...)
+	selectable = append(selectable, "DIRECT")
+
+	return yamlMap{
+		{"mixed-port", 7890},
+		{"allow-lan", false},
+		{"mode", "rule"},
+		{"log-level", "info"},
+		{"unified-delay", true},
+		{"dns", mihomoDNS()},
+		{"proxies", proxies},
+		{"proxy-groups", []any{
+			yamlMap{
+				{"name", mihomoGroupProxy},
+				{"type", "select"},
+				{"proxies", selectable},
+			},
+			yamlMap{
+				{"name", mihomoGroupAuto},
+				{"type", "url-test"},
+				{"url", mihomoTestURL},
+				{"interval", mihomoTestInterval},
+				{"tolerance", 50},
+				{"proxies", members},
+			},
+			yamlMap{
+				{"name", mihomoGroupFallback},
+				{"type", "fallback"},
+				{"url", mihomoTestURL},
+				{"interval", mihomoTestInterval},
+				{"proxies", members},
+			},
+		}},
+		{"rules", mihomoRules()},
+	}
+}
+
+// mihomoDNS: fake-ip，国内 DoH 解析，境外结果 (geoip 非 CN) 换成海外 DoH。
+// 代理服务器自己的域名用 proxy-server-nameserver 解析，不绕回代理。
+func mihomoDNS() yamlMap {
+	return yamlMap{
+		{"enable", true},
+		{"ipv6", false},
+		{"enhanced-mode", "fake-ip"},
+		{"fake-ip-range", "198.18.0.1/16"},
+		{"fake-ip-filter", []string{"*.lan", "+.local", "+.msftconnecttest.com", "+.msftncsi.com", "+.pool.ntp.org", "time.*.com"}},
+		{"default-nameserver", []string{"223.5.5.5", "119.29.29.29"}},
+		{"nameserver", []string{"https://dns.alidns.com/dns-query", "https://doh.pub/dns-query"}},
+		{"proxy-server-nameserver", []string{"https://dns.alidns.com/dns-query", "https://doh.pub/dns-query"}},
+		{"fallback", []string{"https://1.1.1.1/dns-query", "https://dns.google/dns-query"}},
+		{"fallback-filter", yamlMap{
+			{"geoip", true},
+			{"geoip-code", "CN"},
+			{"ipcidr", []string{"240.0.0.0/4"}},
+		}},
+	}
+}
+
+func mihomoRules() []string {
+	return []string{
+		"DOMAIN-SUFFIX,local,DIRECT",
+		"IP-CIDR,127.0.0.0/8,DIRECT,no-resolve",
+		"IP-CIDR,10.0.0.0/8,DIRECT,no-resolve",
+		"IP-CIDR,172.16.0.0/12,DIRECT,no-resolve",
+		"IP-CIDR,192.168.0.0/16,DIRECT,no-resolve",
+		"IP-CIDR,100.64.0.0/10,DIRECT,no-resolve",
+		"IP-CIDR6,fe80::/10,DIRECT,no-resolve",
+		"GEOSITE,cn,DIRECT",
+		"GEOIP,CN,DIRECT",
+		"MATCH," + mihomoGroupProxy,
+	}
+}
@@ -0,0 +1,58 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestYAMLString(t *testing.T) {
+	for in, want := range map[string]string{
+		"Proxy":         "Proxy",
+		"1.2.3.4":       "1.2.3.4",
+		"+.local":       "+.local",
+		"https://a.b/c": "https://a.b/c",
+		"":              `""`,
+		"yes":           `"yes"`,
+		"443":           `"443"`,
+		"1e3":           `"1e3"`,
+		"*.lan":         `"*.lan"`,
+		"a: b":          `"a: b"`,
+		"香港 01":         `"香港 01"`,
+		"198.18.0.1/16": `"198.18.0.1/16"`,
+		"say \"hi\"\n":  `"say \"hi\"\n"`,
+	} {
+		if got := yamlString(in); got != want {
+			t.Errorf("yamlString(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestMihomoProfile(t *testing.T) {
+	node := store.Node{
+		ID: "hysteria2-1.2.3.4", Name: "HK", Type: store.TypeHysteria2,
+		Server: "1.2.3.4", Port: 443,
+		Params: map[string]any{"password": "p", "domain": "hk.example.com"},
+	}
+	f, ok := Lookup("mihomo-profile")
+	if !ok {
+		t.Fatal("mihomo-profile not registered")
+	}
+	var b strings.Builder
+	if err := f.Render(&b, []store.Node{node, node}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"proxies:\n  - name: HK\n    password: p\n",
+		`  - name: "HK 2"` + "\n",
+		"  - name: Auto\n    type: url-test\n",
+		"      - HK\n      - \"HK 2\"\n",
+		"  - MATCH,Proxy\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("profile missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package format
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func mobileNodes() []store.Node {
+	return []store.Node{
+		{
+			ID: "vless-reality-1.2.3.4", Name: "HK-R", Type: store.TypeVLESSReality, Server: "1.2.3.4", Port: 443,
+			Params: map[string]any{
+				"uuid": "0b3c1f3e-1111-4222-8333-944455556666", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+				"short_id": "ab", "server_name": "www.apple.com", "flow": "xtls-rprx-vision",
+			},
+		},
+		{
+			ID: "hysteria2-1.2.3.4", Name: "HK-H", Type: store.TypeHysteria2, Server: "1.2.3.4", Port: 8443,
+			Params: map[string]any{"password": "pw", "domain": "hk.example.com", "enable_obfs": true, "obfs_password": "o"},
+		},
+		{
+			ID: "anytls-reality-1.2.3.4", Name: "HK-AR", Type: store.TypeAnyTLSReality, Server: "1.2.3.4", Port: 9443,
+			Params: map[string]any{
+				"password": "p", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+				"short_id": "ab", "server_name": "www.apple.com",
+			},
+		},
+	}
+}
+
+func TestLoonLines(t *testing.T) {
+	nodes := mobileNodes()
+	for i, want := range []string{
+		`HK-R = VLESS,1.2.3.4,443,"0b3c1f3e-1111-4222-8333-944455556666",transport=tcp,flow=xtls-rprx-vision,public-key="Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",short-id=ab,udp=true,over-tls=true,sni=www.apple.com`,
+		`HK-H = Hysteria2,hk.example.com,8443,"pw",tls-name=hk.example.com,udp=true,salamander-password=o`,
+	} {
+		got, err := ToLoon(&nodes[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("loon:\n got %s\nwant %s", got, want)
+		}
+	}
+	if _, err := ToLoon(&nodes[2]); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("loon anytls-reality: err = %v", err)
+	}
+}
+
+// 客户端表达不了的组合：To* 报 ErrUnsupportedFormat，整份订阅里跳过。
+func TestMobileFormatsSkipUnsupported(t *testing.T) {
+	nodes := mobileNodes()
+	render := func(name string) string {
+		f, ok := Lookup(name)
+		if !ok {
+			t.Fatalf("%s not registered", name)
+		}
+		var b strings.Builder
+		if err := f.Render(&b, nodes, Options{}); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(render("shadowrocket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(raw)), "\n"); len(lines) != 2 {
+		t.Errorf("shadowrocket = %q", raw)
+	}
+
+	stash := render("stash")
+	if !strings.Contains(stash, "type: vless") || strings.Contains(stash, "HK-H") || strings.Contains(stash, "HK-AR") {
+		t.Errorf("stash should only carry the reality node (obfs / anytls-reality unsupported):\n%s", stash)
+	}
+	plain := nodes[1]
+	plain.Params = map[string]any{"password": "pw", "domain": "hk.example.com"}
+	if entry, err := ToStash(&plain); err != nil || entry["auth"] != "pw" {
+		t.Errorf("stash hysteria2 = %v, %v", entry, err)
+	}
+
+	surfboard := render("surfboard")
+	if strings.Contains(surfboard, "HK-") || !strings.Contains(surfboard, "Proxy = select, DIRECT\n") {
+		t.Errorf("surfboard has no node it can express:\n%s", surfboard)
+	}
+	if line, err := ToSurfboard(&plain); err != nil || line != "HK-H = hysteria2, hk.example.com, 8443, password=pw, sni=hk.example.com" {
+		t.Errorf("surfboard hysteria2 = %q, %v", line, err)
+	}
+}
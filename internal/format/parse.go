@@ -0,0 +1,414 @@
+package format
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// 反方向：把分享链接 / Clash proxy / sing-box outbound 解析回 store.Node，
+// 给 `node import` 收编不归本工具管的节点。只认本工具会生成的四种协议，
+// 字段约定和 share.go、各协议的 To* 生成器对称 (parse_test.go 做往返测试)。
+//
+// 解析出的节点一律 External=true，ID 由 store.ExternalID 按类型 + 地址生成。
+// 生成器不认的组合 (非 Reality 的 vless、跳过证书校验的 hysteria2 / anytls)
+// 直接报错，而不是导入一个客户端连不上的节点。
+
+// ErrNotAProxy marks entries that are valid config but not a proxy node
+// (sing-box selector / direct / dns outbounds); ParseNodes skips them
+// silently.
+var ErrNotAProxy = errors.New("not a proxy outbound")
+
+// ParseShareURL parses a vless:// (Reality), hysteria2:// / hy2:// or
+// anytls:// share URI.
+func ParseShareURL(raw string) (store.Node, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return store.Node{}, fmt.Errorf("分享链接解析失败: %w", err)
+	}
+	if u.User == nil || u.Hostname() == "" {
+		return store.Node{}, fmt.Errorf("分享链接缺少凭据或地址: %s://...", u.Scheme)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return store.Node{}, fmt.Errorf("分享链接端口不对: %q", u.Port())
+	}
+	q := u.Query()
+	secret := u.User.Username()
+	if pw, ok := u.User.Password(); ok {
+		secret += ":" + pw // hysteria2 的 userpass 认证写成 user:pass
+	}
+	n := store.Node{Name: u.Fragment, Server: u.Hostname(), Port: port}
+
+	switch strings.ToLower(u.Scheme) {
+	case "vless":
+		if sec := q.Get("security"); sec != "reality" {
+			return store.Node{}, fmt.Errorf("只支持 Reality 的 vless 链接 (security=%s)", sec)
+		}
+		if t := q.Get("type"); t != "" && t != "tcp" {
+			return store.Node{}, fmt.Errorf("只支持 tcp 传输的 vless 链接 (type=%s)", t)
+		}
+		n.Type = store.TypeVLESSReality
+		n.Params = realityParams(secret, q.Get("pbk"), q.Get("sid"), q.Get("sni"), q.Get("flow"))
+	case "hysteria2", "hy2":
+		if err := checkInsecure(q.Get("insecure") == "1"); err != nil {
+			return store.Node{}, err
+		}
+		n.Type = store.TypeHysteria2
+		n.Params, err = hysteria2Params(secret, firstNonEmpty(q.Get("sni"), n.Server), q.Get("obfs"), q.Get("obfs-password"))
+	case "anytls":
+		if q.Get("security") == "reality" {
+			n.Type = store.TypeAnyTLSReality
+			n.Params = anytlsRealityParams(secret, q.Get("pbk"), q.Get("sid"), q.Get("sni"))
+			break
+		}
+		if err := checkInsecure(q.Get("insecure") == "1"); err != nil {
+			return store.Node{}, err
+		}
+		n.Type = store.TypeAnyTLS
+		n.Params = map[string]any{"password": secret, "sni": firstNonEmpty(q.Get("sni"), n.Server)}
+	default:
+		return store.Node{}, fmt.Errorf("不支持的分享链接: %s:// (支持 vless / hysteria2 / hy2 / anytls)", u.Scheme)
+	}
+	if err != nil {
+		return store.Node{}, err
+	}
+	return finishExternal(n)
+}
+
+// ParseClash parses one Clash Meta / mihomo proxy entry (ToClash's shape).
+func ParseClash(m map[string]any) (store.Node, error) {
+	n := store.Node{Name: mapStr(m, "name"), Server: mapStr(m, "server"), Port: mapInt(m, "port")}
+	typ := mapStr(m, "type")
+	reality := mapSub(m, "reality-opts")
+	if mapBool(m, "skip-cert-verify") && reality == nil {
+		return store.Node{}, fmt.Errorf("%s: %w", n.Name, checkInsecure(true))
+	}
+	var err error
+	switch typ {
+	case "vless":
+		if reality == nil {
+			return store.Node{}, fmt.Errorf("%s: 只支持 Reality 的 vless (缺少 reality-opts)", n.Name)
+		}
+		if nw := mapStr(m, "network"); nw != "" && nw != "tcp" {
+			return store.Node{}, fmt.Errorf("%s: 只支持 tcp 传输的 vless (network=%s)", n.Name, nw)
+		}
+		n.Type = store.TypeVLESSReality
+		n.Params = realityParams(mapStr(m, "uuid"), mapStr(reality, "public-key"), mapStr(reality, "short-id"), mapStr(m, "servername"), mapStr(m, "flow"))
+	case "hysteria2":
+		n.Type = store.TypeHysteria2
+		n.Params, err = hysteria2Params(mapStr(m, "password"), firstNonEmpty(mapStr(m, "sni"), n.Server), mapStr(m, "obfs"), mapStr(m, "obfs-password"))
+	case "anytls":
+		if reality != nil {
+			n.Type = store.TypeAnyTLSReality
+			n.Params = anytlsRealityParams(mapStr(m, "password"), mapStr(reality, "public-key"), mapStr(reality, "short-id"), mapStr(m, "servername"))
+			break
+		}
+		n.Type = store.TypeAnyTLS
+		n.Params = map[string]any{"password": mapStr(m, "password"), "sni": firstNonEmpty(mapStr(m, "sni"), n.Server)}
+	default:
+		return store.Node{}, fmt.Errorf("%s: 不支持的协议 %q", n.Name, typ)
+	}
+	if err != nil {
+		return store.Node{}, fmt.Errorf("%s: %w", n.Name, err)
+	}
+	return finishExternal(n)
+}
+
+// ParseSingbox parses one sing-box outbound (ToSingbox's shape). The tag
+// becomes the node name. Non-proxy outbounds return ErrNotAProxy.
+func ParseSingbox(m map[string]any) (store.Node, error) {
+	n := store.Node{Name: mapStr(m, "tag"), Server: mapStr(m, "server"), Port: mapInt(m, "server_port")}
+	typ := mapStr(m, "type")
+	tls := mapSub(m, "tls")
+	reality := mapSub(tls, "reality")
+	if reality != nil && !mapBool(reality, "enabled") {
+		reality = nil
+	}
+	if mapBool(tls, "insecure") {
+		return store.Node{}, fmt.Errorf("%s: %w", n.Name, checkInsecure(true))
+	}
+	var err error
+	switch typ {
+	case "vless":
+		if reality == nil {
+			return store.Node{}, fmt.Errorf("%s: 只支持 Reality 的 vless (缺少 tls.reality)", n.Name)
+		}
+		if tr := mapSub(m, "transport"); tr != nil {
+			return store.Node{}, fmt.Errorf("%s: 只支持 tcp 传输的 vless (transport=%s)", n.Name, mapStr(tr, "type"))
+		}
+		n.Type = store.TypeVLESSReality
+		n.Params = realityParams(mapStr(m, "uuid"), mapStr(reality, "public_key"), mapStr(reality, "short_id"), mapStr(tls, "server_name"), mapStr(m, "flow"))
+	case "hysteria2":
+		obfs := mapSub(m, "obfs")
+		n.Type = store.TypeHysteria2
+		n.Params, err = hysteria2Params(mapStr(m, "password"), firstNonEmpty(mapStr(tls, "server_name"), n.Server), mapStr(obfs, "type"), mapStr(obfs, "password"))
+	case "anytls":
+		if reality != nil {
+			n.Type = store.TypeAnyTLSReality
+			n.Params = anytlsRealityParams(mapStr(m, "password"), mapStr(reality, "public_key"), mapStr(reality, "short_id"), mapStr(tls, "server_name"))
+			break
+		}
+		n.Type = store.TypeAnyTLS
+		n.Params = map[string]any{"password": mapStr(m, "password"), "sni": firstNonEmpty(mapStr(tls, "server_name"), n.Server)}
+		if name := mapStr(m, "padding_scheme"); name != "" {
+			n.Params["padding_name"] = name
+		}
+	case "selector", "urltest", "direct", "block", "dns":
+		return store.Node{}, ErrNotAProxy
+	default:
+		return store.Node{}, fmt.Errorf("%s: 不支持的协议 %q", n.Name, typ)
+	}
+	if err != nil {
+		return store.Node{}, fmt.Errorf("%s: %w", n.Name, err)
+	}
+	return finishExternal(n)
+}
+
+// ParseNodes detects what data is and parses every node in it:
+//
+//   - 分享链接，一行一个 (也接受整段 base64，即 uri 格式的订阅)
+//   - sing-box 配置 ({"outbounds": [...]}) 或 outbound 数组 / 单个 outbound
+//   - Clash 配置 / proxy-provider ({proxies: [...]})，YAML 或 JSON
+//   - 本工具的 json 订阅 ({"nodes": [...]})
+//
+// Entries that fail are reported in errs and the rest still returned.
+func ParseNodes(data []byte) (nodes []store.Node, errs []error) {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(data) == 0 {
+		return nil, []error{errors.New("输入为空")}
+	}
+	if !bytes.Contains(data, []byte("://")) {
+		if decoded, ok := decodeBase64(data); ok && bytes.Contains(decoded, []byte("://")) {
+			data = decoded
+		}
+	}
+	if isShareURLList(data) {
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			n, err := ParseShareURL(line)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("第 %d 行: %w", i+1, err))
+				continue
+			}
+			nodes = append(nodes, n)
+		}
+		return nodes, errs
+	}
+
+	var doc any
+	if data[0] == '{' || data[0] == '[' {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			// flow 风格的 YAML 也以 { [ 开头
+			if doc, err = parseYAML(data); err != nil {
+				return nil, []error{fmt.Errorf("既不是 JSON 也不是 YAML: %w", err)}
+			}
+		}
+	} else {
+		var err error
+		if doc, err = parseYAML(data); err != nil {
+			return nil, []error{err}
+		}
+	}
+
+	var entries []any
+	switch d := doc.(type) {
+	case map[string]any:
+		switch {
+		case d["proxies"] != nil:
+			entries, _ = d["proxies"].([]any)
+		case d["outbounds"] != nil:
+			entries, _ = d["outbounds"].([]any)
+		case d["nodes"] != nil:
+			return parseStoreNodes(d["nodes"])
+		default:
+			entries = []any{d}
+		}
+	case []any:
+		entries = d
+	}
+	if len(entries) == 0 {
+		return nil, []error{errors.New("没有找到节点 (需要分享链接、Clash proxies 或 sing-box outbounds)")}
+	}
+	for i, e := range entries {
+		m, ok := e.(map[string]any)
+		if !ok {
+			errs = append(errs, fmt.Errorf("第 %d 项不是 proxy / outbound 对象", i+1))
+			continue
+		}
+		var n store.Node
+		var err error
+		if _, singbox := m["server_port"]; singbox || m["tag"] != nil {
+			n, err = ParseSingbox(m)
+		} else {
+			n, err = ParseClash(m)
+		}
+		if errors.Is(err, ErrNotAProxy) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, errs
+}
+
+// parseStoreNodes takes the nodes of another proxy-manager's json
+// subscription as they are, just re-keyed as external.
+func parseStoreNodes(v any) (nodes []store.Node, errs []error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var in []store.Node
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return nil, []error{fmt.Errorf("nodes 解析失败: %w", err)}
+	}
+	for _, n := range in {
+		out, err := finishExternal(store.Node{Name: n.Name, Type: n.Type, Server: n.Server, Port: n.Port, Params: n.Params})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		nodes = append(nodes, out)
+	}
+	return nodes, errs
+}
+
+// finishExternal fills ID / default name, marks the node external and
+// validates it with the same rules the generators use.
+func finishExternal(n store.Node) (store.Node, error) {
+	if n.Server == "" || n.Port < 1 || n.Port > 65535 {
+		return store.Node{}, fmt.Errorf("%s: 地址或端口缺失 (%s:%d)", n.Name, n.Server, n.Port)
+	}
+	n.External = true
+	n.ID = store.ExternalID(n.Type, n.Server, n.Port)
+	if n.Name == "" {
+		n.Name = fmt.Sprintf("%s@%s", n.Type, n.Server)
+	}
+	if err := n.Validate(); err != nil {
+		return store.Node{}, fmt.Errorf("%s: %w", n.Name, err)
+	}
+	return n, nil
+}
+
+func realityParams(uuid, publicKey, shortID, serverName, flow string) map[string]any {
+	p := map[string]any{"uuid": uuid, "public_key": publicKey, "short_id": shortID, "server_name": serverName}
+	if flow != "" {
+		p["flow"] = flow
+	}
+	return p
+}
+
+// hysteria2Params 把对方的 SNI 存成 sni 而不是 domain (anytls 同理)：domain
+// 在生成器里是连接地址，导入的节点要连的是 Node.Server。
+func hysteria2Params(password, sni, obfs, obfsPassword string) (map[string]any, error) {
+	p := map[string]any{"password": password, "sni": sni}
+	switch obfs {
+	case "":
+	case "salamander":
+		p["enable_obfs"] = true
+		p["obfs_password"] = obfsPassword
+	default:
+		return nil, fmt.Errorf("不支持的 hysteria2 obfs: %s", obfs)
+	}
+	return p, nil
+}
+
+func anytlsRealityParams(password, publicKey, shortID, serverName string) map[string]any {
+	return map[string]any{"password": password, "public_key": publicKey, "short_id": shortID, "server_name": serverName}
+}
+
+func checkInsecure(insecure bool) error {
+	if insecure {
+		return errors.New("节点跳过了证书校验 (insecure / skip-cert-verify)，生成的客户端配置会连不上，不导入")
+	}
+	return nil
+}
+
+func isShareURLList(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		scheme, _, ok := strings.Cut(line, "://")
+		return ok && !strings.ContainsAny(scheme, " \t:{[\"'")
+	}
+	return false
+}
+
+// decodeBase64 accepts std / url alphabets with or without padding, and
+// line-wrapped input.
+func decodeBase64(data []byte) ([]byte, bool) {
+	s := strings.Join(strings.Fields(string(data)), "")
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if out, err := enc.DecodeString(s); err == nil {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// mapStr / mapInt / mapBool / mapSub read loosely typed config values: JSON
+// gives float64 ports, YAML gives int, some subscriptions quote everything.
+
+func mapStr(m map[string]any, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func mapInt(m map[string]any, key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+func mapBool(m map[string]any, key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	}
+	return false
+}
+
+func mapSub(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
@@ -0,0 +1,217 @@
+package format
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func roundTripNodes() []store.Node {
+	return []store.Node{
+		{
+			ID: "vless-reality-1.2.3.4", Name: "HK Reality", Type: store.TypeVLESSReality, Server: "1.2.3.4", Port: 443,
+			Params: map[string]any{
+				"uuid": "0b3c1f3e-1111-4222-8333-944455556666", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+				"short_id": "ab", "server_name": "www.apple.com", "flow": "xtls-rprx-vision",
+			},
+		},
+		{
+			ID: "hysteria2-1.2.3.4", Name: "HK #2", Type: store.TypeHysteria2, Server: "1.2.3.4", Port: 8443,
+			Params: map[string]any{"password": "p@ss:w/rd", "domain": "hk.example.com", "enable_obfs": true, "obfs_password": "o"},
+		},
+		{
+			ID: "anytls-1.2.3.4", Name: "AnyTLS@1.2.3.4", Type: store.TypeAnyTLS, Server: "1.2.3.4", Port: 8444,
+			Params: map[string]any{"password": "p", "domain": "a.example.com", "padding_name": "默认"},
+		},
+		{
+			ID: "anytls-reality-2001:db8::1", Name: "AR", Type: store.TypeAnyTLSReality, Server: "2001:db8::1", Port: 443,
+			Params: map[string]any{
+				"password": "p", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+				"short_id": "ab", "server_name": "www.apple.com",
+			},
+		},
+	}
+}
+
+// 生成 → 解析 → 再生成，两次输出必须一样：生成器和解析器对字段的理解一致。
+func TestParseRoundTrip(t *testing.T) {
+	for _, orig := range roundTripNodes() {
+		orig := orig
+		if share, err := ShareURL(&orig); err == nil {
+			n, err := ParseShareURL(share)
+			if err != nil {
+				t.Fatalf("%s: ParseShareURL: %v", orig.Type, err)
+			}
+			if !n.External || !strings.HasPrefix(n.ID, store.ExternalIDPrefix) {
+				t.Errorf("%s: not marked external: %+v", orig.Type, n)
+			}
+			if again, _ := ShareURL(&n); again != share {
+				t.Errorf("%s share:\n got %s\nwant %s", orig.Type, again, share)
+			}
+		}
+
+		want, err := ToClash(&orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := ParseClash(want)
+		if err != nil {
+			t.Fatalf("%s: ParseClash: %v", orig.Type, err)
+		}
+		if got, _ := ToClash(&n); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s clash:\n got %v\nwant %v", orig.Type, got, want)
+		}
+
+		outs, err := ToSingbox(&orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err = ParseSingbox(outs[0])
+		if err != nil {
+			t.Fatalf("%s: ParseSingbox: %v", orig.Type, err)
+		}
+		again, _ := ToSingbox(&n)
+		delete(outs[0], "tag") // tag 是节点 ID，导入后换成了 ext- ID
+		delete(again[0], "tag")
+		if !reflect.DeepEqual(again[0], outs[0]) {
+			t.Errorf("%s singbox:\n got %v\nwant %v", orig.Type, again[0], outs[0])
+		}
+	}
+}
+
+// 导入的节点连接地址和 SNI 不同 (IP + CDN 域名之类)：连接地址必须留在
+// Server，导出时不能被 SNI 顶掉。
+func TestParseRoundTripHostNotSNI(t *testing.T) {
+	for _, share := range []string{
+		"hysteria2://pw@1.2.3.4:443/?sni=cdn.example.com#hy2",
+		"anytls://pw@1.2.3.4:443/?sni=cdn.example.com#anytls",
+	} {
+		n, err := ParseShareURL(share)
+		if err != nil {
+			t.Fatalf("%s: %v", share, err)
+		}
+		if n.Server != "1.2.3.4" {
+			t.Errorf("%s: server = %q, want 1.2.3.4", share, n.Server)
+		}
+		if again, _ := ShareURL(&n); again != share {
+			t.Errorf("share:\n got %s\nwant %s", again, share)
+		}
+
+		clash, err := ToClash(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clash["server"] != "1.2.3.4" || clash["sni"] != "cdn.example.com" {
+			t.Errorf("%s clash: server=%v sni=%v", n.Type, clash["server"], clash["sni"])
+		}
+		c, err := ParseClash(clash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := ToClash(&c); !reflect.DeepEqual(got, clash) {
+			t.Errorf("%s clash:\n got %v\nwant %v", n.Type, got, clash)
+		}
+
+		outs, err := ToSingbox(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tls, _ := outs[0]["tls"].(map[string]any)
+		if outs[0]["server"] != "1.2.3.4" || tls["server_name"] != "cdn.example.com" {
+			t.Errorf("%s singbox: server=%v server_name=%v", n.Type, outs[0]["server"], tls["server_name"])
+		}
+		sb, err := ParseSingbox(outs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sb.Server != "1.2.3.4" {
+			t.Errorf("%s singbox import: server = %q", n.Type, sb.Server)
+		}
+
+		surge, err := ToSurge(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(surge, ", 1.2.3.4, 443,") || !strings.Contains(surge, "sni=cdn.example.com") {
+			t.Errorf("%s surge: %s", n.Type, surge)
+		}
+	}
+}
+
+// 整份订阅 / 配置：每种自己的输出格式都能原样导回来。
+func TestParseNodesFormats(t *testing.T) {
+	nodes := roundTripNodes()
+	for _, name := range []string{"uri", "mihomo-provider", "mihomo-profile", "singbox", "singbox-profile", "json"} {
+		f, _ := Lookup(name)
+		var b strings.Builder
+		if err := f.Render(&b, nodes, Options{}); err != nil {
+			t.Fatal(err)
+		}
+		got, errs := ParseNodes([]byte(b.String()))
+		if len(errs) > 0 {
+			t.Fatalf("%s: %v", name, errs)
+		}
+		if len(got) != len(nodes) {
+			t.Fatalf("%s: parsed %d nodes, want %d", name, len(got), len(nodes))
+		}
+		for i := range got {
+			if got[i].Type != nodes[i].Type || got[i].Port != nodes[i].Port {
+				t.Errorf("%s #%d: %s:%d, want %s:%d", name, i, got[i].Type, got[i].Port, nodes[i].Type, nodes[i].Port)
+			}
+		}
+	}
+}
+
+func TestParseNodesRejects(t *testing.T) {
+	in := strings.Join([]string{
+		"# comment",
+		"vless://0b3c1f3e-1111-4222-8333-944455556666@1.2.3.4:443?security=tls&sni=a.example.com#tls",
+		"hy2://pw@h.example.com:443/?insecure=1#insecure",
+		"hy2://pw@h.example.com:443/?sni=h.example.com#ok",
+		"ss://YWVzLTI1Ni1nY206cGFzcw@1.2.3.4:8388#ss",
+	}, "\n")
+	nodes, errs := ParseNodes([]byte(in))
+	if len(nodes) != 1 || nodes[0].Name != "ok" || nodes[0].Type != store.TypeHysteria2 {
+		t.Errorf("nodes = %+v", nodes)
+	}
+	if len(errs) != 3 {
+		t.Errorf("errs = %v", errs)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	doc := `
+# provider
+proxies:
+- {name: "HK, 1", type: anytls, server: a.example.com, port: 443, password: 'it''s'}
+- name: JP   # trailing comment
+  type: hysteria2
+  server: jp.example.com
+  port: "8443"
+  password: "p#1"
+  alpn:
+    - h3
+  note: |
+    line one
+    line two
+rules: [DOMAIN-SUFFIX,cn,DIRECT]
+`
+	v, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(v)
+	want := `{"proxies":[{"name":"HK, 1","password":"it's","port":443,"server":"a.example.com","type":"anytls"},` +
+		`{"alpn":["h3"],"name":"JP","note":"line one\nline two","password":"p#1","port":"8443","server":"jp.example.com","type":"hysteria2"}],` +
+		`"rules":["DOMAIN-SUFFIX","cn","DIRECT"]}`
+	if string(got) != want {
+		t.Errorf("parseYAML:\n got %s\nwant %s", got, want)
+	}
+
+	if _, err := parseYAML([]byte("a:\n  b: 1\n c: 2\n")); err == nil {
+		t.Error("bad indent accepted")
+	}
+}
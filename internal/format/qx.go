@@ -18,18 +18,17 @@ import (
 	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
-func vlessRealityToQX(n *store.Node) string {
-	p := n.Params
+func vlessRealityToQX(n *store.Node, p *store.RealityParams) string {
 	parts := []string{
 		fmt.Sprintf("vless=%s:%d", n.Server, n.Port),
 		"method=none",
-		"password=" + str(p, "uuid"),
+		"password=" + p.UUID,
 		"obfs=over-tls",
-		"obfs-host=" + str(p, "server_name"),
-		"reality-base64-pubkey=" + str(p, "public_key"),
-		"reality-hex-shortid=" + str(p, "short_id"),
+		"obfs-host=" + p.ServerName,
+		"reality-base64-pubkey=" + p.PublicKey,
+		"reality-hex-shortid=" + p.ShortID,
 	}
-	if flow := str(p, "flow"); flow != "" {
+	if flow := p.Flow; flow != "" {
 		parts = append(parts, "vless-flow="+flow)
 	}
 	parts = append(parts,
@@ -42,18 +41,14 @@ func vlessRealityToQX(n *store.Node) string {
 
 // QX Hysteria2: hysteria2= 块。连接 host 用域名 (LE 证书绑域名)，
 // 用 IP 连会触发证书校验失败。obfs (salamander) 是可选 server-side feature。
-func hysteria2ToQX(n *store.Node) string {
-	p := n.Params
-	host := str(p, "domain")
-	if host == "" {
-		host = n.Server
-	}
+func hysteria2ToQX(n *store.Node, p *store.Hysteria2Params) string {
+	host, sni := p.Endpoint(n.Server)
 	parts := []string{
 		fmt.Sprintf("hysteria2=%s:%d", host, n.Port),
-		"password=" + str(p, "password"),
-		"sni=" + host,
+		"password=" + p.Password,
+		"sni=" + sni,
 	}
-	if obfsPw := str(p, "obfs_password"); obfsPw != "" {
+	if obfsPw := p.ObfsPassword; obfsPw != "" {
 		parts = append(parts,
 			"obfs=salamander",
 			"obfs-password="+obfsPw,
@@ -64,16 +59,12 @@ func hysteria2ToQX(n *store.Node) string {
 }
 
 // QX AnyTLS: 2024 年 QX 加的协议。连接 host 用域名 (LE 证书)。
-func anytlsToQX(n *store.Node) string {
-	p := n.Params
-	host := str(p, "domain")
-	if host == "" {
-		host = n.Server
-	}
+func anytlsToQX(n *store.Node, p *store.AnyTLSParams) string {
+	host, sni := p.Endpoint(n.Server)
 	parts := []string{
 		fmt.Sprintf("anytls=%s:%d", host, n.Port),
-		"password=" + str(p, "password"),
-		"sni=" + host,
+		"password=" + p.Password,
+		"sni=" + sni,
 		"fast-open=true",
 		"udp-relay=true",
 		"tag=" + n.Name,
@@ -0,0 +1,86 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// Formatter renders a whole node list as one client-facing document. export
+// --format, /s/{format}/{token}, `subscribe url` and the TUI config viewer
+// all go through the registry below, so a new output format is one Formatter
+// plus one Register call.
+//
+// Render gets nodes that already passed store.SplitValid; a node the format
+// can't express (Supports false, e.g. xray for Hysteria2) is left out
+// silently rather than failing the whole document.
+type Formatter interface {
+	Name() string
+	Aliases() []string
+	ContentType() string
+	Supports(n *store.Node) bool
+	Render(w io.Writer, nodes []store.Node, opts Options) error
+}
+
+// Options is what a render knows about where the document is going. Zero
+// value is fine for every format.
+type Options struct {
+	// URL the document is served from (subscription URL incl. token and
+	// query). Profiles that update themselves point back at it (Surge
+	// #!MANAGED-CONFIG); empty = not served, e.g. a one-off export.
+	URL string
+	// Params are per-format knobs: the request's query string, or
+	// `export --param k=v`. Formats ignore keys they don't use.
+	Params url.Values
+}
+
+var registry []Formatter
+
+// Register adds f to the registry. Built-in formats register from init;
+// names and aliases must be unique across formats.
+func Register(f Formatter) {
+	for _, name := range append([]string{f.Name()}, f.Aliases()...) {
+		if _, dup := Lookup(name); dup {
+			panic(fmt.Sprintf("format: %q registered twice", name))
+		}
+	}
+	registry = append(registry, f)
+}
+
+// Lookup finds a formatter by name or alias.
+func Lookup(name string) (Formatter, bool) {
+	for _, f := range registry {
+		if f.Name() == name {
+			return f, true
+		}
+		for _, a := range f.Aliases() {
+			if a == name {
+				return f, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// All returns every registered formatter in registration order.
+func All() []Formatter {
+	return append([]Formatter(nil), registry...)
+}
+
+// Names returns the primary name of every formatter, in registration order.
+func Names() []string {
+	out := make([]string, 0, len(registry))
+	for _, f := range registry {
+		out = append(out, f.Name())
+	}
+	return out
+}
+
+// UnknownFormatError builds the error for a name Lookup didn't find, listing
+// what is supported.
+func UnknownFormatError(name string) error {
+	return fmt.Errorf("未知格式: %s (支持: %s)", name, strings.Join(Names(), ", "))
+}
@@ -0,0 +1,76 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestLookup(t *testing.T) {
+	for name, want := range map[string]string{
+		"clash":         "clash",
+		"mihomo":        "clash",
+		"sing-box":      "singbox",
+		"clash-profile": "mihomo-profile",
+		"quantumultx":   "qx",
+		"base64":        "uri",
+		"v2rayn":        "uri",
+		"json":          "json",
+	} {
+		f, ok := Lookup(name)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", name)
+			continue
+		}
+		if f.Name() != want {
+			t.Errorf("Lookup(%q) = %s, want %s", name, f.Name(), want)
+		}
+	}
+	for _, name := range []string{"", "Clash", "surge ", "nope"} {
+		if _, ok := Lookup(name); ok {
+			t.Errorf("Lookup(%q) should miss", name)
+		}
+	}
+}
+
+func TestUnknownFormatError(t *testing.T) {
+	msg := UnknownFormatError("nope").Error()
+	if !strings.Contains(msg, "nope") {
+		t.Errorf("error should name the format: %s", msg)
+	}
+	for _, name := range Names() {
+		if !strings.Contains(msg, name) {
+			t.Errorf("error should list %s: %s", name, msg)
+		}
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func(old []Formatter) { registry = old }(registry)
+	defer func() {
+		if recover() == nil {
+			t.Error("registering an existing alias should panic")
+		}
+	}()
+	Register(formatter{name: "new-format", aliases: []string{"mihomo"}})
+}
+
+// json 的外形是 XSurge / upstream 的接口：只有 version + nodes。
+func TestJSONShape(t *testing.T) {
+	f, _ := Lookup("json")
+	var b bytes.Buffer
+	nodes := []store.Node{{ID: "a", Type: store.TypeHysteria2, Params: map[string]any{"password": "pw"}}}
+	if err := f.Render(&b, nodes, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(b.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out["version"] == nil || out["nodes"] == nil {
+		t.Errorf("json keys = %v, want version + nodes", b.String())
+	}
+}
@@ -0,0 +1,26 @@
+// Shadowrocket 订阅 = 分享 URI 一行一个再整体 base64，和 uri 格式同一套
+// 链接约定 (share.go)。单独成一个格式是因为支持面不同：Shadowrocket 有
+// VLESS Reality / Hysteria2 / AnyTLS，但没有 AnyTLS+Reality——uri 格式照出，
+// 这里给明确的错误，订阅里跳过。
+package format
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func shadowrocketURL(n *store.Node, p store.Params) (url.URL, error) {
+	switch p := p.(type) {
+	case *store.RealityParams:
+		return vlessRealityShareURL(n, p), nil
+	case *store.Hysteria2Params:
+		return hysteria2ShareURL(n, p), nil
+	case *store.AnyTLSParams:
+		return anytlsShareURL(n, p), nil
+	case *store.AnyTLSRealityParams:
+		return url.URL{}, fmt.Errorf("%w: shadowrocket has no anytls-reality", ErrUnsupportedFormat)
+	}
+	return url.URL{}, fmt.Errorf("%w: %q", ErrUnknownNodeType, n.Type)
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// 单节点分享 URI。各家客户端 (v2rayN / NekoBox / Shadowrocket / Hiddify)
+// 认的是同一套约定：
+//
+//	vless://<uuid>@<host>:<port>?encryption=none&type=tcp&security=reality&
+//	  pbk=<publickey>&sni=<servername>&sid=<shortid>&fp=chrome&spx=%2F#<name>
+//	  (https://github.com/XTLS/Xray-core/discussions/716)
+//	hysteria2://<password>@<host>:<port>/?sni=<sni>&obfs=salamander&obfs-password=<pw>#<name>
+//	  (https://v2.hysteria.network/docs/developers/URI-Scheme/)
+//	anytls://<password>@<host>:<port>/?sni=<sni>#<name>
+//	  Reality 版沿用 vless 的 security=reality&pbk=&sid=&fp= 参数
+//
+// 凭据放 userinfo，名字放 fragment，都由 url.URL 负责转义。
+
+func shareURL(scheme, secret, host string, port int, q url.Values, name string) url.URL {
+	return url.URL{
+		Scheme:   scheme,
+		User:     url.User(secret),
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/",
+		RawQuery: q.Encode(),
+		Fragment: name,
+	}
+}
+
+func vlessRealityShareURL(n *store.Node, p *store.RealityParams) url.URL {
+	q := url.Values{}
+	q.Set("encryption", "none")
+	if flow := p.Flow; flow != "" {
+		q.Set("flow", flow)
+	}
+	q.Set("type", "tcp")
+	q.Set("security", "reality")
+	q.Set("pbk", p.PublicKey)
+	q.Set("sni", p.ServerName)
+	q.Set("sid", p.ShortID)
+	q.Set("fp", "chrome")
+	q.Set("spx", "/")
+	u := shareURL("vless", p.UUID, n.Server, n.Port, q, n.Name)
+	u.Path = "" // vless:// 习惯上没有 path
+	return u
+}
+
+func hysteria2ShareURL(n *store.Node, p *store.Hysteria2Params) url.URL {
+	host, sni := p.Endpoint(n.Server)
+	q := url.Values{}
+	q.Set("sni", sni)
+	if p.EnableObfs {
+		q.Set("obfs", "salamander")
+		q.Set("obfs-password", p.ObfsPassword)
+	}
+	return shareURL("hysteria2", p.Password, host, n.Port, q, n.Name)
+}
+
+func anytlsShareURL(n *store.Node, p *store.AnyTLSParams) url.URL {
+	host, sni := p.Endpoint(n.Server)
+	q := url.Values{}
+	q.Set("sni", sni)
+	return shareURL("anytls", p.Password, host, n.Port, q, n.Name)
+}
+
+func anytlsRealityShareURL(n *store.Node, p *store.AnyTLSRealityParams) url.URL {
+	q := url.Values{}
+	q.Set("security", "reality")
+	q.Set("sni", p.ServerName)
+	q.Set("pbk", p.PublicKey)
+	q.Set("sid", p.ShortID)
+	q.Set("fp", "chrome")
+	return shareURL("anytls", p.Password, n.Server, n.Port, q, n.Name)
+}
@@ -0,0 +1,72 @@
+package format
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestShareURL(t *testing.T) {
+	for _, tc := range []struct {
+		node store.Node
+		want string
+	}{
+		{
+			store.Node{
+				Name: "HK Reality", Type: store.TypeVLESSReality, Server: "1.2.3.4", Port: 443,
+				Params: map[string]any{
+					"uuid": "0b3c1f3e-1111-4222-8333-944455556666", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+					"short_id": "ab", "server_name": "www.apple.com",
+				},
+			},
+			"vless://0b3c1f3e-1111-4222-8333-944455556666@1.2.3.4:443?encryption=none&fp=chrome&pbk=Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw&security=reality&sid=ab&sni=www.apple.com&spx=%2F&type=tcp#HK%20Reality",
+		},
+		{
+			store.Node{
+				Name: "HK", Type: store.TypeHysteria2, Server: "1.2.3.4", Port: 8443,
+				Params: map[string]any{"password": "p@ss", "domain": "hk.example.com", "enable_obfs": true, "obfs_password": "o"},
+			},
+			"hysteria2://p%40ss@hk.example.com:8443/?obfs=salamander&obfs-password=o&sni=hk.example.com#HK",
+		},
+		{
+			store.Node{
+				Name: "AR", Type: store.TypeAnyTLSReality, Server: "2001:db8::1", Port: 443,
+				Params: map[string]any{
+					"password": "p", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+					"short_id": "ab", "server_name": "www.apple.com",
+				},
+			},
+			"anytls://p@[2001:db8::1]:443/?fp=chrome&pbk=Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw&security=reality&sid=ab&sni=www.apple.com#AR",
+		},
+	} {
+		got, err := ShareURL(&tc.node)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.node.Type, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.node.Type, got, tc.want)
+		}
+	}
+}
+
+func TestURIFormatIsBase64Lines(t *testing.T) {
+	nodes := []store.Node{
+		{Name: "A", Type: store.TypeAnyTLS, Server: "1.2.3.4", Port: 443, Params: map[string]any{"password": "p", "domain": "a.example.com"}},
+		{Name: "B", Type: store.TypeHysteria2, Server: "1.2.3.4", Port: 443, Params: map[string]any{"password": "p", "domain": "b.example.com"}},
+	}
+	f, _ := Lookup("uri")
+	var b strings.Builder
+	if err := f.Render(&b, nodes, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(b.String())
+	if err != nil {
+		t.Fatalf("not base64: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "anytls://") || !strings.HasPrefix(lines[1], "hysteria2://") {
+		t.Errorf("decoded = %q", raw)
+	}
+}
@@ -0,0 +1,239 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// singbox-profile 是能直接 `sing-box run -c` 的客户端配置：mixed (+可选 tun)
+// 入站、selector + urltest、direct、DNS (可选 fake-ip)、国内直连的路由规则。
+// 节点 outbound 来自 ToSingbox，tag 换成节点名，客户端里选节点看得懂。
+//
+// 查询参数 (export 用 --param k=v)：
+//
+//	version=1.10|1.11|1.12  目标 sing-box 版本，缺省 1.12
+//	tun=1                   加 tun 入站 (接管全局流量)
+//	fakeip=1                DNS 用 fake-ip
+//
+// 各版本字段差异：
+//   - 1.11 起 sniff / DNS 劫持 / 拦截改成路由 action，inbound sniff 字段和
+//     dns / block 出站弃用 (1.13 移除)
+//   - 1.12 起 DNS server 改成 type + server 的新结构，节点域名的解析改由
+//     route.default_domain_resolver 指定，dns rule 里的 outbound 弃用
+//   - AnyTLS 出站 1.12 才有，更老的版本跳过这类节点
+
+const (
+	singboxMinMinor     = 10
+	singboxLatestMinor  = 12
+	singboxAnyTLSMinor  = 12
+	singboxTestURL      = "https://www.gstatic.com/generate_204"
+	singboxMixedPort    = 2080
+	singboxFakeIPRange  = "198.18.0.0/15"
+	singboxRuleSetURL   = "https://raw.githubusercontent.com/SagerNet/sing-%s/rule-set/%s.srs"
+	singboxTagProxy     = "proxy"
+	singboxTagAuto      = "auto"
+	singboxTagDirect    = "direct"
+	singboxDNSRemote    = "remote"
+	singboxDNSLocal     = "local"
+	singboxDNSFakeIP    = "fakeip"
+	singboxRemoteDNSIP  = "1.1.1.1"
+	singboxLocalDNSIP   = "223.5.5.5"
+	singboxGeositeCN    = "geosite-cn"
+	singboxGeoIPCN      = "geoip-cn"
+	singboxLegacyDNSOut = "dns-out"
+	singboxLegacyBlock  = "block"
+)
+
+// singboxMinor parses "1.11", "1.11.4" or "v1.12.0" into the minor version.
+// Empty = latest layout; newer than we know = latest layout too.
+func singboxMinor(v string) (int, error) {
+	if v == "" {
+		return singboxLatestMinor, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("sing-box 版本格式不对: %q (例: 1.11)", v)
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || major > 1 {
+		return 0, fmt.Errorf("sing-box 版本格式不对: %q (例: 1.11)", v)
+	}
+	if major < 1 || minor < singboxMinMinor {
+		return 0, fmt.Errorf("sing-box %s 太老，singbox-profile 最低支持 1.%d", v, singboxMinMinor)
+	}
+	if minor > singboxLatestMinor {
+		minor = singboxLatestMinor
+	}
+	return minor, nil
+}
+
+func renderSingboxProfile(w io.Writer, nodes []store.Node, opts Options) error {
+	minor, err := singboxMinor(opts.Params.Get("version"))
+	if err != nil {
+		return err
+	}
+	tun := opts.Params.Get("tun") == "1"
+	fakeIP := opts.Params.Get("fakeip") == "1"
+	legacy := minor < 11 // sniff / hijack / block 还是 inbound 字段和特殊出站
+
+	seen := map[string]bool{}
+	var nodeOutbounds []any
+	var tags []string
+	for i := range nodes {
+		if minor < singboxAnyTLSMinor && (nodes[i].Type == store.TypeAnyTLS || nodes[i].Type == store.TypeAnyTLSReality) {
+			continue
+		}
+		entries, err := ToSingbox(&nodes[i])
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			tag := uniqueName(seen, nodes[i].Name)
+			e["tag"] = tag
+			nodeOutbounds = append(nodeOutbounds, e)
+			tags = append(tags, tag)
+		}
+	}
+
+	// inbounds
+	mixed := map[string]any{
+		"type":        "mixed",
+		"tag":         "mixed-in",
+		"listen":      "127.0.0.1",
+		"listen_port": singboxMixedPort,
+	}
+	inbounds := []any{mixed}
+	if tun {
+		inbounds = append(inbounds, map[string]any{
+			"type":         "tun",
+			"tag":          "tun-in",
+			"address":      []string{"172.19.0.1/30", "fdfe:dcba:9876::1/126"},
+			"auto_route":   true,
+			"strict_route": true,
+			"stack":        "mixed",
+		})
+	}
+	if legacy {
+		for _, in := range inbounds {
+			in.(map[string]any)["sniff"] = true
+		}
+	}
+
+	// outbounds: selector 在最前，客户端 UI 默认展示它
+	selectable := []string{}
+	if len(tags) > 0 {
+		selectable = append(selectable, singboxTagAuto)
+	}
+	selectable = append(append(selectable, tags...), singboxTagDirect)
+	outbounds := []any{map[string]any{
+		"type":      "selector",
+		"tag":       singboxTagProxy,
+		"outbounds": selectable,
+		"default":   selectable[0],
+	}}
+	// urltest 不能是空的，没有节点就不放。
+	if len(tags) > 0 {
+		outbounds = append(outbounds, map[string]any{
+			"type":      "urltest",
+			"tag":       singboxTagAuto,
+			"outbounds": tags,
+			"url":       singboxTestURL,
+			"interval":  "3m",
+			"tolerance": 50,
+		})
+	}
+	outbounds = append(outbounds, nodeOutbounds...)
+	outbounds = append(outbounds, map[string]any{"type": "direct", "tag": singboxTagDirect})
+	if legacy {
+		outbounds = append(outbounds,
+			map[string]any{"type": "block", "tag": singboxLegacyBlock},
+			map[string]any{"type": "dns", "tag": singboxLegacyDNSOut},
+		)
+	}
+
+	// route
+	var rules []any
+	if legacy {
+		rules = append(rules, map[string]any{"protocol": "dns", "outbound": singboxLegacyDNSOut})
+	} else {
+		rules = append(rules,
+			map[string]any{"action": "sniff"},
+			map[string]any{"protocol": "dns", "action": "hijack-dns"},
+		)
+	}
+	rules = append(rules,
+		map[string]any{"ip_is_private": true, "outbound": singboxTagDirect},
+		map[string]any{"rule_set": []string{singboxGeositeCN, singboxGeoIPCN}, "outbound": singboxTagDirect},
+	)
+	route := map[string]any{
+		"rules": rules,
+		"rule_set": []any{
+			singboxRuleSet(singboxGeositeCN, "geosite"),
+			singboxRuleSet(singboxGeoIPCN, "geoip"),
+		},
+		"final":                 singboxTagProxy,
+		"auto_detect_interface": true,
+	}
+	if minor >= 12 {
+		route["default_domain_resolver"] = singboxDNSLocal
+	}
+
+	return writeJSON(w, map[string]any{
+		"log":       map[string]any{"level": "info", "timestamp": true},
+		"dns":       singboxDNS(minor, fakeIP),
+		"inbounds":  inbounds,
+		"outbounds": outbounds,
+		"route":     route,
+		"experimental": map[string]any{
+			"cache_file": map[string]any{"enabled": true, "store_fakeip": fakeIP},
+		},
+	})
+}
+
+func singboxRuleSet(tag, repo string) map[string]any {
+	return map[string]any{
+		"type":            "remote",
+		"tag":             tag,
+		"format":          "binary",
+		"url":             fmt.Sprintf(singboxRuleSetURL, repo, tag),
+		"download_detour": singboxTagProxy,
+	}
+}
+
+// singboxDNS: 国内域名走 223.5.5.5，其余经代理问 1.1.1.1 (都是 DoH)。
+func singboxDNS(minor int, fakeIP bool) map[string]any {
+	var servers, rules []any
+	dns := map[string]any{"final": singboxDNSRemote}
+	if minor >= 12 {
+		servers = []any{
+			map[string]any{"type": "https", "tag": singboxDNSRemote, "server": singboxRemoteDNSIP, "detour": singboxTagProxy},
+			map[string]any{"type": "https", "tag": singboxDNSLocal, "server": singboxLocalDNSIP},
+		}
+		if fakeIP {
+			servers = append(servers, map[string]any{"type": "fakeip", "tag": singboxDNSFakeIP, "inet4_range": singboxFakeIPRange})
+		}
+	} else {
+		servers = []any{
+			map[string]any{"tag": singboxDNSRemote, "address": "https://" + singboxRemoteDNSIP + "/dns-query", "detour": singboxTagProxy},
+			map[string]any{"tag": singboxDNSLocal, "address": "https://" + singboxLocalDNSIP + "/dns-query", "detour": singboxTagDirect},
+		}
+		if fakeIP {
+			servers = append(servers, map[string]any{"tag": singboxDNSFakeIP, "address": "fakeip"})
+			dns["fakeip"] = map[string]any{"enabled": true, "inet4_range": singboxFakeIPRange}
+		}
+		// 节点自己的域名必须直连解析，不然要先连上代理才能解析代理。
+		rules = append(rules, map[string]any{"outbound": "any", "server": singboxDNSLocal})
+	}
+	rules = append(rules, map[string]any{"rule_set": singboxGeositeCN, "server": singboxDNSLocal})
+	if fakeIP {
+		rules = append(rules, map[string]any{"query_type": []string{"A", "AAAA"}, "server": singboxDNSFakeIP})
+	}
+	dns["servers"] = servers
+	dns["rules"] = rules
+	return dns
+}
@@ -0,0 +1,73 @@
+package format
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestSingboxProfileVersions(t *testing.T) {
+	nodes := []store.Node{
+		{
+			ID: "hysteria2-1.2.3.4", Name: "HK", Type: store.TypeHysteria2,
+			Server: "1.2.3.4", Port: 443,
+			Params: map[string]any{"password": "p", "domain": "hk.example.com"},
+		},
+		{
+			ID: "anytls-1.2.3.4", Name: "HK-AnyTLS", Type: store.TypeAnyTLS,
+			Server: "1.2.3.4", Port: 8443,
+			Params: map[string]any{"password": "p", "domain": "hk.example.com"},
+		},
+	}
+	render := func(query string) (map[string]any, error) {
+		params, _ := url.ParseQuery(query)
+		var b strings.Builder
+		if err := renderSingboxProfile(&b, nodes, Options{Params: params}); err != nil {
+			return nil, err
+		}
+		var out map[string]any
+		if err := json.Unmarshal([]byte(b.String()), &out); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		return out, nil
+	}
+	outboundTags := func(cfg map[string]any) []string {
+		var tags []string
+		for _, o := range cfg["outbounds"].([]any) {
+			tags = append(tags, o.(map[string]any)["tag"].(string))
+		}
+		return tags
+	}
+
+	latest, err := render("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(outboundTags(latest), ","); got != "proxy,auto,HK,HK-AnyTLS,direct" {
+		t.Errorf("1.12 outbounds = %s", got)
+	}
+	if latest["route"].(map[string]any)["default_domain_resolver"] != "local" {
+		t.Error("1.12 must set route.default_domain_resolver")
+	}
+
+	legacy, err := render("version=1.10.7&tun=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1.10 没有 anytls 出站，还用 block / dns 特殊出站
+	if got := strings.Join(outboundTags(legacy), ","); got != "proxy,auto,HK,direct,block,dns-out" {
+		t.Errorf("1.10 outbounds = %s", got)
+	}
+	if n := len(legacy["inbounds"].([]any)); n != 2 {
+		t.Errorf("tun=1 should add a tun inbound, got %d inbounds", n)
+	}
+
+	for _, bad := range []string{"version=0.9", "version=1.9", "version=latest"} {
+		if _, err := render(bad); err == nil {
+			t.Errorf("%s should be rejected", bad)
+		}
+	}
+}
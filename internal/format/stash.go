@@ -0,0 +1,115 @@
+// Stash (iOS / macOS) 吃 Clash 风格的 YAML，但 proxy 字段是自己的方言：
+// Hysteria2 的密码叫 auth，没有 salamander 混淆，也没有 AnyTLS+Reality；
+// VLESS Reality 沿用 Clash Meta 的 reality-opts。stash 格式是一份完整配置
+// (proxies + 策略组 + 规则)，Stash 里「从 URL 下载配置」直接用。
+package format
+
+import (
+	"fmt"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func vlessRealityToStash(n *store.Node, p *store.RealityParams) map[string]any {
+	out := map[string]any{
+		"name":       n.Name,
+		"type":       "vless",
+		"server":     n.Server,
+		"port":       n.Port,
+		"uuid":       p.UUID,
+		"network":    "tcp",
+		"tls":        true,
+		"udp":        true,
+		"servername": p.ServerName,
+		"reality-opts": map[string]any{
+			"public-key": p.PublicKey,
+			"short-id":   p.ShortID,
+		},
+	}
+	if flow := p.Flow; flow != "" {
+		out["flow"] = flow
+	}
+	return out
+}
+
+func hysteria2ToStash(n *store.Node, p *store.Hysteria2Params) (map[string]any, error) {
+	if p.EnableObfs {
+		return nil, fmt.Errorf("%w: stash has no hysteria2 salamander obfs", ErrUnsupportedFormat)
+	}
+	host, sni := p.Endpoint(n.Server)
+	return map[string]any{
+		"name":   n.Name,
+		"type":   "hysteria2",
+		"server": host,
+		"port":   n.Port,
+		"auth":   p.Password,
+		"sni":    sni,
+	}, nil
+}
+
+func anytlsToStash(n *store.Node, p *store.AnyTLSParams) map[string]any {
+	host, sni := p.Endpoint(n.Server)
+	return map[string]any{
+		"name":     n.Name,
+		"type":     "anytls",
+		"server":   host,
+		"port":     n.Port,
+		"password": p.Password,
+		"sni":      sni,
+		"udp":      true,
+	}
+}
+
+// stashProfile 和 mihomo-profile 同样的策略组，规则只用 Stash 认的类型，
+// DNS 交给 Stash 自己的默认设置。
+func stashProfile(nodes []store.Node) yamlMap {
+	seen := map[string]bool{}
+	var proxies []any
+	var names []string
+	for i := range nodes {
+		entry, err := ToStash(&nodes[i])
+		if err != nil {
+			continue
+		}
+		name := uniqueName(seen, nodes[i].Name)
+		entry["name"] = name
+		proxies = append(proxies, entry)
+		names = append(names, name)
+	}
+	members := names
+	if len(members) == 0 {
+		members = []string{"DIRECT"}
+	}
+	selectable := append([]string{mihomoGroupAuto}, names...)
+	selectable = append(selectable, "DIRECT")
+
+	return yamlMap{
+		{"mode", "rule"},
+		{"log-level", "warning"},
+		{"proxies", proxies},
+		{"proxy-groups", []any{
+			yamlMap{
+				{"name", mihomoGroupProxy},
+				{"type", "select"},
+				{"proxies", selectable},
+			},
+			yamlMap{
+				{"name", mihomoGroupAuto},
+				{"type", "url-test"},
+				{"url", mihomoTestURL},
+				{"interval", mihomoTestInterval},
+				{"tolerance", 50},
+				{"proxies", members},
+			},
+		}},
+		{"rules", []string{
+			"DOMAIN-SUFFIX,local,DIRECT",
+			"IP-CIDR,127.0.0.0/8,DIRECT,no-resolve",
+			"IP-CIDR,10.0.0.0/8,DIRECT,no-resolve",
+			"IP-CIDR,172.16.0.0/12,DIRECT,no-resolve",
+			"IP-CIDR,192.168.0.0/16,DIRECT,no-resolve",
+			"GEOIP,CN,DIRECT",
+			"MATCH," + mihomoGroupProxy,
+		}},
+	}
+}
@@ -0,0 +1,36 @@
+// Surfboard (Android) 用 Surge 的 .conf 语法和 #!MANAGED-CONFIG 自更新，
+// 但协议少得多：这里能生成的只有不带混淆的 Hysteria2。VLESS、AnyTLS、
+// salamander obfs 它都没有，对应节点返回 ErrUnsupportedFormat。
+package format
+
+import (
+	"fmt"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// surfboardProfile 比 Surge 少了 Surfboard 不认的 [General] 键和 RULE-SET。
+var surfboardProfile = surgeStyleProfile{
+	line: ToSurfboard,
+	general: []string{
+		"dns-server = system, 223.5.5.5, 119.29.29.29",
+		"skip-proxy = 127.0.0.1, 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, 100.64.0.0/10, localhost, *.local",
+		"proxy-test-url = " + surgeTestURL,
+		"ipv6 = false",
+	},
+	rules: []string{
+		"IP-CIDR,192.168.0.0/16,DIRECT",
+		"IP-CIDR,10.0.0.0/8,DIRECT",
+		"IP-CIDR,172.16.0.0/12,DIRECT",
+		"DOMAIN-SUFFIX,cn,DIRECT",
+		"GEOIP,CN,DIRECT",
+	},
+}
+
+func hysteria2ToSurfboard(n *store.Node, p *store.Hysteria2Params) (string, error) {
+	if p.EnableObfs {
+		return "", fmt.Errorf("%w: surfboard has no hysteria2 salamander obfs", ErrUnsupportedFormat)
+	}
+	host, sni := p.Endpoint(n.Server)
+	return fmt.Sprintf("%s = hysteria2, %s, %d, password=%s, sni=%s", n.Name, host, n.Port, p.Password, sni), nil
+}
@@ -0,0 +1,101 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// surge-profile 是完整的 Surge .conf：#!MANAGED-CONFIG 指回订阅 URL，
+// Surge 按 interval 自己拉新版本，不用手动合并 [Proxy] 行。Surge 不支持的
+// 节点 (AnyTLS+Reality) 直接不出现。
+
+const (
+	surgeGroupProxy = "Proxy"
+	surgeGroupAuto  = "Auto"
+
+	surgeTestURL = "http://www.gstatic.com/generate_204"
+	// surgeUpdateInterval 是 MANAGED-CONFIG 的刷新间隔 (秒)。
+	surgeUpdateInterval = 86400
+)
+
+// surgeStyleProfile 是 Surge 和 Surfboard 共用的 .conf 骨架：两家 [Proxy]
+// 行的方言和支持的 [General] / 规则类型不同，段落结构、策略组写法和
+// MANAGED-CONFIG 头是一样的。
+type surgeStyleProfile struct {
+	line    func(*store.Node) (string, error)
+	general []string
+	rules   []string // FINAL 之前的规则
+}
+
+var surgeProfile = surgeStyleProfile{
+	line: ToSurge,
+	general: []string{
+		"loglevel = notify",
+		"dns-server = system, 223.5.5.5, 119.29.29.29",
+		"skip-proxy = 127.0.0.1, 192.168.0.0/16, 10.0.0.0/8, 172.16.0.0/12, 100.64.0.0/10, localhost, *.local",
+		"exclude-simple-hostnames = true",
+		"proxy-test-url = " + surgeTestURL,
+		"ipv6 = false",
+	},
+	rules: []string{
+		"RULE-SET,LAN,DIRECT",
+		"DOMAIN-SUFFIX,cn,DIRECT",
+		"GEOIP,CN,DIRECT",
+	},
+}
+
+func renderSurgeProfile(w io.Writer, nodes []store.Node, opts Options) error {
+	return surgeProfile.render(w, nodes, opts)
+}
+
+func (p surgeStyleProfile) render(w io.Writer, nodes []store.Node, opts Options) error {
+	seen := map[string]bool{}
+	var proxies, names []string
+	for i := range nodes {
+		n := nodes[i]
+		n.Name = uniqueName(seen, n.Name)
+		line, err := p.line(&n)
+		if err != nil {
+			continue
+		}
+		proxies = append(proxies, line)
+		names = append(names, n.Name)
+	}
+
+	var b strings.Builder
+	if opts.URL != "" {
+		// strict=false: 拉取失败时继续用本地那份，不弹窗打断。
+		fmt.Fprintf(&b, "#!MANAGED-CONFIG %s interval=%d strict=false\n\n", opts.URL, surgeUpdateInterval)
+	}
+
+	b.WriteString("[General]\n")
+	for _, line := range p.general {
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n[Proxy]\n")
+	for _, line := range proxies {
+		b.WriteString(line + "\n")
+	}
+
+	// url-test 组不能是空的；没有节点时只留 select + DIRECT。
+	b.WriteString("\n[Proxy Group]\n")
+	if len(names) == 0 {
+		fmt.Fprintf(&b, "%s = select, DIRECT\n", surgeGroupProxy)
+	} else {
+		fmt.Fprintf(&b, "%s = select, %s, %s, DIRECT\n", surgeGroupProxy, surgeGroupAuto, strings.Join(names, ", "))
+		fmt.Fprintf(&b, "%s = url-test, %s, url=%s, interval=300, tolerance=50\n", surgeGroupAuto, strings.Join(names, ", "), surgeTestURL)
+	}
+
+	b.WriteString("\n[Rule]\n")
+	for _, rule := range p.rules {
+		b.WriteString(rule + "\n")
+	}
+	fmt.Fprintf(&b, "FINAL,%s,dns-failed\n", surgeGroupProxy)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
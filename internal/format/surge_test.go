@@ -0,0 +1,51 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestSurgeProfile(t *testing.T) {
+	nodes := []store.Node{
+		{
+			ID: "hysteria2-1.2.3.4", Name: "HK", Type: store.TypeHysteria2,
+			Server: "1.2.3.4", Port: 443,
+			Params: map[string]any{"password": "p", "domain": "hk.example.com"},
+		},
+		{
+			ID: "anytls-reality-1.2.3.4", Name: "HK-AR", Type: store.TypeAnyTLSReality,
+			Server: "1.2.3.4", Port: 8443,
+			Params: map[string]any{
+				"password": "p", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+				"short_id": "ab", "server_name": "www.apple.com",
+			},
+		},
+	}
+	f, ok := Lookup("surge-profile")
+	if !ok {
+		t.Fatal("surge-profile not registered")
+	}
+	var b strings.Builder
+	if err := f.Render(&b, nodes, Options{URL: "https://sub.example.com/s/surge-profile/tok"}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.HasPrefix(out, "#!MANAGED-CONFIG https://sub.example.com/s/surge-profile/tok interval=86400 strict=false\n") {
+		t.Errorf("missing MANAGED-CONFIG header:\n%s", out)
+	}
+	for _, want := range []string{
+		"[Proxy]\nHK = hysteria2, hk.example.com, 443,",
+		"Proxy = select, Auto, HK, DIRECT\n",
+		"Auto = url-test, HK, url=",
+		"FINAL,Proxy,dns-failed\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("profile missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "HK-AR") || strings.Contains(out, "# AnyTLS") {
+		t.Errorf("anytls-reality must be left out of a Surge profile:\n%s", out)
+	}
+}
@@ -2,112 +2,74 @@ package format
 
 import (
 	"fmt"
-	"net/url"
 
 	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
-// VlessRealityShareURL 生成单节点 vless:// 分享 URL，标准格式 (xray/v2ray
-// 客户端通用)。可以 QR 编码后扫码导入到 NekoBox / V2Box / sing-box-windows
-// 等任意 Reality 客户端，不依赖订阅服务。
-//
-// 格式参考: https://github.com/XTLS/Xray-core/discussions/716
-//
-//	vless://<uuid>@<host>:<port>?
-//	  encryption=none&flow=xtls-rprx-vision&
-//	  type=tcp&security=reality&
-//	  pbk=<publickey>&sni=<servername>&sid=<shortid>&
-//	  fp=chrome&spx=%2F
-//	  #<urlencoded-name>
-func VlessRealityShareURL(n *store.Node) string {
-	p := n.Params
-	q := url.Values{}
-	q.Set("encryption", "none")
-	if flow := str(p, "flow"); flow != "" {
-		q.Set("flow", flow)
-	}
-	q.Set("type", "tcp")
-	q.Set("security", "reality")
-	q.Set("pbk", str(p, "public_key"))
-	q.Set("sni", str(p, "server_name"))
-	q.Set("sid", str(p, "short_id"))
-	q.Set("fp", "chrome")
-	q.Set("spx", "/")
-	return fmt.Sprintf("vless://%s@%s:%d?%s#%s",
-		str(p, "uuid"),
-		n.Server, n.Port,
-		q.Encode(),
-		url.QueryEscape(n.Name),
-	)
-}
-
 // vlessRealityToSurge emits Surge's documented vless-reality syntax. Surge's
 // official VLESS Reality support is recent and field names may shift; if your
 // Surge build rejects this line, route the node through the Mac bridge script
 // (xray output) instead.
-func vlessRealityToSurge(n *store.Node) string {
-	p := n.Params
+func vlessRealityToSurge(n *store.Node, p *store.RealityParams) string {
 	line := fmt.Sprintf(
 		"%s = vless, %s, %d, username=%s, sni=%s, public-key=%s, short-id=%s, tfo=true, udp-relay=true",
 		n.Name, n.Server, n.Port,
-		str(p, "uuid"),
-		str(p, "server_name"),
-		str(p, "public_key"),
-		str(p, "short_id"),
+		p.UUID,
+		p.ServerName,
+		p.PublicKey,
+		p.ShortID,
 	)
-	if flow := str(p, "flow"); flow != "" {
+	if flow := p.Flow; flow != "" {
 		line += ", flow=" + flow
 	}
 	return line
 }
 
-func vlessRealityToClash(n *store.Node) map[string]any {
-	p := n.Params
+func vlessRealityToClash(n *store.Node, p *store.RealityParams) map[string]any {
 	out := map[string]any{
 		"name":               n.Name,
 		"type":               "vless",
 		"server":             n.Server,
 		"port":               n.Port,
-		"uuid":               str(p, "uuid"),
+		"uuid":               p.UUID,
 		"network":            "tcp",
 		"tls":                true,
 		"udp":                true,
-		"servername":         str(p, "server_name"),
+		"servername":         p.ServerName,
 		"client-fingerprint": "chrome",
 		"reality-opts": map[string]any{
-			"public-key": str(p, "public_key"),
-			"short-id":   str(p, "short_id"),
+			"public-key": p.PublicKey,
+			"short-id":   p.ShortID,
 		},
 	}
-	if flow := str(p, "flow"); flow != "" {
+	if flow := p.Flow; flow != "" {
 		out["flow"] = flow
 	}
 	return out
 }
 
-func vlessRealityToSingbox(n *store.Node) map[string]any {
-	p := n.Params
+func vlessRealityToSingbox(n *store.Node, p *store.RealityParams) map[string]any {
 	out := map[string]any{
 		"type":        "vless",
 		"tag":         n.ID,
 		"server":      n.Server,
 		"server_port": n.Port,
-		"uuid":        str(p, "uuid"),
+		"uuid":        p.UUID,
 		"tls": map[string]any{
 			"enabled":     true,
-			"server_name": str(p, "server_name"),
+			"server_name": p.ServerName,
 			"utls": map[string]any{
 				"enabled":     true,
 				"fingerprint": "chrome",
 			},
 			"reality": map[string]any{
 				"enabled":    true,
-				"public_key": str(p, "public_key"),
-				"short_id":   str(p, "short_id"),
+				"public_key": p.PublicKey,
+				"short_id":   p.ShortID,
 			},
 		},
 	}
-	if flow := str(p, "flow"); flow != "" {
+	if flow := p.Flow; flow != "" {
 		out["flow"] = flow
 	}
 	return out
@@ -116,13 +78,12 @@ func vlessRealityToSingbox(n *store.Node) map[string]any {
 // vlessRealityToXray is the canonical bridge target. The Mac script reads
 // these outbounds and writes them into a local xray config that exposes a
 // SOCKS5 listener for Surge to consume.
-func vlessRealityToXray(n *store.Node) map[string]any {
-	p := n.Params
+func vlessRealityToXray(n *store.Node, p *store.RealityParams) map[string]any {
 	user := map[string]any{
-		"id":         str(p, "uuid"),
+		"id":         p.UUID,
 		"encryption": "none",
 	}
-	if flow := str(p, "flow"); flow != "" {
+	if flow := p.Flow; flow != "" {
 		user["flow"] = flow
 	}
 	return map[string]any{
@@ -143,9 +104,9 @@ func vlessRealityToXray(n *store.Node) map[string]any {
 			"realitySettings": map[string]any{
 				"show":        false,
 				"fingerprint": "chrome",
-				"serverName":  str(p, "server_name"),
-				"publicKey":   str(p, "public_key"),
-				"shortId":     str(p, "short_id"),
+				"serverName":  p.ServerName,
+				"publicKey":   p.PublicKey,
+				"shortId":     p.ShortID,
 				"spiderX":     "/",
 			},
 		},
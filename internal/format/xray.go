@@ -0,0 +1,135 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// xray-bridge 是完整的 xray 客户端配置，下载下来直接 `xray -c` 跑：本地
+// SOCKS5 + HTTP 入站，每个 VLESS Reality 节点一个出站，observatory 探测 +
+// leastPing balancer 在节点之间自动切换。XSurge 不用再自己拼配置；Linux
+// 桌面也能直接用。
+//
+// 分流交给前面的 Surge / 系统代理：这里只让私有地址直连，其余全进 balancer。
+//
+// 查询参数 (export 用 --param k=v)：
+//
+//	socks_port=10808  SOCKS5 入站端口
+//	http_port=10809   HTTP 入站端口
+
+const (
+	xrayDefaultSocksPort = 10808
+	xrayDefaultHTTPPort  = 10809
+	xrayTestURL          = "https://www.gstatic.com/generate_204"
+
+	// 节点出站 tag 统一加前缀，observatory / balancer 按前缀选中全部节点。
+	xrayNodeTagPrefix = "node-"
+	xrayBalancerTag   = "auto"
+)
+
+// ErrNoBridgeNodes: xray-bridge 只认 VLESS Reality，一个都没有就没东西可跑。
+var ErrNoBridgeNodes = errors.New("没有可用的 VLESS Reality 节点，xray-bridge 无法生成")
+
+func renderXrayBridge(w io.Writer, nodes []store.Node, opts Options) error {
+	socksPort, err := portParam(opts, "socks_port", xrayDefaultSocksPort)
+	if err != nil {
+		return err
+	}
+	httpPort, err := portParam(opts, "http_port", xrayDefaultHTTPPort)
+	if err != nil {
+		return err
+	}
+	if socksPort == httpPort {
+		return fmt.Errorf("socks_port 和 http_port 不能相同: %d", socksPort)
+	}
+
+	var outbounds []any
+	var firstTag string
+	for i := range nodes {
+		entries, err := ToXray(&nodes[i])
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			tag := xrayNodeTagPrefix + nodes[i].ID
+			e["tag"] = tag
+			if firstTag == "" {
+				firstTag = tag
+			}
+			outbounds = append(outbounds, e)
+		}
+	}
+	if len(outbounds) == 0 {
+		return ErrNoBridgeNodes
+	}
+	outbounds = append(outbounds,
+		map[string]any{"tag": "direct", "protocol": "freedom"},
+		map[string]any{"tag": "block", "protocol": "blackhole"},
+	)
+
+	sniffing := map[string]any{
+		"enabled":      true,
+		"destOverride": []string{"http", "tls", "quic"},
+		"routeOnly":    true,
+	}
+	return writeJSON(w, map[string]any{
+		"log": map[string]any{"loglevel": "warning"},
+		"inbounds": []any{
+			map[string]any{
+				"tag":      "socks-in",
+				"protocol": "socks",
+				"listen":   "127.0.0.1",
+				"port":     socksPort,
+				"settings": map[string]any{"udp": true},
+				"sniffing": sniffing,
+			},
+			map[string]any{
+				"tag":      "http-in",
+				"protocol": "http",
+				"listen":   "127.0.0.1",
+				"port":     httpPort,
+				"sniffing": sniffing,
+			},
+		},
+		"outbounds": outbounds,
+		"observatory": map[string]any{
+			"subjectSelector":   []string{xrayNodeTagPrefix},
+			"probeURL":          xrayTestURL,
+			"probeInterval":     "1m",
+			"enableConcurrency": true,
+		},
+		"routing": map[string]any{
+			"domainStrategy": "AsIs",
+			"balancers": []any{
+				map[string]any{
+					"tag":      xrayBalancerTag,
+					"selector": []string{xrayNodeTagPrefix},
+					"strategy": map[string]any{"type": "leastPing"},
+					// 探测还没出结果 (刚启动) 或全部失败时先用第一个节点。
+					"fallbackTag": firstTag,
+				},
+			},
+			"rules": []any{
+				map[string]any{"type": "field", "ip": []string{"geoip:private"}, "outboundTag": "direct"},
+				map[string]any{"type": "field", "network": "tcp,udp", "balancerTag": xrayBalancerTag},
+			},
+		},
+	})
+}
+
+// portParam reads a local port from opts.Params, def when absent.
+func portParam(opts Options, key string, def int) (int, error) {
+	v := opts.Params.Get(key)
+	if v == "" {
+		return def, nil
+	}
+	port, err := strconv.Atoi(v)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%s 不是合法端口: %q", key, v)
+	}
+	return port, nil
+}
@@ -0,0 +1,55 @@
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestXrayBridge(t *testing.T) {
+	reality := store.Node{
+		ID: "vless-reality-1.2.3.4", Name: "HK", Type: store.TypeVLESSReality,
+		Server: "1.2.3.4", Port: 443,
+		Params: map[string]any{
+			"uuid": "0b3c1f3e-1111-4222-8333-944455556666", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+			"short_id": "ab", "server_name": "www.apple.com",
+		},
+	}
+	params, _ := url.ParseQuery("socks_port=1080&http_port=8118")
+	var b strings.Builder
+	if err := renderXrayBridge(&b, []store.Node{reality}, Options{Params: params}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Inbounds []struct {
+			Protocol string `json:"protocol"`
+			Port     int    `json:"port"`
+		} `json:"inbounds"`
+		Routing struct {
+			Balancers []struct {
+				FallbackTag string `json:"fallbackTag"`
+			} `json:"balancers"`
+		} `json:"routing"`
+	}
+	if err := json.Unmarshal([]byte(b.String()), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Inbounds) != 2 || cfg.Inbounds[0].Port != 1080 || cfg.Inbounds[1].Port != 8118 {
+		t.Errorf("inbounds = %+v", cfg.Inbounds)
+	}
+	if len(cfg.Routing.Balancers) != 1 || cfg.Routing.Balancers[0].FallbackTag != "node-vless-reality-1.2.3.4" {
+		t.Errorf("balancers = %+v", cfg.Routing.Balancers)
+	}
+
+	if err := renderXrayBridge(&b, nil, Options{}); !errors.Is(err, ErrNoBridgeNodes) {
+		t.Errorf("no reality nodes: err = %v", err)
+	}
+	params.Set("http_port", "70000")
+	if err := renderXrayBridge(&b, []store.Node{reality}, Options{Params: params}); err == nil {
+		t.Error("out-of-range port should be rejected")
+	}
+}
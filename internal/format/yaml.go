@@ -0,0 +1,183 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 一个只够输出客户端配置的 YAML emitter：block 风格的 mapping / sequence +
+// 标量。go.mod 里不为这点输出引入 yaml 依赖；输入全是我们自己拼的
+// map/slice，所以不需要处理 anchor、多行字符串之类。读的一侧 (node import
+// 解析 Clash 配置) 在 yaml_parse.go，同样只认一个子集。
+
+// yamlMap is a mapping that keeps its key order (top-level profile sections
+// are read by humans, so order matters). Plain map[string]any values, e.g.
+// what ToClash returns, are emitted with "name" first and the rest sorted.
+type yamlMap []yamlItem
+
+type yamlItem struct {
+	Key   string
+	Value any
+}
+
+func writeYAML(w io.Writer, m yamlMap) error {
+	var b strings.Builder
+	emitYAMLMap(&b, m, 0)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func emitYAMLMap(b *strings.Builder, m yamlMap, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, it := range m {
+		b.WriteString(pad + yamlScalar(it.Key) + ":")
+		emitYAMLValue(b, it.Value, indent)
+	}
+}
+
+// emitYAMLValue writes v after "key:" — inline for scalars and empty
+// collections, on the following lines (indent+2) otherwise.
+func emitYAMLValue(b *strings.Builder, v any, indent int) {
+	switch v := normalizeYAML(v).(type) {
+	case yamlMap:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		emitYAMLMap(b, v, indent+2)
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		emitYAMLSeq(b, v, indent+2)
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func emitYAMLSeq(b *strings.Builder, s []any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, item := range s {
+		item = normalizeYAML(item)
+		switch item.(type) {
+		case yamlMap, []any:
+			// 先按 indent+2 输出，再把第一行的前导空格换成 "- "。
+			var sub strings.Builder
+			switch item := item.(type) {
+			case yamlMap:
+				if len(item) == 0 {
+					b.WriteString(pad + "- {}\n")
+					continue
+				}
+				emitYAMLMap(&sub, item, indent+2)
+			case []any:
+				if len(item) == 0 {
+					b.WriteString(pad + "- []\n")
+					continue
+				}
+				emitYAMLSeq(&sub, item, indent+2)
+			}
+			b.WriteString(pad + "- " + sub.String()[indent+2:])
+		default:
+			b.WriteString(pad + "- " + yamlScalar(item) + "\n")
+		}
+	}
+}
+
+// normalizeYAML folds the Go shapes the generators produce into yamlMap /
+// []any / scalar.
+func normalizeYAML(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if (keys[i] == "name") != (keys[j] == "name") {
+				return keys[i] == "name"
+			}
+			return keys[i] < keys[j]
+		})
+		m := make(yamlMap, 0, len(keys))
+		for _, k := range keys {
+			m = append(m, yamlItem{k, v[k]})
+		}
+		return m
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	case []string:
+		out := make([]any, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out
+	}
+	return v
+}
+
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return yamlString(v)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	}
+	return yamlString(fmt.Sprint(v))
+}
+
+// yamlString leaves s plain only when no YAML reader could take it for
+// anything but that string; everything else is double-quoted (Go's escape
+// sequences are a subset of YAML's).
+func yamlString(s string) string {
+	if yamlPlainSafe(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func yamlPlainSafe(s string) bool {
+	if s == "" || strings.HasSuffix(s, ":") {
+		return false
+	}
+	switch strings.ToLower(strings.TrimPrefix(s, "+")) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", ".inf", ".nan":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	if s[0] == '+' && len(s) > 1 && s[1] >= '0' && s[1] <= '9' {
+		return false
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		// 数字开头的只放行 IPv4 这种点分形式；版本号 / 时间 / 十六进制
+		// 交给引号。
+		return strings.Trim(s, "0123456789.") == ""
+	}
+	switch s[0] {
+	case '-', '.', ':', '?', ',', '*', '&', '!', '|', '>', '%', '@', '`', '=', '/':
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_./:+=@,", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,448 @@
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Clash / mihomo 配置的 YAML 子集解析，给 node import 用：block 风格的
+// mapping / sequence、flow 风格的 {..} / [..] (订阅里的 proxies 常写成一行
+// 一个 {name: .., type: ..})、单双引号字符串、| > 块标量、注释。anchor /
+// alias / tag / 多文档不支持：要么报错，要么原样当字符串。解析结果是
+// map[string]any / []any / string / int / bool / nil，和 encoding/json 解出来
+// 的东西一个形状，后面的 ParseClash 两边通用。
+
+type yamlLine struct {
+	num    int // 1-based, for errors
+	indent int
+	text   string // 去掉缩进、注释和行尾空白
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripYAMLComment(strings.TrimRight(raw, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." || strings.HasPrefix(trimmed, "%") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("YAML 第 %d 行: 缩进不能用 tab", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "缩进不对")
+	}
+	return v, nil
+}
+
+func (p *yamlParser) errorf(l yamlLine, format string, args ...any) error {
+	return fmt.Errorf("YAML 第 %d 行: %s", l.num, fmt.Sprintf(format, args...))
+}
+
+// block parses the node starting at the current line, whose indent is indent.
+func (p *yamlParser) block(indent int) (any, error) {
+	l := p.lines[p.pos]
+	if isYAMLSeqItem(l.text) {
+		return p.seq(indent)
+	}
+	if _, _, ok := splitYAMLKey(l.text); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return p.inline(l)
+}
+
+// nested parses the value on the lines after "key:" / "-": a block indented
+// deeper than indent, or nil when there is none.
+func (p *yamlParser) nested(indent int) (any, error) {
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.block(p.lines[p.pos].indent)
+	}
+	return nil, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) seq(indent int) (any, error) {
+	out := []any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && !isYAMLSeqItem(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l, "缩进不对")
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var v any
+		var err error
+		if rest == "" {
+			p.pos++
+			v, err = p.nested(indent)
+		} else {
+			// "- key: value" / "- - x"：把这一行当成从 rest 所在列开始的新块
+			col := indent + len(l.text) - len(rest)
+			p.lines[p.pos] = yamlLine{num: l.num, indent: col, text: rest}
+			v, err = p.block(col)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	out := map[string]any{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent || isYAMLSeqItem(l.text) && l.indent == indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf(l, "缩进不对")
+		}
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, p.errorf(l, "应为 key: value")
+		}
+		p.pos++
+		var v any
+		var err error
+		switch {
+		case rest == "":
+			// "key:" 下面的序列允许不缩进
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+				v, err = p.seq(indent)
+			} else {
+				v, err = p.nested(indent)
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			v = p.blockScalar(indent, rest[0] == '>')
+		default:
+			v, err = p.inline(yamlLine{num: l.num, indent: l.indent, text: rest})
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// blockScalar collects the lines of a | (literal) or > (folded) scalar.
+// 注释和空行在切行时已经丢了，对配置里的块标量够用。
+func (p *yamlParser) blockScalar(indent int, folded bool) string {
+	var parts []string
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		parts = append(parts, p.lines[p.pos].text)
+		p.pos++
+	}
+	if folded {
+		return strings.Join(parts, " ")
+	}
+	return strings.Join(parts, "\n")
+}
+
+// inline parses a scalar or flow collection written after "key:" / "-".
+// 没闭合的 {..} / [..] 继续吞后面的行。
+func (p *yamlParser) inline(l yamlLine) (any, error) {
+	text := l.text
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		for !flowBalanced(text) && p.pos < len(p.lines) {
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+	}
+	s := &flowScanner{s: text}
+	v, err := s.value(false)
+	if err == nil {
+		s.skipSpace()
+		if s.i < len(s.s) {
+			err = fmt.Errorf("多余的内容 %q", s.s[s.i:])
+		}
+	}
+	if err != nil {
+		return nil, p.errorf(l, "%v", err)
+	}
+	return v, nil
+}
+
+func flowBalanced(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// splitYAMLKey splits "key: value" / "key:" (key plain or quoted).
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text == "" || strings.ContainsRune("[{", rune(text[0])) {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		s := &flowScanner{s: text}
+		k, err := s.quoted()
+		if err != nil || s.i >= len(text) || text[s.i] != ':' {
+			return "", "", false
+		}
+		rest = text[s.i+1:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(rest), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment drops a trailing "# ..." that is not inside quotes. 引号
+// 只在 token 开头才算 (It's 这种词中间的撇号不是)。
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && yamlTokenStart(s, i):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func yamlTokenStart(s string, i int) bool {
+	j := i - 1
+	for j >= 0 && s[j] == ' ' {
+		j--
+	}
+	return j < 0 || strings.ContainsRune(":-,[{", rune(s[j]))
+}
+
+// flowScanner reads one value in flow style: {a: b}, [x, y], quoted or
+// plain scalars.
+type flowScanner struct {
+	s string
+	i int
+}
+
+func (f *flowScanner) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+// value reads the next value; inFlow means we are inside {} / [] where
+// "," "]" "}" end a plain scalar.
+func (f *flowScanner) value(inFlow bool) (any, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '{':
+		return f.flowMap()
+	case '[':
+		return f.flowSeq()
+	case '"', '\'':
+		return f.quoted()
+	case '&', '*', '!':
+		return nil, fmt.Errorf("不支持 YAML anchor / alias / tag: %q", f.s[f.i:])
+	}
+	start := f.i
+	if !inFlow {
+		f.i = len(f.s)
+		return yamlResolve(strings.TrimSpace(f.s[start:])), nil
+	}
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+		f.i++
+	}
+	return yamlResolve(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *flowScanner) flowSeq() (any, error) {
+	f.i++ // [
+	out := []any{}
+	for {
+		f.skipSpace()
+		if f.i >= len(f.s) {
+			return nil, fmt.Errorf("[ 没有闭合")
+		}
+		if f.s[f.i] == ']' {
+			f.i++
+			return out, nil
+		}
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		if err := f.flowSep(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flowScanner) flowMap() (any, error) {
+	f.i++ // {
+	out := map[string]any{}
+	for {
+		f.skipSpace()
+		if f.i >= len(f.s) {
+			return nil, fmt.Errorf("{ 没有闭合")
+		}
+		if f.s[f.i] == '}' {
+			f.i++
+			return out, nil
+		}
+		key, err := f.flowKey()
+		if err != nil {
+			return nil, err
+		}
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+		if err := f.flowSep('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// flowKey reads "key:" inside {}. 值里可以有冒号 (http://..)，key 以
+// 后面跟空格 / , / } 的冒号结束。
+func (f *flowScanner) flowKey() (string, error) {
+	if c := f.s[f.i]; c == '"' || c == '\'' {
+		k, err := f.quoted()
+		if err != nil {
+			return "", err
+		}
+		f.skipSpace()
+		if f.i >= len(f.s) || f.s[f.i] != ':' {
+			return "", fmt.Errorf("%q 后面缺少冒号", k)
+		}
+		f.i++
+		return k, nil
+	}
+	start := f.i
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if c == ',' || c == '}' {
+			break
+		}
+		if c == ':' && (f.i+1 == len(f.s) || strings.ContainsRune(" ,}", rune(f.s[f.i+1]))) {
+			k := strings.TrimSpace(f.s[start:f.i])
+			f.i++
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("应为 key: value: %q", f.s[start:f.i])
+}
+
+// flowSep consumes the "," between items, or stops before the closer.
+func (f *flowScanner) flowSep(closer byte) error {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return fmt.Errorf("%c 没有闭合", closer)
+	}
+	switch f.s[f.i] {
+	case ',':
+		f.i++
+		return nil
+	case closer:
+		return nil
+	}
+	return fmt.Errorf("应为 , 或 %c: %q", closer, f.s[f.i:])
+}
+
+func (f *flowScanner) quoted() (string, error) {
+	q := f.s[f.i]
+	start := f.i
+	for j := f.i + 1; j < len(f.s); j++ {
+		c := f.s[j]
+		if q == '"' && c == '\\' {
+			j++
+			continue
+		}
+		if c != q {
+			continue
+		}
+		if q == '\'' && j+1 < len(f.s) && f.s[j+1] == '\'' {
+			j++ // '' 是转义的单引号
+			continue
+		}
+		f.i = j + 1
+		raw := f.s[start:f.i]
+		if q == '\'' {
+			return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+		}
+		if s, err := strconv.Unquote(raw); err == nil {
+			return s, nil
+		}
+		return raw[1 : len(raw)-1], nil // Go 不认的转义 (\/ 之类) 原样保留
+	}
+	return "", fmt.Errorf("引号没有闭合: %s", f.s[start:])
+}
+
+// yamlResolve types a plain scalar the way YAML 1.2 core schema would, minus
+// floats (配置里的数字都是端口之类的整数，其余保留字符串)。
+func yamlResolve(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return s
+}
@@ -1,11 +1,9 @@
 package install
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
 
 	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
@@ -16,11 +14,10 @@ import (
 // =========================================
 
 const (
-	AnyTLSConfigDir       = "/etc/anytls"
-	AnyTLSConfigPath      = "/etc/anytls/config.json"
-	AnyTLSProxyConfigPath = "/etc/anytls-proxy-config.txt"
-	AnyTLSCertPath        = "/etc/anytls/server.crt"
-	AnyTLSKeyPath         = "/etc/anytls/server.key"
+	AnyTLSConfigDir  = "/etc/anytls"
+	AnyTLSConfigPath = "/etc/anytls/config.json"
+	AnyTLSCertPath   = "/etc/anytls/server.crt"
+	AnyTLSKeyPath    = "/etc/anytls/server.key"
 )
 
 // AnyTLSConfig AnyTLS 配置
@@ -33,6 +30,7 @@ type AnyTLSConfig struct {
 	PaddingScheme string
 	PaddingName   string
 	SingboxVer    string
+	Users         []store.User
 }
 
 // 填充方案定义
@@ -70,7 +68,7 @@ func InstallAnyTLS() (*InstallResult, error) {
 	utils.PrintInfo("开始安装 AnyTLS (sing-box 内核)...")
 
 	// 检查是否已安装
-	if utils.FileExists(AnyTLSProxyConfigPath) {
+	if IsAnyTLSInstalled() {
 		if !utils.PromptConfirm("AnyTLS 已安装，是否重新安装？") {
 			return nil, fmt.Errorf("安装已取消")
 		}
@@ -158,8 +156,9 @@ func InstallAnyTLS() (*InstallResult, error) {
 		return nil, fmt.Errorf("证书安装失败: %v", err)
 	}
 
-	// 创建 sing-box 配置
-	if err := createAnyTLSSingboxConfig(config, padding.Scheme); err != nil {
+	// 创建 sing-box 配置 (padding scheme 按 PaddingName 从 PaddingSchemes 反查)
+	node := storeNodeFromAnyTLS(config)
+	if err := writeNodeConfig(node); err != nil {
 		return nil, fmt.Errorf("创建配置失败: %v", err)
 	}
 
@@ -178,8 +177,8 @@ func InstallAnyTLS() (*InstallResult, error) {
 	}
 
 	// 保存配置
-	saveAnyTLSConfig(config)
-	upsertNode(storeNodeFromAnyTLS(config))
+	upsertNode(node)
+	recordKernelVersion(store.KernelSingbox, singboxVersion)
 
 	// 生成客户端配置
 	surgeProxy := fmt.Sprintf(
@@ -250,22 +249,20 @@ func installCertToAnyTLS(domain string) error {
 	return InstallCertForService(domain, "anytls", AnyTLSKeyPath, AnyTLSCertPath)
 }
 
-// createAnyTLSSingboxConfig 创建 sing-box 配置
-func createAnyTLSSingboxConfig(cfg AnyTLSConfig, paddingScheme []string) error {
-	config := map[string]interface{}{
+// anyTLSServerConfig 出 AnyTLS 的 sing-box 服务端配置
+func anyTLSServerConfig(cfg AnyTLSConfig, paddingScheme []string) map[string]interface{} {
+	return map[string]interface{}{
 		"log": map[string]interface{}{
 			"level":     "info",
 			"timestamp": true,
 		},
 		"inbounds": []map[string]interface{}{
 			{
-				"type":        "anytls",
-				"tag":         "anytls-in",
-				"listen":      "::",
-				"listen_port": cfg.Port,
-				"users": []map[string]interface{}{
-					{"password": cfg.Password},
-				},
+				"type":           "anytls",
+				"tag":            "anytls-in",
+				"listen":         "::",
+				"listen_port":    cfg.Port,
+				"users":          singboxUsers(cfg.Password, cfg.Users),
 				"padding_scheme": paddingScheme,
 				"tls": map[string]interface{}{
 					"enabled":          true,
@@ -279,24 +276,6 @@ func createAnyTLSSingboxConfig(cfg AnyTLSConfig, paddingScheme []string) error {
 			{"type": "direct", "tag": "direct"},
 		},
 	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	if err := utils.WriteFile(AnyTLSConfigPath, string(data), PermConfigFile); err != nil {
-		return err
-	}
-
-	// 验证配置
-	cmd := exec.Command(SingboxBinaryPath, "check", "-c", AnyTLSConfigPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("配置验证失败: %s", string(output))
-	}
-
-	utils.PrintSuccess("配置文件创建成功")
-	return nil
 }
 
 func createAnyTLSService() error {
@@ -311,21 +290,6 @@ func createAnyTLSService() error {
 	})
 }
 
-func saveAnyTLSConfig(cfg AnyTLSConfig) {
-	config := map[string]string{
-		"TYPE":            "anytls",
-		"SERVER_IP":       cfg.ServerIP,
-		"IP_VERSION":      cfg.IPVersion,
-		"SINGBOX_VERSION": cfg.SingboxVer,
-		"ANYTLS_PORT":     strconv.Itoa(cfg.Port),
-		"ANYTLS_PASSWORD": cfg.Password,
-		"ANYTLS_DOMAIN":   cfg.Domain,
-		"CERT_TYPE":       "letsencrypt",
-		"PADDING_NAME":    cfg.PaddingName,
-	}
-	SaveConfigFile(AnyTLSProxyConfigPath, config)
-}
-
 func printAnyTLSSuccess(cfg AnyTLSConfig, surgeProxy string) {
 	fmt.Println()
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
@@ -350,12 +314,12 @@ func printAnyTLSSuccess(cfg AnyTLSConfig, surgeProxy string) {
 
 // ViewAnyTLSConfig 查看 AnyTLS 配置
 func ViewAnyTLSConfig() {
-	if !utils.FileExists(AnyTLSProxyConfigPath) {
+	n, ok := installedNode(store.TypeAnyTLS)
+	if !ok {
 		utils.PrintError("AnyTLS 未安装")
 		return
 	}
-
-	config, err := ParseConfigFile(AnyTLSProxyConfigPath)
+	cfg, err := anyTLSConfigFromNode(n)
 	if err != nil {
 		utils.PrintError("读取配置失败: %v", err)
 		return
@@ -365,23 +329,15 @@ func ViewAnyTLSConfig() {
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s   AnyTLS 配置 (sing-box 内核)%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
-	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SERVER_IP"])
-	fmt.Printf("%s域名:%s %s\n", utils.ColorCyan, utils.ColorReset, config["ANYTLS_DOMAIN"])
-	fmt.Printf("%s端口:%s %s\n", utils.ColorCyan, utils.ColorReset, config["ANYTLS_PORT"])
-	if paddingName := config["PADDING_NAME"]; paddingName != "" {
-		fmt.Printf("%s填充方案:%s %s\n", utils.ColorCyan, utils.ColorReset, paddingName)
+	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ServerIP)
+	fmt.Printf("%s域名:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.Domain)
+	fmt.Printf("%s端口:%s %d\n", utils.ColorCyan, utils.ColorReset, cfg.Port)
+	if cfg.PaddingName != "" {
+		fmt.Printf("%s填充方案:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.PaddingName)
 	}
-	fmt.Printf("%sSing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SINGBOX_VERSION"])
-	fmt.Println()
-
-	surgeProxy := fmt.Sprintf(
-		"AnyTLS = anytls, %s, %s, password=%s, sni=%s",
-		config["ANYTLS_DOMAIN"], config["ANYTLS_PORT"], config["ANYTLS_PASSWORD"], config["ANYTLS_DOMAIN"],
-	)
-	fmt.Printf("%sSurge:%s\n", utils.ColorCyan, utils.ColorReset)
-	fmt.Printf("%s%s%s\n", utils.ColorGreen, surgeProxy, utils.ColorReset)
+	fmt.Printf("%sSing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, kernelVersion(store.KernelSingbox))
 	fmt.Println()
-	PrintAdditionalFormatsForType(store.TypeAnyTLS)
+	PrintClientFormatsForType(store.TypeAnyTLS)
 }
 
 // =========================================
@@ -390,16 +346,11 @@ func ViewAnyTLSConfig() {
 
 // UpdateAnyTLS 更新 AnyTLS (sing-box 内核)
 func UpdateAnyTLS() error {
-	if !utils.FileExists(AnyTLSProxyConfigPath) {
+	if !IsAnyTLSInstalled() {
 		return fmt.Errorf("AnyTLS 未安装")
 	}
 
-	config, err := ParseConfigFile(AnyTLSProxyConfigPath)
-	if err != nil {
-		return err
-	}
-
-	currentVersion := config["SINGBOX_VERSION"]
+	currentVersion := kernelVersion(store.KernelSingbox)
 	latestVersion := utils.GetLatestVersion("SagerNet/sing-box", utils.DefaultSingboxVersion)
 
 	fmt.Printf("%s当前 sing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, currentVersion)
@@ -428,8 +379,7 @@ func UpdateAnyTLS() error {
 		return fmt.Errorf("更新失败: %v", err)
 	}
 
-	config["SINGBOX_VERSION"] = latestVersion
-	SaveConfigFile(AnyTLSProxyConfigPath, config)
+	recordKernelVersion(store.KernelSingbox, latestVersion)
 
 	utils.ServiceStart("anytls")
 
@@ -444,7 +394,15 @@ func UpdateAnyTLS() error {
 
 // RenewAnyTLSCert 续签 AnyTLS 证书
 func RenewAnyTLSCert() error {
-	return RenewCertForService("anytls", AnyTLSProxyConfigPath, "ANYTLS_DOMAIN", AnyTLSKeyPath, AnyTLSCertPath)
+	n, ok := installedNode(store.TypeAnyTLS)
+	if !ok {
+		return fmt.Errorf("anytls 未安装")
+	}
+	cfg, err := anyTLSConfigFromNode(n)
+	if err != nil {
+		return err
+	}
+	return RenewCertForService("anytls", cfg.Domain, AnyTLSKeyPath, AnyTLSCertPath)
 }
 
 // =========================================
@@ -455,16 +413,12 @@ func RenewAnyTLSCert() error {
 func UninstallAnyTLS() error {
 	utils.PrintInfo("正在卸载 AnyTLS...")
 
-	// 读取配置以获取域名
-	if utils.FileExists(AnyTLSProxyConfigPath) {
-		config, _ := ParseConfigFile(AnyTLSProxyConfigPath)
-		domain := config["ANYTLS_DOMAIN"]
-		certType := config["CERT_TYPE"]
-
-		if certType == "letsencrypt" && domain != "" {
+	// 证书是 Let's Encrypt 申请的，域名在节点 params 里
+	if n, ok := installedNode(store.TypeAnyTLS); ok {
+		if cfg, err := anyTLSConfigFromNode(n); err == nil && cfg.Domain != "" {
 			if utils.PromptConfirm("是否删除证书？") {
 				acmePath := os.Getenv("HOME") + "/.acme.sh/acme.sh"
-				exec.Command(acmePath, "--remove", "-d", domain, "--ecc").Run()
+				exec.Command(acmePath, "--remove", "-d", cfg.Domain, "--ecc").Run()
 			}
 		}
 	}
@@ -472,13 +426,12 @@ func UninstallAnyTLS() error {
 	RemoveSystemdService("anytls")
 
 	os.RemoveAll(AnyTLSConfigDir)
-	os.Remove(AnyTLSProxyConfigPath)
 	removeNodeByType(store.TypeAnyTLS)
 
 	utils.DeleteSystemUser("anytls")
 
 	// 如果没有其他服务使用 sing-box，删除二进制
-	if !IsSingboxShared(AnyTLSProxyConfigPath) {
+	if !IsSingboxShared(store.TypeAnyTLS) {
 		os.Remove(SingboxBinaryPath)
 		utils.DeleteSystemUser("sing-box")
 	} else {
@@ -489,7 +442,8 @@ func UninstallAnyTLS() error {
 	return nil
 }
 
-// IsAnyTLSInstalled 检查是否已安装
+// IsAnyTLSInstalled 检查是否已安装 (nodes.json 里有 AnyTLS 节点)
 func IsAnyTLSInstalled() bool {
-	return utils.FileExists(AnyTLSProxyConfigPath)
+	_, ok := installedNode(store.TypeAnyTLS)
+	return ok
 }
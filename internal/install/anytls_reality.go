@@ -1,10 +1,8 @@
 package install
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"strconv"
 
 	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
@@ -17,29 +15,28 @@ import (
 // QuantumultX 测试版，不兼容 Surge / 旧 xray-only 客户端。
 
 const (
-	AnyTLSRealityConfigDir       = "/etc/anytls-reality"
-	AnyTLSRealityConfigPath      = "/etc/anytls-reality/config.json"
-	AnyTLSRealityProxyConfigPath = "/etc/anytls-reality-proxy-config.txt"
-	AnyTLSRealityServiceName     = "anytls-reality"
+	AnyTLSRealityConfigDir   = "/etc/anytls-reality"
+	AnyTLSRealityConfigPath  = "/etc/anytls-reality/config.json"
+	AnyTLSRealityServiceName = "anytls-reality"
 )
 
 type AnyTLSRealityConfig struct {
-	ServerIP       string
-	IPVersion      string
-	Port           int
-	Password       string
-	PrivateKey     string
-	PublicKey      string
-	ShortID        string
-	ServerName     string
-	Padding        string
-	SingboxVersion string
+	ServerIP   string
+	IPVersion  string
+	Port       int
+	Password   string
+	PrivateKey string
+	PublicKey  string
+	ShortID    string
+	ServerName string
+	Padding    string
+	Users      []store.User
 }
 
 func InstallAnyTLSReality() (*InstallResult, error) {
 	utils.PrintInfo("开始安装 AnyTLS + Reality (sing-box 内核)...")
 
-	if utils.FileExists(AnyTLSRealityProxyConfigPath) {
+	if IsAnyTLSRealityInstalled() {
 		if !utils.PromptConfirm("AnyTLS+Reality 已安装，重新安装？") {
 			return nil, fmt.Errorf("已取消")
 		}
@@ -67,6 +64,7 @@ func InstallAnyTLSReality() (*InstallResult, error) {
 		if err := downloadSingbox(singboxVersion, arch); err != nil {
 			return nil, fmt.Errorf("下载 sing-box 失败: %v", err)
 		}
+		recordKernelVersion(store.KernelSingbox, singboxVersion)
 	}
 	if !utils.FileExists(XrayBinaryPath) {
 		if err := downloadXray(utils.GetLatestVersion("XTLS/Xray-core", DefaultXrayVersion), arch); err != nil {
@@ -85,23 +83,20 @@ func InstallAnyTLSReality() (*InstallResult, error) {
 	shortID := generateShortID()
 
 	cfg := AnyTLSRealityConfig{
-		ServerIP:       serverIP,
-		IPVersion:      ipVersion,
-		Port:           port,
-		Password:       password,
-		PrivateKey:     kp.PrivateKey,
-		PublicKey:      kp.PublicKey,
-		ShortID:        shortID,
-		ServerName:     serverName,
-		Padding:        "default",
-		SingboxVersion: singboxVersion,
+		ServerIP:   serverIP,
+		IPVersion:  ipVersion,
+		Port:       port,
+		Password:   password,
+		PrivateKey: kp.PrivateKey,
+		PublicKey:  kp.PublicKey,
+		ShortID:    shortID,
+		ServerName: serverName,
+		Padding:    "default",
 	}
 
-	if err := os.MkdirAll(AnyTLSRealityConfigDir, 0755); err != nil {
-		return nil, err
-	}
 	utils.CreateSystemUser("anytls-reality")
-	if err := createAnyTLSRealityConfig(cfg); err != nil {
+	node := storeNodeFromAnyTLSReality(cfg)
+	if err := writeNodeConfig(node); err != nil {
 		return nil, fmt.Errorf("创建 sing-box config 失败: %v", err)
 	}
 	if err := createAnyTLSRealityService(); err != nil {
@@ -112,8 +107,7 @@ func InstallAnyTLSReality() (*InstallResult, error) {
 	if !utils.VerifyServiceStarted(AnyTLSRealityServiceName, 10) {
 		return nil, fmt.Errorf("服务启动失败 (查 journalctl -u %s)", AnyTLSRealityServiceName)
 	}
-	saveAnyTLSRealityConfig(cfg)
-	upsertNode(storeNodeFromAnyTLSReality(cfg))
+	upsertNode(node)
 
 	fmt.Println()
 	utils.PrintSuccess("安装完成！")
@@ -132,8 +126,9 @@ func InstallAnyTLSReality() (*InstallResult, error) {
 	}, nil
 }
 
-func createAnyTLSRealityConfig(cfg AnyTLSRealityConfig) error {
-	config := map[string]any{
+// anyTLSRealityServerConfig 出 AnyTLS+Reality 的 sing-box 服务端配置
+func anyTLSRealityServerConfig(cfg AnyTLSRealityConfig) map[string]any {
+	return map[string]any{
 		"log": map[string]any{"level": "info", "timestamp": true},
 		"inbounds": []map[string]any{
 			{
@@ -141,9 +136,7 @@ func createAnyTLSRealityConfig(cfg AnyTLSRealityConfig) error {
 				"tag":         "anytls-reality-in",
 				"listen":      "::",
 				"listen_port": cfg.Port,
-				"users": []map[string]any{
-					{"name": "user1", "password": cfg.Password},
-				},
+				"users":       singboxUsers(cfg.Password, cfg.Users),
 				"tls": map[string]any{
 					"enabled":     true,
 					"server_name": cfg.ServerName,
@@ -161,11 +154,6 @@ func createAnyTLSRealityConfig(cfg AnyTLSRealityConfig) error {
 		},
 		"outbounds": []map[string]any{{"type": "direct", "tag": "direct"}},
 	}
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
-	return utils.WriteFile(AnyTLSRealityConfigPath, string(data), PermConfigFile)
 }
 
 func createAnyTLSRealityService() error {
@@ -178,22 +166,6 @@ func createAnyTLSRealityService() error {
 	})
 }
 
-func saveAnyTLSRealityConfig(cfg AnyTLSRealityConfig) {
-	kv := map[string]string{
-		"TYPE":            "anytls-reality",
-		"SERVER_IP":       cfg.ServerIP,
-		"IP_VERSION":      cfg.IPVersion,
-		"PORT":            strconv.Itoa(cfg.Port),
-		"PASSWORD":        cfg.Password,
-		"PRIVATE_KEY":     cfg.PrivateKey,
-		"PUBLIC_KEY":      cfg.PublicKey,
-		"SHORT_ID":        cfg.ShortID,
-		"SERVER_NAME":     cfg.ServerName,
-		"SINGBOX_VERSION": cfg.SingboxVersion,
-	}
-	SaveConfigFile(AnyTLSRealityProxyConfigPath, kv)
-}
-
 func storeNodeFromAnyTLSReality(cfg AnyTLSRealityConfig) store.Node {
 	return store.Node{
 		ID:     fmt.Sprintf("anytls-reality-%s", cfg.ServerIP),
@@ -203,10 +175,12 @@ func storeNodeFromAnyTLSReality(cfg AnyTLSRealityConfig) store.Node {
 		Port:   cfg.Port,
 		Params: map[string]any{
 			"password":    cfg.Password,
+			"private_key": cfg.PrivateKey,
 			"public_key":  cfg.PublicKey,
 			"short_id":    cfg.ShortID,
 			"server_name": cfg.ServerName,
 		},
+		Users: cfg.Users,
 	}
 }
 
@@ -214,7 +188,6 @@ func UninstallAnyTLSReality() error {
 	utils.PrintInfo("卸载 AnyTLS+Reality...")
 	RemoveSystemdService(AnyTLSRealityServiceName)
 	os.RemoveAll(AnyTLSRealityConfigDir)
-	os.Remove(AnyTLSRealityProxyConfigPath)
 	removeNodeByType(store.TypeAnyTLSReality)
 	utils.DeleteSystemUser("anytls-reality")
 	utils.PrintSuccess("已卸载")
@@ -222,19 +195,20 @@ func UninstallAnyTLSReality() error {
 }
 
 func IsAnyTLSRealityInstalled() bool {
-	return utils.FileExists(AnyTLSRealityProxyConfigPath)
+	_, ok := installedNode(store.TypeAnyTLSReality)
+	return ok
 }
 
 // ViewAnyTLSRealityConfig 查看 AnyTLS+Reality 配置。Surge 不支持这个组合，
-// 所以只打印基础参数 + 委托 PrintAdditionalFormatsForType 输出 sing-box /
-// mihomo / QX 行。
+// 所以只打印基础参数 + 委托 PrintClientFormatsForType 输出其余客户端
+// 格式 (sing-box / mihomo / QX ...)。
 func ViewAnyTLSRealityConfig() {
-	if !utils.FileExists(AnyTLSRealityProxyConfigPath) {
+	n, ok := installedNode(store.TypeAnyTLSReality)
+	if !ok {
 		utils.PrintError("AnyTLS + Reality 未安装")
 		return
 	}
-
-	config, err := ParseConfigFile(AnyTLSRealityProxyConfigPath)
+	cfg, err := anyTLSRealityConfigFromNode(n)
 	if err != nil {
 		utils.PrintError("读取配置失败: %v", err)
 		return
@@ -244,15 +218,15 @@ func ViewAnyTLSRealityConfig() {
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s   AnyTLS + Reality 配置 (sing-box 内核)%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
-	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SERVER_IP"])
-	fmt.Printf("%s端口:%s %s\n", utils.ColorCyan, utils.ColorReset, config["PORT"])
-	fmt.Printf("%s密码:%s %s\n", utils.ColorCyan, utils.ColorReset, config["PASSWORD"])
-	fmt.Printf("%sSNI 目标:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SERVER_NAME"])
-	fmt.Printf("%sPublicKey:%s %s\n", utils.ColorCyan, utils.ColorReset, config["PUBLIC_KEY"])
-	fmt.Printf("%sShortID:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SHORT_ID"])
-	fmt.Printf("%sSing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SINGBOX_VERSION"])
+	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ServerIP)
+	fmt.Printf("%s端口:%s %d\n", utils.ColorCyan, utils.ColorReset, cfg.Port)
+	fmt.Printf("%s密码:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.Password)
+	fmt.Printf("%sSNI 目标:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ServerName)
+	fmt.Printf("%sPublicKey:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.PublicKey)
+	fmt.Printf("%sShortID:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ShortID)
+	fmt.Printf("%sSing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, kernelVersion(store.KernelSingbox))
 	fmt.Println()
 	fmt.Printf("%s注:%s Surge 暂不支持 AnyTLS+Reality，请用 sing-box / mihomo / QuantumultX 客户端。\n", utils.ColorYellow, utils.ColorReset)
 	fmt.Println()
-	PrintAdditionalFormatsForType(store.TypeAnyTLSReality)
+	PrintClientFormatsForType(store.TypeAnyTLSReality)
 }
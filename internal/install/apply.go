@@ -0,0 +1,159 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// ApplyNode 按 nodes.json 里的节点重新渲染对应内核 config 并只重启这一个
+// unit。多用户增删、reality edit、store restore 都走这里——其他协议的服务
+// 不受影响，在线的其他用户最多断一次重连。
+func ApplyNode(n store.Node) error {
+	if n.External {
+		return ErrExternalNode
+	}
+	if err := writeNodeConfig(n); err != nil {
+		return err
+	}
+
+	unit := serviceNameFor(n.Type)
+	if n.Disabled {
+		return nil // 下线维护中：config 写好，等 node enable 再起
+	}
+	if err := utils.ServiceRestart(unit); err != nil {
+		return fmt.Errorf("配置已更新但重启服务失败: %w (建议手工 systemctl restart %s)", err, unit)
+	}
+	return nil
+}
+
+// ErrExternalNode: node import 进来的节点不在本机，没有内核 config 可写。
+var ErrExternalNode = errors.New("外部节点不在本机，只能修改 tags / 备注 / 上下线")
+
+// serviceNameFor 节点类型 → systemd unit 名。
+func serviceNameFor(t store.NodeType) string {
+	switch t {
+	case store.TypeVLESSReality:
+		return RealityServiceName
+	case store.TypeAnyTLSReality:
+		return AnyTLSRealityServiceName
+	default:
+		return string(t) // hysteria2 / anytls 的 unit 名就是类型名
+	}
+}
+
+// NodeUnitInstalled reports whether the systemd unit for t exists, i.e. the
+// protocol is installed on this machine (as opposed to only being recorded
+// in nodes.json, e.g. after an uninstall or in a restored snapshot).
+func NodeUnitInstalled(t store.NodeType) bool {
+	return utils.FileExists(fmt.Sprintf("%s/%s.service", SystemdPath, serviceNameFor(t)))
+}
+
+// --- store.Node → 各协议 install 结构 -------------------------------------
+
+// 这几个 builder 走 store.Node.DecodeParams，params 缺字段 / 类型不对时报错，
+// 不会写出一份 uuid 为空的内核 config。
+
+func realityConfigFromNode(n store.Node) (RealityConfig, error) {
+	d, err := n.DecodeParams()
+	if err != nil {
+		return RealityConfig{}, err
+	}
+	p := d.(*store.RealityParams)
+	if p.PrivateKey == "" {
+		return RealityConfig{}, fmt.Errorf("节点 %s 缺少 private_key，无法重建 config", n.ID)
+	}
+	return RealityConfig{
+		ServerIP:   n.Server,
+		IPVersion:  ipVersionOf(n.Server),
+		Port:       n.Port,
+		UUID:       p.UUID,
+		PrivateKey: p.PrivateKey,
+		PublicKey:  p.PublicKey,
+		ShortID:    p.ShortID,
+		ServerName: p.ServerName,
+		Users:      n.Users,
+	}, nil
+}
+
+func hysteria2ConfigFromNode(n store.Node) (Hysteria2Config, error) {
+	d, err := n.DecodeParams()
+	if err != nil {
+		return Hysteria2Config{}, err
+	}
+	p := d.(*store.Hysteria2Params)
+	return Hysteria2Config{
+		ServerIP:     n.Server,
+		IPVersion:    ipVersionOf(n.Server),
+		Port:         n.Port,
+		Password:     p.Password,
+		Domain:       p.Domain,
+		EnableObfs:   p.EnableObfs,
+		ObfsPassword: p.ObfsPassword,
+		Users:        n.Users,
+	}, nil
+}
+
+func anyTLSConfigFromNode(n store.Node) (AnyTLSConfig, error) {
+	d, err := n.DecodeParams()
+	if err != nil {
+		return AnyTLSConfig{}, err
+	}
+	p := d.(*store.AnyTLSParams)
+	return AnyTLSConfig{
+		ServerIP:    n.Server,
+		IPVersion:   ipVersionOf(n.Server),
+		Port:        n.Port,
+		Password:    p.Password,
+		Domain:      p.Domain,
+		PaddingName: p.PaddingName,
+		Users:       n.Users,
+	}, nil
+}
+
+func anyTLSRealityConfigFromNode(n store.Node) (AnyTLSRealityConfig, error) {
+	d, err := n.DecodeParams()
+	if err != nil {
+		return AnyTLSRealityConfig{}, err
+	}
+	p := d.(*store.AnyTLSRealityParams)
+	cfg := AnyTLSRealityConfig{
+		ServerIP:   n.Server,
+		IPVersion:  ipVersionOf(n.Server),
+		Port:       n.Port,
+		Password:   p.Password,
+		PrivateKey: p.PrivateKey,
+		PublicKey:  p.PublicKey,
+		ShortID:    p.ShortID,
+		ServerName: p.ServerName,
+		Users:      n.Users,
+	}
+	// v4.0.25 起装的节点 nodes.json 里一直没存 private_key，只在 .txt 里；
+	// LoadOrMigrate 导入 .txt 时会补上。还缺说明 .txt 也早没了。
+	if cfg.PrivateKey == "" {
+		return AnyTLSRealityConfig{}, fmt.Errorf("节点 %s 缺少 private_key，无法重建 config", n.ID)
+	}
+	return cfg, nil
+}
+
+// paddingSchemeByName 把 nodes.json 里存的中文显示名 ("默认") 反查回
+// PaddingSchemes 的 scheme；查不到用 default，跟安装时的兜底一致。
+func paddingSchemeByName(name string) []string {
+	for _, p := range PaddingSchemes {
+		if p.Name == name {
+			return p.Scheme
+		}
+	}
+	return PaddingSchemes["default"].Scheme
+}
+
+// ipVersionOf 由地址推出 IP 版本 ("4" / "6")，跟 utils.GetServerIP 一致。
+func ipVersionOf(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "6"
+	}
+	return "4"
+}
@@ -1,7 +1,6 @@
 package install
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -23,43 +22,40 @@ const (
 	PermConfigFile = 0644 // 配置文件 (服务用户可读)
 	PermKeyFile    = 0600 // 密钥文件 (仅所有者可读)
 	PermCertFile   = 0644 // 证书文件 (公开可读)
-	PermProxyConf  = 0600 // 代理配置文件 (含密码)
 )
 
 // =========================================
 // 通用证书管理
 // =========================================
 
-// PrintAdditionalFormatsForType 给查看配置流程在 Surge 行之下追加 Mihomo +
-// QuantumultX 两段（Mihomo 是 Clash.Meta 接班，accept 标准 Clash YAML；QX
-// iOS 主流）。失败静默。
-func PrintAdditionalFormatsForType(t store.NodeType) {
+// PrintClientFormatsForType 给查看配置流程输出该类型节点的分享链接，再按
+// format 注册表逐个输出客户端配置 (Surge / Clash / sing-box / ...)，和
+// export、订阅 URL 是同一套格式。节点不支持的格式跳过，失败静默。
+func PrintClientFormatsForType(t store.NodeType) {
 	s, err := store.Load()
 	if err != nil || s == nil {
 		return
 	}
-	var node *store.Node
-	for i := range s.Nodes {
-		if s.Nodes[i].Type == t {
-			node = &s.Nodes[i]
-			break
-		}
-	}
-	if node == nil {
+	node, ok := s.LocalNode(t)
+	if !ok {
 		return
 	}
-	if entry, err := format.ToClash(node); err == nil {
-		// Clash/Mihomo 用 JSON 一行输出，方便用户直接粘到 proxies: 数组里
-		if data, err := json.Marshal(entry); err == nil {
-			fmt.Printf("%sMihomo / Clash.Meta:%s\n%s%s%s\n\n",
-				utils.ColorCyan, utils.ColorReset,
-				utils.ColorGreen, string(data), utils.ColorReset)
-		}
-	}
-	if qx, err := format.ToQX(node); err == nil && qx != "" {
-		fmt.Printf("%sQuantumultX:%s\n%s%s%s\n\n",
+	if share, err := format.ShareURL(node); err == nil {
+		fmt.Printf("%s分享链接:%s\n%s%s%s\n\n",
 			utils.ColorCyan, utils.ColorReset,
-			utils.ColorGreen, qx, utils.ColorReset)
+			utils.ColorGreen, share, utils.ColorReset)
+	}
+	for _, f := range format.All() {
+		if !f.Supports(node) {
+			continue
+		}
+		var buf strings.Builder
+		if err := f.Render(&buf, []store.Node{*node}, format.Options{}); err != nil {
+			continue
+		}
+		fmt.Printf("%s%s:%s\n%s%s%s\n\n",
+			utils.ColorCyan, f.Name(), utils.ColorReset,
+			utils.ColorGreen, strings.TrimSpace(buf.String()), utils.ColorReset)
 	}
 }
 
@@ -219,18 +215,8 @@ func InstallCertForService(domain, serviceName, keyPath, certPath string) error
 	return nil
 }
 
-// RenewCertForService 续签指定服务的证书
-func RenewCertForService(serviceName, configPath, domainKey, keyPath, certPath string) error {
-	if !utils.FileExists(configPath) {
-		return fmt.Errorf("%s 未安装", serviceName)
-	}
-
-	config, err := ParseConfigFile(configPath)
-	if err != nil {
-		return err
-	}
-
-	domain := config[domainKey]
+// RenewCertForService 续签指定服务的证书。domain 由调用方从节点 params 里取。
+func RenewCertForService(serviceName, domain, keyPath, certPath string) error {
 	if domain == "" {
 		return fmt.Errorf("未找到域名配置")
 	}
@@ -255,72 +241,31 @@ func RenewCertForService(serviceName, configPath, domainKey, keyPath, certPath s
 	return nil
 }
 
-// IsSingboxShared 检查是否有其他服务还在使用 sing-box 二进制
-// excludeConfigs 为当前正在卸载的服务的配置路径，应排除在检查之外
+// IsSingboxShared 检查 nodes.json 里是否还有其他 sing-box 协议的节点。
+// exclude 为当前正在卸载的协议，应排除在检查之外。
 //
 // 注：v4.0.26 删 SS-2022+STLS / Snell+STLS 后，sing-box 仅供 Hysteria2 /
 // AnyTLS / AnyTLS+Reality 用；Reality 已切 xray，不在此列表里。
-func IsSingboxShared(excludeConfigs ...string) bool {
-	allConfigs := []string{
-		Hysteria2ProxyConfigPath,
-		AnyTLSProxyConfigPath,
-		AnyTLSRealityProxyConfigPath,
+func IsSingboxShared(exclude ...store.NodeType) bool {
+	s, err := store.LoadOrMigrate()
+	if err != nil {
+		return true // 读不到就保守地留着 binary
 	}
-
-	excluded := make(map[string]bool)
-	for _, c := range excludeConfigs {
-		excluded[c] = true
+	excluded := make(map[store.NodeType]bool)
+	for _, t := range exclude {
+		excluded[t] = true
 	}
-
-	for _, cfg := range allConfigs {
-		if excluded[cfg] {
+	for _, n := range s.Nodes {
+		if excluded[n.Type] || n.External {
 			continue
 		}
-		if utils.FileExists(cfg) {
+		if store.KernelFor(n.Type) == store.KernelSingbox {
 			return true
 		}
 	}
 	return false
 }
 
-// =========================================
-// 配置文件解析
-// =========================================
-
-// ParseConfigFile 解析配置文件 (KEY=VALUE 格式)
-func ParseConfigFile(path string) (map[string]string, error) {
-	content, err := utils.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	config := make(map[string]string)
-	lines := strings.Split(content, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			config[parts[0]] = parts[1]
-		}
-	}
-
-	return config, nil
-}
-
-// SaveConfigFile 保存配置文件
-func SaveConfigFile(path string, config map[string]string) error {
-	var lines []string
-	for k, v := range config {
-		lines = append(lines, fmt.Sprintf("%s=%s", k, v))
-	}
-	return utils.WriteFile(path, strings.Join(lines, "\n")+"\n", PermProxyConf)
-}
-
 // =========================================
 // Systemd 服务创建
 // =========================================
@@ -364,6 +309,9 @@ ExecStart=%s
 Restart=always
 RestartSec=10s
 
+# 流量计数 (订阅的 Subscription-Userinfo 从这里取已用流量)
+IPAccounting=yes
+
 # 安全加固
 NoNewPrivileges=true
 ProtectSystem=strict
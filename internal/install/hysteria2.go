@@ -1,13 +1,12 @@
 package install
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
 	"strings"
 
+	"github.com/Mamaaz/proxy-manager/internal/format"
 	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
 )
@@ -17,11 +16,10 @@ import (
 // =========================================
 
 const (
-	Hysteria2ConfigDir       = "/etc/hysteria2"
-	Hysteria2ConfigPath      = "/etc/hysteria2/config.json"
-	Hysteria2ProxyConfigPath = "/etc/hysteria2-proxy-config.txt"
-	Hysteria2CertPath        = "/etc/hysteria2/server.crt"
-	Hysteria2KeyPath         = "/etc/hysteria2/server.key"
+	Hysteria2ConfigDir  = "/etc/hysteria2"
+	Hysteria2ConfigPath = "/etc/hysteria2/config.json"
+	Hysteria2CertPath   = "/etc/hysteria2/server.crt"
+	Hysteria2KeyPath    = "/etc/hysteria2/server.key"
 )
 
 // Hysteria2Config Hysteria2 配置
@@ -34,6 +32,7 @@ type Hysteria2Config struct {
 	EnableObfs   bool
 	ObfsPassword string
 	SingboxVer   string
+	Users        []store.User
 }
 
 // InstallHysteria2 安装 Hysteria2 (使用 sing-box 内核)
@@ -41,7 +40,7 @@ func InstallHysteria2() (*InstallResult, error) {
 	utils.PrintInfo("开始安装 Hysteria2 (sing-box 内核)...")
 
 	// 检查是否已安装
-	if utils.FileExists(Hysteria2ProxyConfigPath) {
+	if IsHysteria2Installed() {
 		if !utils.PromptConfirm("Hysteria2 已安装，是否重新安装？") {
 			return nil, fmt.Errorf("安装已取消")
 		}
@@ -132,7 +131,8 @@ func InstallHysteria2() (*InstallResult, error) {
 	}
 
 	// 创建 sing-box 配置
-	if err := createHysteria2SingboxConfig(config); err != nil {
+	node := storeNodeFromHysteria2(config)
+	if err := writeNodeConfig(node); err != nil {
 		return nil, fmt.Errorf("创建配置失败: %v", err)
 	}
 
@@ -151,8 +151,8 @@ func InstallHysteria2() (*InstallResult, error) {
 	}
 
 	// 保存配置
-	saveHysteria2Config(config)
-	upsertNode(storeNodeFromHysteria2(config))
+	upsertNode(node)
+	recordKernelVersion(store.KernelSingbox, singboxVersion)
 
 	// 生成客户端配置
 	surgeProxy := generateHysteria2SurgeProxy(config)
@@ -176,16 +176,14 @@ func installCertToHysteria2(domain string) error {
 	return InstallCertForService(domain, "hysteria2", Hysteria2KeyPath, Hysteria2CertPath)
 }
 
-// createHysteria2SingboxConfig 创建 sing-box 配置
-func createHysteria2SingboxConfig(cfg Hysteria2Config) error {
+// hysteria2ServerConfig 出 Hysteria2 的 sing-box 服务端配置
+func hysteria2ServerConfig(cfg Hysteria2Config) map[string]interface{} {
 	inbound := map[string]interface{}{
 		"type":        "hysteria2",
 		"tag":         "hy2-in",
 		"listen":      "::",
 		"listen_port": cfg.Port,
-		"users": []map[string]interface{}{
-			{"name": "user1", "password": cfg.Password},
-		},
+		"users":       singboxUsers(cfg.Password, cfg.Users),
 		"tls": map[string]interface{}{
 			"enabled":          true,
 			"server_name":      cfg.Domain,
@@ -202,7 +200,7 @@ func createHysteria2SingboxConfig(cfg Hysteria2Config) error {
 		}
 	}
 
-	config := map[string]interface{}{
+	return map[string]interface{}{
 		"log": map[string]interface{}{
 			"level":     "info",
 			"timestamp": true,
@@ -212,24 +210,6 @@ func createHysteria2SingboxConfig(cfg Hysteria2Config) error {
 			{"type": "direct", "tag": "direct"},
 		},
 	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	if err := utils.WriteFile(Hysteria2ConfigPath, string(data), PermConfigFile); err != nil {
-		return err
-	}
-
-	// 验证配置
-	cmd := exec.Command(SingboxBinaryPath, "check", "-c", Hysteria2ConfigPath)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("配置验证失败: %s", string(output))
-	}
-
-	utils.PrintSuccess("配置文件创建成功")
-	return nil
 }
 
 func createHysteria2Service() error {
@@ -244,24 +224,6 @@ func createHysteria2Service() error {
 	})
 }
 
-func saveHysteria2Config(cfg Hysteria2Config) {
-	config := map[string]string{
-		"TYPE":               "hysteria2",
-		"SERVER_IP":          cfg.ServerIP,
-		"IP_VERSION":         cfg.IPVersion,
-		"SINGBOX_VERSION":    cfg.SingboxVer,
-		"HYSTERIA2_PORT":     strconv.Itoa(cfg.Port),
-		"HYSTERIA2_PASSWORD": cfg.Password,
-		"HYSTERIA2_DOMAIN":   cfg.Domain,
-		"CERT_TYPE":          "letsencrypt",
-		"ENABLE_OBFS":        strconv.FormatBool(cfg.EnableObfs),
-	}
-	if cfg.EnableObfs {
-		config["OBFS_PASSWORD"] = cfg.ObfsPassword
-	}
-	SaveConfigFile(Hysteria2ProxyConfigPath, config)
-}
-
 func generateHysteria2SurgeProxy(cfg Hysteria2Config) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Hysteria2 = hysteria2, %s, %d, password=%s, sni=%s",
@@ -272,15 +234,15 @@ func generateHysteria2SurgeProxy(cfg Hysteria2Config) string {
 	return sb.String()
 }
 
+// generateHysteria2ShareLink 是 format.ShareURL 对这份配置的结果，跟订阅里
+// 的 uri 格式同一份。
 func generateHysteria2ShareLink(cfg Hysteria2Config) string {
-	var link strings.Builder
-	link.WriteString(fmt.Sprintf("hysteria2://%s@%s:%d?sni=%s",
-		cfg.Password, cfg.Domain, cfg.Port, cfg.Domain))
-	if cfg.EnableObfs {
-		link.WriteString(fmt.Sprintf("&obfs=salamander&obfs-password=%s", cfg.ObfsPassword))
+	n := storeNodeFromHysteria2(cfg)
+	link, err := format.ShareURL(&n)
+	if err != nil {
+		return ""
 	}
-	link.WriteString(fmt.Sprintf("#Hysteria2-%s", cfg.Domain))
-	return link.String()
+	return link
 }
 
 func printHysteria2Success(cfg Hysteria2Config, surgeProxy string) {
@@ -315,59 +277,30 @@ func printHysteria2Success(cfg Hysteria2Config, surgeProxy string) {
 
 // ViewHysteria2Config 查看 Hysteria2 配置
 func ViewHysteria2Config() {
-	if !utils.FileExists(Hysteria2ProxyConfigPath) {
+	n, ok := installedNode(store.TypeHysteria2)
+	if !ok {
 		utils.PrintError("Hysteria2 未安装")
 		return
 	}
-
-	config, err := ParseConfigFile(Hysteria2ProxyConfigPath)
+	cfg, err := hysteria2ConfigFromNode(n)
 	if err != nil {
 		utils.PrintError("读取配置失败: %v", err)
 		return
 	}
 
-	enableObfs := config["ENABLE_OBFS"] == "true"
-
 	fmt.Println()
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s   Hysteria2 配置 (sing-box 内核)%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
-	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SERVER_IP"])
-	fmt.Printf("%s域名:%s %s\n", utils.ColorCyan, utils.ColorReset, config["HYSTERIA2_DOMAIN"])
-	fmt.Printf("%s端口:%s %s\n", utils.ColorCyan, utils.ColorReset, config["HYSTERIA2_PORT"])
-	if enableObfs {
+	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ServerIP)
+	fmt.Printf("%s域名:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.Domain)
+	fmt.Printf("%s端口:%s %d\n", utils.ColorCyan, utils.ColorReset, cfg.Port)
+	if cfg.EnableObfs {
 		fmt.Printf("%s混淆:%s 已启用\n", utils.ColorCyan, utils.ColorReset)
 	}
-	fmt.Printf("%sSing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SINGBOX_VERSION"])
+	fmt.Printf("%sSing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, kernelVersion(store.KernelSingbox))
 	fmt.Println()
-
-	// 生成分享链接
-	var shareLink strings.Builder
-	shareLink.WriteString(fmt.Sprintf("hysteria2://%s@%s:%s?sni=%s",
-		config["HYSTERIA2_PASSWORD"], config["HYSTERIA2_DOMAIN"],
-		config["HYSTERIA2_PORT"], config["HYSTERIA2_DOMAIN"]))
-	if enableObfs {
-		shareLink.WriteString(fmt.Sprintf("&obfs=salamander&obfs-password=%s", config["OBFS_PASSWORD"]))
-	}
-	shareLink.WriteString("#Hysteria2")
-
-	fmt.Printf("%s分享链接:%s\n", utils.ColorCyan, utils.ColorReset)
-	fmt.Printf("%s%s%s\n", utils.ColorGreen, shareLink.String(), utils.ColorReset)
-	fmt.Println()
-
-	// Surge 配置
-	var surgeProxy strings.Builder
-	surgeProxy.WriteString(fmt.Sprintf("Hysteria2 = hysteria2, %s, %s, password=%s, sni=%s",
-		config["HYSTERIA2_DOMAIN"], config["HYSTERIA2_PORT"],
-		config["HYSTERIA2_PASSWORD"], config["HYSTERIA2_DOMAIN"]))
-	if enableObfs {
-		surgeProxy.WriteString(fmt.Sprintf(", obfs=salamander, obfs-password=%s", config["OBFS_PASSWORD"]))
-	}
-
-	fmt.Printf("%sSurge:%s\n", utils.ColorCyan, utils.ColorReset)
-	fmt.Printf("%s%s%s\n", utils.ColorGreen, surgeProxy.String(), utils.ColorReset)
-	fmt.Println()
-	PrintAdditionalFormatsForType(store.TypeHysteria2)
+	PrintClientFormatsForType(store.TypeHysteria2)
 }
 
 // =========================================
@@ -376,16 +309,11 @@ func ViewHysteria2Config() {
 
 // UpdateHysteria2 更新 Hysteria2 (sing-box 内核)
 func UpdateHysteria2() error {
-	if !utils.FileExists(Hysteria2ProxyConfigPath) {
+	if !IsHysteria2Installed() {
 		return fmt.Errorf("Hysteria2 未安装")
 	}
 
-	config, err := ParseConfigFile(Hysteria2ProxyConfigPath)
-	if err != nil {
-		return err
-	}
-
-	currentVersion := config["SINGBOX_VERSION"]
+	currentVersion := kernelVersion(store.KernelSingbox)
 	latestVersion := utils.GetLatestVersion("SagerNet/sing-box", utils.DefaultSingboxVersion)
 
 	fmt.Printf("%s当前 sing-box 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, currentVersion)
@@ -414,8 +342,7 @@ func UpdateHysteria2() error {
 		return fmt.Errorf("更新失败: %v", err)
 	}
 
-	config["SINGBOX_VERSION"] = latestVersion
-	SaveConfigFile(Hysteria2ProxyConfigPath, config)
+	recordKernelVersion(store.KernelSingbox, latestVersion)
 
 	utils.ServiceStart("hysteria2")
 
@@ -430,7 +357,15 @@ func UpdateHysteria2() error {
 
 // RenewHysteria2Cert 续签 Hysteria2 证书
 func RenewHysteria2Cert() error {
-	return RenewCertForService("hysteria2", Hysteria2ProxyConfigPath, "HYSTERIA2_DOMAIN", Hysteria2KeyPath, Hysteria2CertPath)
+	n, ok := installedNode(store.TypeHysteria2)
+	if !ok {
+		return fmt.Errorf("hysteria2 未安装")
+	}
+	cfg, err := hysteria2ConfigFromNode(n)
+	if err != nil {
+		return err
+	}
+	return RenewCertForService("hysteria2", cfg.Domain, Hysteria2KeyPath, Hysteria2CertPath)
 }
 
 // =========================================
@@ -441,16 +376,12 @@ func RenewHysteria2Cert() error {
 func UninstallHysteria2() error {
 	utils.PrintInfo("正在卸载 Hysteria2...")
 
-	// 读取配置以获取域名
-	if utils.FileExists(Hysteria2ProxyConfigPath) {
-		config, _ := ParseConfigFile(Hysteria2ProxyConfigPath)
-		domain := config["HYSTERIA2_DOMAIN"]
-		certType := config["CERT_TYPE"]
-
-		if certType == "letsencrypt" && domain != "" {
+	// 证书是 Let's Encrypt 申请的，域名在节点 params 里
+	if n, ok := installedNode(store.TypeHysteria2); ok {
+		if cfg, err := hysteria2ConfigFromNode(n); err == nil && cfg.Domain != "" {
 			if utils.PromptConfirm("是否删除证书？") {
 				acmePath := os.Getenv("HOME") + "/.acme.sh/acme.sh"
-				exec.Command(acmePath, "--remove", "-d", domain, "--ecc").Run()
+				exec.Command(acmePath, "--remove", "-d", cfg.Domain, "--ecc").Run()
 			}
 		}
 	}
@@ -458,13 +389,12 @@ func UninstallHysteria2() error {
 	RemoveSystemdService("hysteria2")
 
 	os.RemoveAll(Hysteria2ConfigDir)
-	os.Remove(Hysteria2ProxyConfigPath)
 	removeNodeByType(store.TypeHysteria2)
 
 	utils.DeleteSystemUser("hysteria2")
 
 	// 如果没有其他服务使用 sing-box，删除二进制
-	if !IsSingboxShared(Hysteria2ProxyConfigPath) {
+	if !IsSingboxShared(store.TypeHysteria2) {
 		os.Remove(SingboxBinaryPath)
 		utils.DeleteSystemUser("sing-box")
 	} else {
@@ -475,7 +405,8 @@ func UninstallHysteria2() error {
 	return nil
 }
 
-// IsHysteria2Installed 检查是否已安装
+// IsHysteria2Installed 检查是否已安装 (nodes.json 里有 Hysteria2 节点)
 func IsHysteria2Installed() bool {
-	return utils.FileExists(Hysteria2ProxyConfigPath)
+	_, ok := installedNode(store.TypeHysteria2)
+	return ok
 }
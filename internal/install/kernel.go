@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/Mamaaz/proxy-manager/internal/audit"
 	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
 )
@@ -36,6 +37,9 @@ func ListKernels() []Kernel {
 
 	hasReality, hasH2, hasAnyTLS, hasAnyTLSReality := false, false, false, false
 	for _, n := range s.Nodes {
+		if n.External {
+			continue
+		}
 		switch n.Type {
 		case store.TypeVLESSReality:
 			hasReality = true
@@ -51,7 +55,7 @@ func ListKernels() []Kernel {
 	var out []Kernel
 	if hasReality {
 		out = append(out, Kernel{
-			Name: "xray-core", BinaryPath: XrayBinaryPath,
+			Name: store.KernelXray, BinaryPath: XrayBinaryPath,
 			Repo: "XTLS/Xray-core", DefaultVer: DefaultXrayVersion,
 			UsedBy:      []string{"VLESS Reality"},
 			Services:    []string{RealityServiceName},
@@ -63,7 +67,7 @@ func ListKernels() []Kernel {
 	// sing-box 是多协议共享内核，UsedBy / Services 累加
 	if hasH2 || hasAnyTLS || hasAnyTLSReality {
 		k := Kernel{
-			Name: "sing-box", BinaryPath: SingboxBinaryPath,
+			Name: store.KernelSingbox, BinaryPath: SingboxBinaryPath,
 			Repo: "SagerNet/sing-box", DefaultVer: utils.DefaultSingboxVersion,
 			VersionCmd:  []string{"version"},
 			VersionGrep: "sing-box version ",
@@ -86,8 +90,8 @@ func ListKernels() []Kernel {
 	return out
 }
 
-// CurrentVersion 问 binary 自己的版本；问不出来 fallback 到从 .txt 读。
-// 返回空串表示不知道。
+// CurrentVersion 问 binary 自己的版本；问不出来 fallback 到 nodes.json 里
+// 记的版本 (install / upgrade 时写的)。返回空串表示不知道。
 func (k Kernel) CurrentVersion() string {
 	if !utils.FileExists(k.BinaryPath) {
 		return ""
@@ -103,20 +107,7 @@ func (k Kernel) CurrentVersion() string {
 			}
 		}
 	}
-	// fallback：从协议 txt config 读版本号
-	switch k.Name {
-	case "xray-core":
-		if kv, err := ParseConfigFile(RealityProxyConfigPath); err == nil {
-			return kv["SINGBOX_VERSION"] // 字段名兼容旧 schema
-		}
-	case "sing-box":
-		for _, p := range []string{Hysteria2ProxyConfigPath, AnyTLSProxyConfigPath, AnyTLSRealityProxyConfigPath} {
-			if kv, err := ParseConfigFile(p); err == nil && kv["SINGBOX_VERSION"] != "" {
-				return kv["SINGBOX_VERSION"]
-			}
-		}
-	}
-	return ""
+	return kernelVersion(k.Name)
 }
 
 // LatestVersion 问 GitHub Releases。失败回退 DefaultVer。
@@ -129,7 +120,7 @@ func (k Kernel) LatestVersion() string {
 
 // Upgrade 升级一个内核：stop services → backup binary → download new →
 // start services。失败时 rollback binary。
-func (k Kernel) Upgrade() error {
+func (k Kernel) Upgrade() (err error) {
 	if k.download == nil {
 		return fmt.Errorf("%s 内核暂不支持自动升级，请走 install 重装相关协议", k.Name)
 	}
@@ -138,6 +129,8 @@ func (k Kernel) Upgrade() error {
 		return err
 	}
 	latest := k.LatestVersion()
+	detail := fmt.Sprintf("%s %s → %s", k.Name, k.CurrentVersion(), latest)
+	defer func() { audit.Record(audit.Entry{Action: "kernel.upgrade", Detail: detail}, err) }()
 
 	utils.PrintInfo("正在升级 %s → %s ...", k.Name, latest)
 
@@ -163,38 +156,24 @@ func (k Kernel) Upgrade() error {
 		return fmt.Errorf("下载 %s 失败: %w (已回滚)", k.Name, err)
 	}
 
-	// 4) 重启 services
+	// 4) 重启 services (node disable 下线的不拉起)
 	for _, svc := range k.Services {
+		if unitHeldDown(svc) {
+			continue
+		}
 		if err := utils.ServiceStart(svc); err != nil {
 			return fmt.Errorf("启动 %s 失败: %w", svc, err)
 		}
 	}
 
-	// 5) 升级版本号到协议 txt config
-	updateVersionInTxt(k.Name, latest)
+	// 5) 版本号记进 nodes.json
+	recordKernelVersion(k.Name, latest)
 
 	// 6) backup 删
 	_ = os.Remove(bak)
 	return nil
 }
 
-func updateVersionInTxt(kernelName, latest string) {
-	switch kernelName {
-	case "xray-core":
-		if kv, err := ParseConfigFile(RealityProxyConfigPath); err == nil {
-			kv["SINGBOX_VERSION"] = latest
-			_ = SaveConfigFile(RealityProxyConfigPath, kv)
-		}
-	case "sing-box":
-		for _, p := range []string{Hysteria2ProxyConfigPath, AnyTLSProxyConfigPath, AnyTLSRealityProxyConfigPath} {
-			if kv, err := ParseConfigFile(p); err == nil && kv["SINGBOX_VERSION"] != "" {
-				kv["SINGBOX_VERSION"] = latest
-				_ = SaveConfigFile(p, kv)
-			}
-		}
-	}
-}
-
 // extractVersionToken 从一行像 "Xray 1.2.3 (Xray-core, mit) ..." 里
 // 找第一个 "数字.数字.数字" 形状的 token。简单 + 容错好。
 func extractVersionToken(line string) string {
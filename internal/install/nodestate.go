@@ -0,0 +1,57 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// SetNodeDisabled 把节点下线维护 / 恢复上线。下线 = nodes.json 标记
+// disabled (订阅和导出不再下发) + systemd stop & disable (重启机器也不会
+// 拉起来)；内核 config、证书、凭据都不动，enable 后客户端原配置直接可用。
+func SetNodeDisabled(ref string, disabled bool) (store.Node, error) {
+	n, err := resolveNode(ref)
+	if err != nil {
+		return store.Node{}, err
+	}
+	updated, err := store.SetNodeDisabled(n.ID, disabled)
+	if err != nil {
+		return store.Node{}, err
+	}
+	unit := serviceNameFor(n.Type)
+	if n.External || !NodeUnitInstalled(n.Type) {
+		return updated, nil // 只在 nodes.json 里 (外部节点 / 已卸载)，没有 unit 可停
+	}
+	if disabled {
+		if err := utils.ServiceStop(unit); err != nil {
+			return updated, fmt.Errorf("停止 %s 失败: %w", unit, err)
+		}
+		if err := utils.ServiceDisable(unit); err != nil {
+			return updated, fmt.Errorf("disable %s 失败: %w", unit, err)
+		}
+		return updated, nil
+	}
+	if err := utils.ServiceEnable(unit); err != nil {
+		return updated, fmt.Errorf("enable %s 失败: %w", unit, err)
+	}
+	if err := utils.ServiceStart(unit); err != nil {
+		return updated, fmt.Errorf("启动 %s 失败: %w", unit, err)
+	}
+	return updated, nil
+}
+
+// unitHeldDown 报告 unit 背后的节点是否被 node disable 下线了——这类 unit
+// 不能被内核升级 / service-rebuild 顺手拉起来。
+func unitHeldDown(unit string) bool {
+	s, err := store.Load()
+	if err != nil {
+		return false
+	}
+	for _, n := range s.Nodes {
+		if n.Disabled && !n.External && serviceNameFor(n.Type) == unit {
+			return true
+		}
+	}
+	return false
+}
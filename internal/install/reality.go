@@ -3,11 +3,9 @@ package install
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
-	"strconv"
 	"strings"
 
 	"github.com/Mamaaz/proxy-manager/internal/store"
@@ -25,9 +23,8 @@ import (
 // 旧 sing-box-reality.service 由 RebuildAllServices 中的迁移逻辑自动卸载。
 // 配置目录从 /etc/sing-box-reality 迁到 /etc/xray-reality。
 const (
-	RealityConfigDir       = "/etc/xray-reality"
-	RealityConfigPath      = "/etc/xray-reality/config.json"
-	RealityProxyConfigPath = "/etc/reality-proxy-config.txt"
+	RealityConfigDir  = "/etc/xray-reality"
+	RealityConfigPath = "/etc/xray-reality/config.json"
 
 	// 旧路径，用于迁移检测
 	LegacyRealityConfigDir   = "/etc/sing-box-reality"
@@ -39,15 +36,15 @@ const (
 
 // RealityConfig Reality 配置
 type RealityConfig struct {
-	ServerIP       string
-	IPVersion      string
-	Port           int
-	UUID           string
-	PrivateKey     string
-	PublicKey      string
-	ShortID        string
-	ServerName     string
-	SingboxVersion string
+	ServerIP   string
+	IPVersion  string
+	Port       int
+	UUID       string
+	PrivateKey string
+	PublicKey  string
+	ShortID    string
+	ServerName string
+	Users      []store.User // 额外用户，见 install/users.go
 }
 
 // InstallReality 安装 VLESS Reality
@@ -55,7 +52,7 @@ func InstallReality() (*InstallResult, error) {
 	utils.PrintInfo("开始安装 VLESS Reality...")
 
 	// 检查是否已安装
-	if utils.FileExists(RealityProxyConfigPath) {
+	if IsRealityInstalled() {
 		if !utils.PromptConfirm("VLESS Reality 已安装，是否重新安装？") {
 			return nil, fmt.Errorf("安装已取消")
 		}
@@ -101,21 +98,21 @@ func InstallReality() (*InstallResult, error) {
 	shortID := generateShortID()
 
 	config := RealityConfig{
-		ServerIP:       serverIP,
-		IPVersion:      ipVersion,
-		Port:           port,
-		UUID:           uuid,
-		PrivateKey:     privateKey,
-		PublicKey:      publicKey,
-		ShortID:        shortID,
-		ServerName:     serverName,
-		SingboxVersion: xrayVersion, // 名字保留兼容旧 .txt schema (字段叫 SINGBOX_VERSION 但存的是当前内核版本)
+		ServerIP:   serverIP,
+		IPVersion:  ipVersion,
+		Port:       port,
+		UUID:       uuid,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+		ShortID:    shortID,
+		ServerName: serverName,
 	}
 
 	// 旧 sing-box-reality 残留先清掉，避免端口冲突或服务名混淆
 	migrateLegacyRealityIfPresent()
 
-	if err := createRealityConfig(config); err != nil {
+	node := storeNodeFromReality(config)
+	if err := writeNodeConfig(node); err != nil {
 		return nil, fmt.Errorf("创建配置失败: %v", err)
 	}
 
@@ -133,8 +130,8 @@ func InstallReality() (*InstallResult, error) {
 	}
 
 	// 保存配置
-	saveRealityConfig(config)
-	upsertNode(storeNodeFromReality(config))
+	upsertNode(node)
+	recordKernelVersion(store.KernelXray, xrayVersion)
 
 	// 生成客户端配置
 	surgeProxy := fmt.Sprintf(
@@ -280,15 +277,11 @@ func generateShortID() string {
 	return hex.EncodeToString(b[:])
 }
 
-// createRealityConfig 写 xray-core 风格的 Reality JSON。字段名跟 sing-box
+// realityServerConfig 出 xray-core 风格的 Reality JSON。字段名跟 sing-box
 // 不同：privateKey (camelCase)、shortIds (数组 + 复数)、dest 用 host:port、
 // flow 直接写在 client 上、serverNames 是数组。
-func createRealityConfig(cfg RealityConfig) error {
-	if err := os.MkdirAll(RealityConfigDir, 0755); err != nil {
-		return err
-	}
-
-	config := map[string]interface{}{
+func realityServerConfig(cfg RealityConfig) map[string]interface{} {
+	return map[string]interface{}{
 		"log": map[string]interface{}{
 			"loglevel": "warning",
 		},
@@ -299,12 +292,7 @@ func createRealityConfig(cfg RealityConfig) error {
 				"port":     cfg.Port,
 				"protocol": "vless",
 				"settings": map[string]interface{}{
-					"clients": []map[string]interface{}{
-						{
-							"id":   cfg.UUID,
-							"flow": "xtls-rprx-vision",
-						},
-					},
+					"clients":    xrayClients(cfg.UUID, cfg.Users),
 					"decryption": "none",
 				},
 				"streamSettings": map[string]interface{}{
@@ -334,12 +322,6 @@ func createRealityConfig(cfg RealityConfig) error {
 		// 数据库文件，xray binary 不自带。简单部署默认全放行；用户要 BT 阻断
 		// /内网防泄漏，单独下 geoip.dat 到 /usr/local/bin/ 后手动加 rules。
 	}
-
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
-	return utils.WriteFile(RealityConfigPath, string(data), PermConfigFile)
 }
 
 // createRealityService 写 xray-reality.service unit。User=xray + CAP_NET_BIND_SERVICE
@@ -369,22 +351,6 @@ func migrateLegacyRealityIfPresent() {
 	_ = utils.DaemonReload()
 }
 
-func saveRealityConfig(cfg RealityConfig) {
-	config := map[string]string{
-		"TYPE":            "reality",
-		"SERVER_IP":       cfg.ServerIP,
-		"IP_VERSION":      cfg.IPVersion,
-		"SINGBOX_VERSION": cfg.SingboxVersion,
-		"PORT":            strconv.Itoa(cfg.Port),
-		"UUID":            cfg.UUID,
-		"PRIVATE_KEY":     cfg.PrivateKey,
-		"PUBLIC_KEY":      cfg.PublicKey,
-		"SHORT_ID":        cfg.ShortID,
-		"SERVER_NAME":     cfg.ServerName,
-	}
-	SaveConfigFile(RealityProxyConfigPath, config)
-}
-
 func printRealitySuccess(cfg RealityConfig, surgeProxy string) {
 	fmt.Println()
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
@@ -409,12 +375,12 @@ func printRealitySuccess(cfg RealityConfig, surgeProxy string) {
 
 // ViewRealityConfig 查看 Reality 配置
 func ViewRealityConfig() {
-	if !utils.FileExists(RealityProxyConfigPath) {
+	n, ok := installedNode(store.TypeVLESSReality)
+	if !ok {
 		utils.PrintError("VLESS Reality 未安装")
 		return
 	}
-
-	config, err := ParseConfigFile(RealityProxyConfigPath)
+	cfg, err := realityConfigFromNode(n)
 	if err != nil {
 		utils.PrintError("读取配置失败: %v", err)
 		return
@@ -424,21 +390,12 @@ func ViewRealityConfig() {
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s   VLESS Reality 配置%s\n", utils.ColorGreen, utils.ColorReset)
 	fmt.Printf("%s=========================================%s\n", utils.ColorGreen, utils.ColorReset)
-	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SERVER_IP"])
-	fmt.Printf("%s端口:%s %s\n", utils.ColorCyan, utils.ColorReset, config["PORT"])
-	fmt.Printf("%sUUID:%s %s\n", utils.ColorCyan, utils.ColorReset, config["UUID"])
-	fmt.Printf("%s目标服务器:%s %s\n", utils.ColorCyan, utils.ColorReset, config["SERVER_NAME"])
-	fmt.Println()
-
-	surgeProxy := fmt.Sprintf(
-		"Reality = vless, %s, %s, username=%s, sni=%s, public-key=%s, short-id=%s, tfo=true, udp-relay=true",
-		config["SERVER_IP"], config["PORT"], config["UUID"],
-		config["SERVER_NAME"], config["PUBLIC_KEY"], config["SHORT_ID"],
-	)
-	fmt.Printf("%sSurge:%s\n", utils.ColorCyan, utils.ColorReset)
-	fmt.Printf("%s%s%s\n", utils.ColorGreen, surgeProxy, utils.ColorReset)
+	fmt.Printf("%s服务器 IP:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ServerIP)
+	fmt.Printf("%s端口:%s %d\n", utils.ColorCyan, utils.ColorReset, cfg.Port)
+	fmt.Printf("%sUUID:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.UUID)
+	fmt.Printf("%s目标服务器:%s %s\n", utils.ColorCyan, utils.ColorReset, cfg.ServerName)
 	fmt.Println()
-	PrintAdditionalFormatsForType(store.TypeVLESSReality)
+	PrintClientFormatsForType(store.TypeVLESSReality)
 }
 
 // =========================================
@@ -446,16 +403,11 @@ func ViewRealityConfig() {
 // =========================================
 
 // UpdateReality 更新 xray-core 内核 (Reality 协议从 v4.0.7 起跑在 xray)。
-// 字段名 SINGBOX_VERSION 保留兼容旧 .txt schema，实际存的是 xray 版本号。
 func UpdateReality() error {
-	if !utils.FileExists(RealityProxyConfigPath) {
+	if !IsRealityInstalled() {
 		return fmt.Errorf("VLESS Reality 未安装")
 	}
-	config, err := ParseConfigFile(RealityProxyConfigPath)
-	if err != nil {
-		return err
-	}
-	currentVersion := config["SINGBOX_VERSION"]
+	currentVersion := kernelVersion(store.KernelXray)
 	latestVersion := utils.GetLatestVersion("XTLS/Xray-core", DefaultXrayVersion)
 
 	fmt.Printf("%s当前 Xray 版本:%s %s\n", utils.ColorCyan, utils.ColorReset, currentVersion)
@@ -481,8 +433,7 @@ func UpdateReality() error {
 		return fmt.Errorf("更新失败: %v", err)
 	}
 
-	config["SINGBOX_VERSION"] = latestVersion // 字段名兼容
-	SaveConfigFile(RealityProxyConfigPath, config)
+	recordKernelVersion(store.KernelXray, latestVersion)
 
 	utils.ServiceStart(RealityServiceName)
 
@@ -506,7 +457,6 @@ func UninstallReality() error {
 	migrateLegacyRealityIfPresent()
 
 	os.RemoveAll(RealityConfigDir)
-	os.Remove(RealityProxyConfigPath)
 	removeNodeByType(store.TypeVLESSReality)
 
 	// xray 是 Reality 专属，没有其他协议共用，直接卸
@@ -517,7 +467,8 @@ func UninstallReality() error {
 	return nil
 }
 
-// IsRealityInstalled 检查是否已安装
+// IsRealityInstalled 检查是否已安装 (nodes.json 里有 Reality 节点)
 func IsRealityInstalled() bool {
-	return utils.FileExists(RealityProxyConfigPath)
+	_, ok := installedNode(store.TypeVLESSReality)
+	return ok
 }
@@ -5,7 +5,8 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/Mamaaz/proxy-manager/internal/utils"
+	"github.com/Mamaaz/proxy-manager/internal/audit"
+	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
 // EditableRealityField 列出 EditReality 接受的字段名。客户端需要重新拉
@@ -20,87 +21,109 @@ type EditableRealityField struct {
 // CurrentRealityFields 读出 reality 当前配置里可编辑的字段，方便上层 UI
 // 渲染 "当前值 -> 新值" 这种形式。
 func CurrentRealityFields() ([]EditableRealityField, error) {
-	if !utils.FileExists(RealityProxyConfigPath) {
+	n, ok := installedNode(store.TypeVLESSReality)
+	if !ok {
 		return nil, fmt.Errorf("VLESS Reality 未安装")
 	}
-	cfg, err := ParseConfigFile(RealityProxyConfigPath)
+	cfg, err := realityConfigFromNode(n)
 	if err != nil {
 		return nil, err
 	}
 	return []EditableRealityField{
-		{Name: "port", DisplayName: "监听端口", CurrentValue: cfg["PORT"],
+		{Name: "port", DisplayName: "监听端口", CurrentValue: strconv.Itoa(cfg.Port),
 			Description: "TCP 端口 1-65535；改后客户端要重连"},
-		{Name: "uuid", DisplayName: "UUID", CurrentValue: cfg["UUID"],
+		{Name: "uuid", DisplayName: "UUID", CurrentValue: cfg.UUID,
 			Description: "VLESS 用户标识；改后旧客户端立即失效，等同 rotate"},
-		{Name: "short-id", DisplayName: "Short ID", CurrentValue: cfg["SHORT_ID"],
+		{Name: "short-id", DisplayName: "Short ID", CurrentValue: cfg.ShortID,
 			Description: "Reality short id；保持 16 位 hex"},
-		{Name: "sni", DisplayName: "目标服务器 (SNI)", CurrentValue: cfg["SERVER_NAME"],
+		{Name: "sni", DisplayName: "目标服务器 (SNI)", CurrentValue: cfg.ServerName,
 			Description: "Reality 仿冒的目标域名，建议先用 sni-test 验证"},
 	}, nil
 }
 
-// EditReality 改一个字段并重启服务。验证失败 / 写入失败时不会半破坏现有
-// 配置——所有写入在 validate 之后才执行。
-func EditReality(field, newValue string) error {
-	if !utils.FileExists(RealityProxyConfigPath) {
+// EditReality 改一个字段并重启服务。验证失败时不写任何东西；验证通过后先
+// 改 nodes.json，再按节点重新渲染 config.json (ApplyNode)。
+func EditReality(field, newValue string) (err error) {
+	n, ok := installedNode(store.TypeVLESSReality)
+	if !ok {
 		return fmt.Errorf("VLESS Reality 未安装")
 	}
-	cfg, err := ParseConfigFile(RealityProxyConfigPath)
-	if err != nil {
-		return fmt.Errorf("解析现有配置失败: %w", err)
-	}
+	// 字段值可能是凭据 (uuid)，审计只记字段名
+	defer func() {
+		audit.Record(audit.Entry{Action: "reality.edit", Node: n.ID, Fields: []string{field}}, err)
+	}()
 
 	newValue = strings.TrimSpace(newValue)
+	var edit func(*store.Node)
 	switch field {
 	case "port":
 		p, err := strconv.Atoi(newValue)
 		if err != nil || p <= 0 || p > 65535 {
 			return fmt.Errorf("port 必须是 1-65535 的整数")
 		}
-		cfg["PORT"] = newValue
+		edit = func(n *store.Node) { n.Port = p }
 	case "uuid":
 		if !looksLikeUUID(newValue) {
 			return fmt.Errorf("UUID 格式错误（期望 8-4-4-4-12 十六进制）")
 		}
-		cfg["UUID"] = newValue
+		edit = func(n *store.Node) { n.Params["uuid"] = newValue }
 	case "short-id":
-		if !looksLikeHex(newValue) || len(newValue) > 16 {
-			return fmt.Errorf("short id 必须是 ≤16 位的 hex 字符串")
+		if !validShortID(newValue) {
+			return fmt.Errorf("short id 必须是 ≤16 位、偶数长度的 hex 字符串")
 		}
-		cfg["SHORT_ID"] = newValue
+		edit = func(n *store.Node) { n.Params["short_id"] = newValue }
 	case "sni":
 		if newValue == "" || strings.ContainsAny(newValue, " \t/") {
 			return fmt.Errorf("SNI 必须是裸域名 (如 www.apple.com)，不带 scheme/路径")
 		}
-		cfg["SERVER_NAME"] = newValue
+		edit = func(n *store.Node) { n.Params["server_name"] = newValue }
 	default:
 		return fmt.Errorf("未知字段: %s (支持: port / uuid / short-id / sni)", field)
 	}
 
-	// 重建 RealityConfig 结构，写 config.json + txt + nodes.json
-	port, _ := strconv.Atoi(cfg["PORT"])
-	rc := RealityConfig{
-		ServerIP:       cfg["SERVER_IP"],
-		IPVersion:      cfg["IP_VERSION"],
-		Port:           port,
-		UUID:           cfg["UUID"],
-		PrivateKey:     cfg["PRIVATE_KEY"],
-		PublicKey:      cfg["PUBLIC_KEY"],
-		ShortID:        cfg["SHORT_ID"],
-		ServerName:     cfg["SERVER_NAME"],
-		SingboxVersion: cfg["SINGBOX_VERSION"],
+	// 先在副本上改一遍跑完整校验：字段级检查漏掉的组合 (比如 DecodeParams
+	// 才查的规则) 也不能落进 nodes.json，否则订阅静默跳过该节点、config.json
+	// 也不会重写。
+	if _, err := editedNode(n, edit); err != nil {
+		return err
 	}
 
-	if err := createRealityConfig(rc); err != nil {
-		return fmt.Errorf("写 sing-box config 失败: %w", err)
+	err = store.Update(func(s *store.Store) error {
+		for i := range s.Nodes {
+			if s.Nodes[i].ID == n.ID {
+				if s.Nodes[i].Params == nil {
+					s.Nodes[i].Params = map[string]any{}
+				}
+				edit(&s.Nodes[i])
+				n = s.Nodes[i]
+				return nil
+			}
+		}
+		return fmt.Errorf("未找到节点: %s", n.ID)
+	})
+	if err != nil {
+		return fmt.Errorf("写入 nodes.json 失败: %w", err)
 	}
-	saveRealityConfig(rc)
-	upsertNode(storeNodeFromReality(rc))
+	return ApplyNode(n)
+}
 
-	if err := utils.ServiceRestart("sing-box-reality"); err != nil {
-		return fmt.Errorf("配置已更新但重启服务失败: %w (建议手工 systemctl restart sing-box-reality)", err)
+// editedNode applies edit to a copy of n (Params 也复制) and validates it.
+func editedNode(n store.Node, edit func(*store.Node)) (store.Node, error) {
+	params := make(map[string]any, len(n.Params))
+	for k, v := range n.Params {
+		params[k] = v
+	}
+	n.Params = params
+	edit(&n)
+	if err := n.Validate(); err != nil {
+		return store.Node{}, fmt.Errorf("修改后节点校验失败: %w", err)
 	}
-	return nil
+	return n, nil
+}
+
+// validShortID: Reality short id 是按字节的 hex，所以长度必须是偶数。
+func validShortID(s string) bool {
+	return looksLikeHex(s) && len(s) <= 16 && len(s)%2 == 0
 }
 
 func looksLikeUUID(s string) bool {
@@ -0,0 +1,43 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestValidShortID(t *testing.T) {
+	for in, want := range map[string]bool{
+		"ab":                true,
+		"0123456789abcdef":  true,
+		"abc":               false, // 奇数长度
+		"0123456789abcdef0": false,
+		"zz":                false,
+		"":                  false,
+	} {
+		if got := validShortID(in); got != want {
+			t.Errorf("validShortID(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+// 校验在副本上做：不合法的修改既不能通过，也不能改到原节点。
+func TestEditedNodeValidatesCopy(t *testing.T) {
+	n := store.Node{
+		ID: "vless-reality-1.2.3.4", Type: store.TypeVLESSReality, Server: "1.2.3.4", Port: 443,
+		Params: map[string]any{
+			"uuid": "0b3c1f3e-1111-4222-8333-944455556666", "public_key": "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+			"short_id": "ab", "server_name": "www.apple.com",
+		},
+	}
+	if _, err := editedNode(n, func(n *store.Node) { n.Params["short_id"] = "abc" }); err == nil {
+		t.Error("odd-length short id should fail validation")
+	}
+	if n.Params["short_id"] != "ab" {
+		t.Errorf("original node modified: short_id = %v", n.Params["short_id"])
+	}
+	got, err := editedNode(n, func(n *store.Node) { n.Params["short_id"] = "abcd" })
+	if err != nil || got.Params["short_id"] != "abcd" {
+		t.Errorf("valid edit: %v, %v", got.Params["short_id"], err)
+	}
+}
@@ -2,8 +2,8 @@ package install
 
 import (
 	"fmt"
-	"strconv"
 
+	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
 )
 
@@ -11,8 +11,8 @@ import (
 // protocol so an upgrade picks up changes to the unit (e.g., User= /
 // Capabilities= / ExecStart= shifts that ship in a new binary version).
 //
-// Idempotent. Only acts on protocols that have an existing config file
-// (i.e. were previously installed).  daemon-reload and service restart are
+// Idempotent. Only acts on protocols that have a node in nodes.json (i.e.
+// were previously installed).  daemon-reload and service restart are
 // invoked per-protocol so a partial failure on one doesn't block the others.
 func RebuildAllServices() ([]string, error) {
 	var rebuilt []string
@@ -29,25 +29,25 @@ func RebuildAllServices() ([]string, error) {
 	tasks := []svc{
 		{
 			name:    "VLESS Reality",
-			check:   func() bool { return utils.FileExists(RealityProxyConfigPath) },
+			check:   IsRealityInstalled,
 			rebuild: rebuildReality,
 			units:   []string{RealityServiceName},
 		},
 		{
 			name:    "Hysteria2",
-			check:   func() bool { return utils.FileExists(Hysteria2ProxyConfigPath) },
+			check:   IsHysteria2Installed,
 			rebuild: createHysteria2Service,
 			units:   []string{"hysteria2"},
 		},
 		{
 			name:    "AnyTLS",
-			check:   func() bool { return utils.FileExists(AnyTLSProxyConfigPath) },
+			check:   IsAnyTLSInstalled,
 			rebuild: createAnyTLSService,
 			units:   []string{"anytls"},
 		},
 		{
 			name:    "AnyTLS + Reality",
-			check:   func() bool { return utils.FileExists(AnyTLSRealityProxyConfigPath) },
+			check:   IsAnyTLSRealityInstalled,
 			rebuild: createAnyTLSRealityService,
 			units:   []string{AnyTLSRealityServiceName},
 		},
@@ -66,6 +66,9 @@ func RebuildAllServices() ([]string, error) {
 		}
 		// Restart so the new unit's User=/Capabilities= take effect.
 		for _, u := range t.units {
+			if unitHeldDown(u) {
+				continue
+			}
 			if err := utils.ServiceRestart(u); err != nil {
 				utils.PrintWarn("[%s] 重启 %s 失败: %v", t.name, u, err)
 			}
@@ -102,29 +105,13 @@ func rebuildReality() error {
 		}
 	}
 
-	// 从 .txt 读现有配置 (sing-box 时代写的 PRIVATE_KEY/UUID 都能复用)
-	kv, err := ParseConfigFile(RealityProxyConfigPath)
-	if err != nil {
-		return fmt.Errorf("parse reality config: %w", err)
-	}
-	cfg := RealityConfig{
-		ServerIP:       kv["SERVER_IP"],
-		IPVersion:      kv["IP_VERSION"],
-		Port:           atoi(kv["PORT"]),
-		UUID:           kv["UUID"],
-		PrivateKey:     kv["PRIVATE_KEY"],
-		PublicKey:      kv["PUBLIC_KEY"],
-		ShortID:        kv["SHORT_ID"],
-		ServerName:     kv["SERVER_NAME"],
-		SingboxVersion: kv["SINGBOX_VERSION"], // 字段名兼容；存的可能是旧 sing-box 版本
+	// 按 nodes.json 里的节点重新渲染 (sing-box 时代的 private_key/UUID 都能复用)
+	n, ok := installedNode(store.TypeVLESSReality)
+	if !ok {
+		return fmt.Errorf("nodes.json 里没有 Reality 节点")
 	}
-	if err := createRealityConfig(cfg); err != nil {
+	if err := writeNodeConfig(n); err != nil {
 		return fmt.Errorf("create xray reality config: %w", err)
 	}
 	return createRealityService()
 }
-
-func atoi(s string) int {
-	n, _ := strconv.Atoi(s)
-	return n
-}
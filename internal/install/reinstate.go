@@ -0,0 +1,131 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// ReinstateNode 按 nodes.json 里的节点记录把协议完整装回本机：内核二进制、
+// 系统用户、证书、内核 config、systemd unit，凭据全部沿用节点里的
+// 值——客户端不需要重新导入。
+//
+// 用在两处：store restore 找回误卸载的协议；backup restore 到新 VPS。
+// Hysteria2 / AnyTLS 的证书从 acme.sh 里 install-cert，acme.sh 里没有该
+// 域名的证书时报错，让用户走正常 install 重新申请。
+func ReinstateNode(n store.Node) error {
+	if n.External {
+		return ErrExternalNode
+	}
+	// params 不完整就别先去下载内核了
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	arch, err := utils.DetectArch()
+	if err != nil {
+		return err
+	}
+	unit := serviceNameFor(n.Type)
+
+	switch n.Type {
+	case store.TypeVLESSReality:
+		ver := utils.GetLatestVersion("XTLS/Xray-core", DefaultXrayVersion)
+		if err := downloadXray(ver, arch); err != nil {
+			return fmt.Errorf("下载 xray 失败: %w", err)
+		}
+		recordKernelVersion(store.KernelXray, ver)
+		utils.CreateSystemUser(RealityServiceUser)
+		if err := createRealityService(); err != nil {
+			return err
+		}
+
+	case store.TypeHysteria2:
+		ver := utils.GetLatestVersion("SagerNet/sing-box", utils.DefaultSingboxVersion)
+		if err := downloadSingbox(ver, arch); err != nil {
+			return fmt.Errorf("下载 sing-box 失败: %w", err)
+		}
+		recordKernelVersion(store.KernelSingbox, ver)
+		utils.CreateSystemUser("hysteria2")
+		cfg, err := hysteria2ConfigFromNode(n)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(Hysteria2ConfigDir, 0755); err != nil {
+			return err
+		}
+		if err := reinstateCert(cfg.Domain, "hysteria2", Hysteria2KeyPath, Hysteria2CertPath); err != nil {
+			return err
+		}
+		if err := createHysteria2Service(); err != nil {
+			return err
+		}
+
+	case store.TypeAnyTLS:
+		ver := utils.GetLatestVersion("SagerNet/sing-box", utils.DefaultSingboxVersion)
+		if err := downloadSingbox(ver, arch); err != nil {
+			return fmt.Errorf("下载 sing-box 失败: %w", err)
+		}
+		recordKernelVersion(store.KernelSingbox, ver)
+		utils.CreateSystemUser("anytls")
+		cfg, err := anyTLSConfigFromNode(n)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(AnyTLSConfigDir, 0755); err != nil {
+			return err
+		}
+		if err := reinstateCert(cfg.Domain, "anytls", AnyTLSKeyPath, AnyTLSCertPath); err != nil {
+			return err
+		}
+		if err := createAnyTLSService(); err != nil {
+			return err
+		}
+
+	case store.TypeAnyTLSReality:
+		ver := utils.GetLatestVersion("SagerNet/sing-box", utils.DefaultSingboxVersion)
+		if err := downloadSingbox(ver, arch); err != nil {
+			return fmt.Errorf("下载 sing-box 失败: %w", err)
+		}
+		recordKernelVersion(store.KernelSingbox, ver)
+		utils.CreateSystemUser("anytls-reality")
+		if err := createAnyTLSRealityService(); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("不支持的节点类型: %s", n.Type)
+	}
+
+	if err := ApplyNode(n); err != nil {
+		return err
+	}
+	if n.Disabled {
+		return nil // 下线维护中的节点装回来但不 enable
+	}
+	return utils.ServiceEnable(unit)
+}
+
+// reinstateCert 证书文件还在 (store restore 场景、或 backup 解压出来的) 就
+// 只修正属主——备份里的 uid 在新机器上对不上；不在则从 acme.sh 重新
+// install-cert。
+func reinstateCert(domain, serviceName, keyPath, certPath string) error {
+	if utils.FileExists(certPath) && utils.FileExists(keyPath) {
+		os.Chmod(keyPath, PermKeyFile)
+		os.Chmod(certPath, PermCertFile)
+		if out, err := exec.Command("chown", serviceName+":"+serviceName, keyPath, certPath).CombinedOutput(); err != nil {
+			utils.PrintWarn("设置证书所有权失败: %v (%s)", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if domain == "" {
+		return fmt.Errorf("节点记录里没有域名，无法恢复证书")
+	}
+	if err := InstallCertForService(domain, serviceName, keyPath, certPath); err != nil {
+		return fmt.Errorf("证书安装失败 (acme.sh 里可能没有 %s 的证书，请重新 install): %w", domain, err)
+	}
+	return nil
+}
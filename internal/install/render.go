@@ -0,0 +1,78 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// 内核 config.json 只从 nodes.json 的节点渲染：install 先拼出 store.Node
+// 再渲染，edit / 多用户 / restore / rebuild 都是改完 store 再重新渲染。
+// 没有第二份数据源，config.json 可以随时删掉重建。
+
+// RenderNode renders n's kernel config (xray for Reality, sing-box for the
+// rest) and returns the path it belongs at. It only reads n: no disk, no
+// .txt, no other nodes.
+func RenderNode(n store.Node) (path string, data []byte, err error) {
+	var config map[string]any
+	switch n.Type {
+	case store.TypeVLESSReality:
+		cfg, err := realityConfigFromNode(n)
+		if err != nil {
+			return "", nil, err
+		}
+		path, config = RealityConfigPath, realityServerConfig(cfg)
+	case store.TypeHysteria2:
+		cfg, err := hysteria2ConfigFromNode(n)
+		if err != nil {
+			return "", nil, err
+		}
+		path, config = Hysteria2ConfigPath, hysteria2ServerConfig(cfg)
+	case store.TypeAnyTLS:
+		cfg, err := anyTLSConfigFromNode(n)
+		if err != nil {
+			return "", nil, err
+		}
+		path, config = AnyTLSConfigPath, anyTLSServerConfig(cfg, paddingSchemeByName(cfg.PaddingName))
+	case store.TypeAnyTLSReality:
+		cfg, err := anyTLSRealityConfigFromNode(n)
+		if err != nil {
+			return "", nil, err
+		}
+		path, config = AnyTLSRealityConfigPath, anyTLSRealityServerConfig(cfg)
+	default:
+		return "", nil, fmt.Errorf("不支持的节点类型: %s", n.Type)
+	}
+	data, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	return path, data, nil
+}
+
+// writeNodeConfig renders n and writes the result. sing-box 的 config 落盘
+// 后再跑一遍 `sing-box check`，格式问题在重启服务之前就暴露出来。
+func writeNodeConfig(n store.Node) error {
+	path, data, err := RenderNode(n)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := utils.WriteFile(path, string(data), PermConfigFile); err != nil {
+		return fmt.Errorf("写 %s 失败: %w", path, err)
+	}
+	if store.KernelFor(n.Type) != store.KernelSingbox {
+		return nil
+	}
+	if output, err := exec.Command(SingboxBinaryPath, "check", "-c", path).CombinedOutput(); err != nil {
+		return fmt.Errorf("配置验证失败: %s", string(output))
+	}
+	return nil
+}
@@ -3,14 +3,15 @@ package install
 import (
 	"fmt"
 
+	"github.com/Mamaaz/proxy-manager/internal/audit"
 	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
 )
 
 // This file bridges the per-protocol install structs into the unified
-// store.Node format. Install/uninstall functions call these helpers in
-// addition to the legacy .txt save/delete; the .txt files remain the
-// runtime source of truth for now and are removed in a later PR.
+// store.Node format. nodes.json is the only record of what is installed:
+// install builds a Node and renders the kernel config from it (render.go),
+// everything afterwards reads the Node back.
 //
 // v4.0.33: ID 和 Name 都按 ServerIP 后缀唯一化。之前所有 VPS 的 Reality
 // 节点都用静态 "vless-reality" / "VLESS-Reality",XSurge 合并多个订阅时
@@ -23,10 +24,56 @@ func upsertNode(n store.Node) {
 	}
 }
 
+// removeNodeByType 是各协议 Uninstall* 的最后一步，顺带记一条 node.uninstall
+// 审计 (store 层只看得到 nodes.json 少了个节点，看不到是卸载造成的)。
 func removeNodeByType(t store.NodeType) {
-	if err := store.RemoveByType(t); err != nil {
+	var ids []string
+	if s, err := store.Load(); err == nil {
+		for _, n := range s.Nodes {
+			if n.Type == t && !n.External {
+				ids = append(ids, n.ID)
+			}
+		}
+	}
+	err := store.RemoveByType(t)
+	if err != nil {
 		utils.PrintWarn("从 nodes.json 移除失败 (不影响卸载): %v", err)
 	}
+	if len(ids) == 0 {
+		ids = []string{string(t)}
+	}
+	for _, id := range ids {
+		audit.Record(audit.Entry{Action: "node.uninstall", Node: id}, err)
+	}
+}
+
+// installedNode 取本机 t 协议的节点。一台机器每种协议只装一个，nodes.json
+// 里有它就算已安装 (取代以前检查 /etc/*-proxy-config.txt 是否存在)。
+func installedNode(t store.NodeType) (store.Node, bool) {
+	s, err := store.LoadOrMigrate()
+	if err != nil {
+		return store.Node{}, false
+	}
+	if n, ok := s.LocalNode(t); ok {
+		return *n, true
+	}
+	return store.Node{}, false
+}
+
+// recordKernelVersion 记下刚装 / 升级好的内核版本，写失败只警告。
+func recordKernelVersion(kernel, version string) {
+	if err := store.SetKernelVersion(kernel, version); err != nil {
+		utils.PrintWarn("写入 nodes.json 失败 (不影响安装): %v", err)
+	}
+}
+
+// kernelVersion 读 nodes.json 里记的内核版本，没记过返回空串。
+func kernelVersion(kernel string) string {
+	s, err := store.LoadOrMigrate()
+	if err != nil {
+		return ""
+	}
+	return s.Kernels[kernel]
 }
 
 func storeNodeFromReality(cfg RealityConfig) store.Node {
@@ -44,6 +91,7 @@ func storeNodeFromReality(cfg RealityConfig) store.Node {
 			"server_name": cfg.ServerName,
 			"flow":        "xtls-rprx-vision",
 		},
+		Users: cfg.Users,
 	}
 }
 
@@ -63,6 +111,7 @@ func storeNodeFromHysteria2(cfg Hysteria2Config) store.Node {
 		Server: cfg.ServerIP,
 		Port:   cfg.Port,
 		Params: params,
+		Users:  cfg.Users,
 	}
 }
 
@@ -78,5 +127,6 @@ func storeNodeFromAnyTLS(cfg AnyTLSConfig) store.Node {
 			"domain":       cfg.Domain,
 			"padding_name": cfg.PaddingName,
 		},
+		Users: cfg.Users,
 	}
 }
@@ -0,0 +1,129 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+	"github.com/Mamaaz/proxy-manager/internal/utils"
+)
+
+// 多用户 (一台 VPS 多人共用)。
+//
+// 节点 Params 里的 uuid/password 是 "default" 用户；Node.Users 是额外的人。
+// 内核 config 的 clients / users 数组 = default + 所有未禁用的 Users，每人
+// 带 name (xray 里叫 email) 方便看日志。吊销一个人只改数组、重启这一个
+// unit，其他人的凭据不变，不用重新导入。
+
+// xrayClients 生成 xray vless inbound 的 clients 数组。
+func xrayClients(uuid string, users []store.User) []map[string]interface{} {
+	clients := []map[string]interface{}{
+		{"id": uuid, "flow": "xtls-rprx-vision", "email": store.DefaultUserName},
+	}
+	for _, u := range users {
+		if u.Disabled || u.UUID == "" {
+			continue
+		}
+		clients = append(clients, map[string]interface{}{
+			"id": u.UUID, "flow": "xtls-rprx-vision", "email": u.Name,
+		})
+	}
+	return clients
+}
+
+// singboxUsers 生成 sing-box hysteria2 / anytls inbound 的 users 数组。
+func singboxUsers(password string, users []store.User) []map[string]interface{} {
+	out := []map[string]interface{}{
+		{"name": store.DefaultUserName, "password": password},
+	}
+	for _, u := range users {
+		if u.Disabled || u.Password == "" {
+			continue
+		}
+		out = append(out, map[string]interface{}{"name": u.Name, "password": u.Password})
+	}
+	return out
+}
+
+// newUserCredential 按协议生成新用户的凭据。长度跟各协议安装时的默认凭据一致。
+func newUserCredential(t store.NodeType, name string) store.User {
+	u := store.User{Name: name}
+	switch t {
+	case store.TypeVLESSReality:
+		u.UUID = generateRandomUUIDv4()
+	case store.TypeAnyTLS:
+		u.Password = utils.GeneratePassword(32)
+	default:
+		u.Password = utils.GeneratePassword(16)
+	}
+	return u
+}
+
+// AddNodeUser 给节点加一个用户并重建该节点的内核 config。ref 可以是节点 ID
+// 或类型 (store.FindNode 的规则)。返回更新后的节点和新用户，调用方用它打印
+// 客户端配置。
+func AddNodeUser(ref, name string) (store.Node, store.User, error) {
+	n, err := resolveLocalNode(ref)
+	if err != nil {
+		return store.Node{}, store.User{}, err
+	}
+	u := newUserCredential(n.Type, name)
+	updated, err := store.AddUser(n.ID, u)
+	if err != nil {
+		return store.Node{}, store.User{}, err
+	}
+	for _, x := range updated.Users {
+		if x.Name == name {
+			u = x
+		}
+	}
+	return updated, u, ApplyNode(updated)
+}
+
+// RemoveNodeUser 删除用户并重建 config——旧凭据立即失效。
+func RemoveNodeUser(ref, name string) (store.Node, error) {
+	n, err := resolveLocalNode(ref)
+	if err != nil {
+		return store.Node{}, err
+	}
+	updated, err := store.RemoveUser(n.ID, name)
+	if err != nil {
+		return store.Node{}, err
+	}
+	return updated, ApplyNode(updated)
+}
+
+// SetNodeUserDisabled 禁用 / 启用用户。禁用只是从内核 config 里拿掉，凭据
+// 留在 nodes.json，启用后客户端原配置直接可用。
+func SetNodeUserDisabled(ref, name string, disabled bool) (store.Node, error) {
+	n, err := resolveLocalNode(ref)
+	if err != nil {
+		return store.Node{}, err
+	}
+	updated, err := store.SetUserDisabled(n.ID, name, disabled)
+	if err != nil {
+		return store.Node{}, err
+	}
+	return updated, ApplyNode(updated)
+}
+
+// resolveLocalNode 是 resolveNode 加一道检查：用户凭据要写进本机内核
+// config，外部节点没有。
+func resolveLocalNode(ref string) (store.Node, error) {
+	n, err := resolveNode(ref)
+	if err == nil && n.External {
+		return store.Node{}, fmt.Errorf("%s: %w", n.ID, ErrExternalNode)
+	}
+	return n, err
+}
+
+func resolveNode(ref string) (store.Node, error) {
+	s, err := store.Load()
+	if err != nil {
+		return store.Node{}, fmt.Errorf("读取 nodes.json 失败: %w", err)
+	}
+	n, err := s.FindNode(ref)
+	if err != nil {
+		return store.Node{}, err
+	}
+	return *n, nil
+}
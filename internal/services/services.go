@@ -2,9 +2,11 @@ package services
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
 )
 
 // ServiceStatus 服务状态
@@ -21,50 +23,55 @@ const (
 type Service struct {
 	Name        string
 	DisplayName string
-	ConfigPath  string
+	NodeType    store.NodeType
 	SystemdName string
 }
 
 // 预定义服务列表。
 //
-// ConfigPath 是协议的 .txt 配置 (install 时落的 source of truth)，
-// 而非 sing-box/xray 内核 config.json (那个路径会随内核切换变)。
-// 例如 reality 在 v4.0.7 内核从 sing-box 切到 xray，内核 config.json
-// 路径从 /etc/sing-box-reality/ 变成 /etc/xray-reality/，但
-// /etc/reality-proxy-config.txt 这个 .txt 永远不变，是稳定锚点。
+// 是否安装看 nodes.json 里有没有 NodeType 的节点，而非 sing-box/xray 内核
+// config.json 在不在 (那个路径会随内核切换变：reality 在 v4.0.7 内核从
+// sing-box 切到 xray，config.json 从 /etc/sing-box-reality/ 搬到了
+// /etc/xray-reality/)。
 //
 // SystemdName 跟着内核走 (v4.0.7 起 reality unit 是 xray-reality)。
 var Services = map[string]Service{
 	"reality": {
 		Name:        "reality",
 		DisplayName: "VLESS Reality",
-		ConfigPath:  "/etc/reality-proxy-config.txt",
+		NodeType:    store.TypeVLESSReality,
 		SystemdName: "xray-reality", // v4.0.7+ 改 xray
 	},
 	"hysteria2": {
 		Name:        "hysteria2",
 		DisplayName: "Hysteria2",
-		ConfigPath:  "/etc/hysteria2-proxy-config.txt",
+		NodeType:    store.TypeHysteria2,
 		SystemdName: "hysteria2",
 	},
 	"anytls": {
 		Name:        "anytls",
 		DisplayName: "AnyTLS",
-		ConfigPath:  "/etc/anytls-proxy-config.txt",
+		NodeType:    store.TypeAnyTLS,
 		SystemdName: "anytls",
 	},
 	"anytls-reality": {
 		Name:        "anytls-reality",
 		DisplayName: "AnyTLS + Reality",
-		ConfigPath:  "/etc/anytls-reality-proxy-config.txt",
+		NodeType:    store.TypeAnyTLSReality,
 		SystemdName: "anytls-reality",
 	},
 }
 
-// IsInstalled 检查服务是否已安装
+// IsInstalled 检查服务是否已安装 (nodes.json 里有该协议的节点)。状态 / 菜单
+// 路径上调，可能不是 root：只读，不做 .txt 导入 (那是 LoadOrMigrate 的事，
+// 要写锁还可能写盘)。
 func (s *Service) IsInstalled() bool {
-	_, err := os.Stat(s.ConfigPath)
-	return err == nil
+	st, err := store.Load()
+	if err != nil {
+		return false
+	}
+	_, ok := st.LocalNode(s.NodeType)
+	return ok
 }
 
 // GetStatus 获取服务状态
@@ -113,6 +120,42 @@ func (s *Service) Enable() error {
 	return cmd.Run()
 }
 
+// ForType 返回 NodeType 对应的服务定义
+func ForType(t store.NodeType) (Service, bool) {
+	for _, svc := range Services {
+		if svc.NodeType == t {
+			return svc, true
+		}
+	}
+	return Service{}, false
+}
+
+// Traffic 读 systemd IPAccounting 的收发字节数 (unit 需要 IPAccounting=yes，
+// 老 unit 跑一次 service-rebuild 才有)。计数从 unit 上次启动算起；没开
+// accounting 或 unit 没在跑时 ok=false。
+func (s *Service) Traffic() (ingress, egress int64, ok bool) {
+	out, err := exec.Command("systemctl", "show", "--property=IPIngressBytes,IPEgressBytes", s.SystemdName).Output()
+	if err != nil {
+		return 0, 0, false
+	}
+	var gotIn, gotOut bool
+	for _, line := range strings.Split(string(out), "\n") {
+		k, v, _ := strings.Cut(strings.TrimSpace(line), "=")
+		// 没数据时是 "[no data]"，老版本 systemd 给 2^64-1，都解析不成 int64
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "IPIngressBytes":
+			ingress, gotIn = n, true
+		case "IPEgressBytes":
+			egress, gotOut = n, true
+		}
+	}
+	return ingress, egress, gotIn && gotOut
+}
+
 // GetAllStatus 获取所有服务状态
 func GetAllStatus() map[string]ServiceStatus {
 	result := make(map[string]ServiceStatus)
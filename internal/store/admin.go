@@ -0,0 +1,64 @@
+package store
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AdminClientCAPath 是 mTLS 客户端 CA 的落地位置：subscribe daemon 以
+// ServiceUser 跑，得能读到它 (root:proxy-manager 0640)。
+var AdminClientCAPath = filepath.Join(StoreDir, "admin-ca.pem")
+
+// RotateAdminToken issues a new bearer token for /api/v1. 旧 token 立即失效。
+func RotateAdminToken() (string, error) {
+	token, err := generateToken(24)
+	if err != nil {
+		return "", err
+	}
+	err = Update(func(s *Store) error {
+		s.Subscribe.AdminToken = token
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LoadClientCAs parses a PEM bundle of CA certificates for client auth.
+func LoadClientCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s 里没有可用的 CA 证书", path)
+	}
+	return pool, nil
+}
+
+// SetAdminClientCA records the client CA bundle used for mTLS ("" = off).
+// The caller copies the file to AdminClientCAPath first.
+func SetAdminClientCA(path string) error {
+	if path != "" {
+		if _, err := LoadClientCAs(path); err != nil {
+			return err
+		}
+	}
+	return Update(func(s *Store) error {
+		s.Subscribe.AdminClientCA = path
+		return nil
+	})
+}
+
+// DisableAdmin drops both the admin token and the client CA.
+func DisableAdmin() error {
+	return Update(func(s *Store) error {
+		s.Subscribe.AdminToken = ""
+		s.Subscribe.AdminClientCA = ""
+		return nil
+	})
+}
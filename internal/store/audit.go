@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/Mamaaz/proxy-manager/internal/audit"
+)
+
+// record 写一条审计；测试里替换掉。
+var record = audit.Record
+
+// auditChanges 把一次 Update 的变化按节点拆成审计条目 (audit 包)。字段名来自
+// Diff，不含值；err 非空 (fn 拒绝或落盘失败) 时条目记为失败。fn 在改动之前
+// 就报错的，没有字段可拆，记一条 store.update 失败，尝试本身也留痕。
+func auditChanges(before, after *Store, err error) {
+	d := Diff(before, after)
+	if d.Empty() {
+		if err != nil {
+			record(audit.Entry{Action: "store.update"}, err)
+		}
+		return
+	}
+	for _, id := range d.Added {
+		record(audit.Entry{Action: "node.add", Node: id}, err)
+	}
+	for _, id := range d.Removed {
+		record(audit.Entry{Action: "node.remove", Node: id}, err)
+	}
+	ids := make([]string, 0, len(d.Changed))
+	for id := range d.Changed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		record(audit.Entry{Action: "node.update", Node: id, Fields: d.Changed[id]}, err)
+	}
+	if len(d.Subscribe) > 0 {
+		record(audit.Entry{Action: "subscribe.update", Fields: d.Subscribe}, err)
+	}
+}
+
+// cloneStore deep-copies s so Update can diff before/after fn.
+func cloneStore(s *Store) *Store {
+	var out Store
+	if data, err := json.Marshal(s); err == nil && json.Unmarshal(data, &out) == nil {
+		return &out
+	}
+	return &Store{}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/audit"
+)
+
+func captureAudit(t *testing.T) *[]audit.Entry {
+	var got []audit.Entry
+	old := record
+	record = func(e audit.Entry, err error) {
+		if err != nil {
+			e.Outcome, e.Error = audit.OutcomeFailed, err.Error()
+		}
+		got = append(got, e)
+	}
+	t.Cleanup(func() { record = old })
+	return &got
+}
+
+// 审计只记字段名：凭据本身不能出现在日志里。
+func TestAuditChangesOnlyFieldNames(t *testing.T) {
+	got := captureAudit(t)
+	before := &Store{
+		Subscribe: SubscribeConfig{Token: "old-main-token"},
+		Nodes: []Node{
+			{ID: "hy2", Type: TypeHysteria2, Params: map[string]any{"password": "old-pw"}},
+			{ID: "gone", Type: TypeAnyTLS},
+		},
+	}
+	after := &Store{
+		Subscribe: SubscribeConfig{Token: "new-main-token"},
+		Nodes: []Node{
+			{ID: "hy2", Type: TypeHysteria2, Params: map[string]any{"password": "new-pw"},
+				Users: []User{{Name: "alice", Password: "alice-pw"}}},
+			{ID: "reality", Type: TypeVLESSReality, Params: map[string]any{"uuid": "secret-uuid"}},
+		},
+	}
+	auditChanges(before, after, nil)
+
+	want := []string{"node.add reality ", "node.remove gone ", "node.update hy2 params.password,users.alice", "subscribe.update  token"}
+	if len(*got) != len(want) {
+		t.Fatalf("entries = %+v", *got)
+	}
+	for i, e := range *got {
+		if s := e.Action + " " + e.Node + " " + strings.Join(e.Fields, ","); s != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, s, want[i])
+		}
+	}
+	line, _ := json.Marshal(*got)
+	for _, secret := range []string{"old-pw", "new-pw", "alice-pw", "secret-uuid", "main-token"} {
+		if strings.Contains(string(line), secret) {
+			t.Errorf("audit entries contain %s: %s", secret, line)
+		}
+	}
+}
+
+func TestAuditChangesFailedAttempt(t *testing.T) {
+	got := captureAudit(t)
+	s := &Store{Nodes: []Node{{ID: "hy2", Type: TypeHysteria2}}}
+	auditChanges(s, cloneStore(s), nil)
+	if len(*got) != 0 {
+		t.Fatalf("no-op update audited: %+v", *got)
+	}
+
+	// fn 在动手之前就拒绝了
+	auditChanges(s, cloneStore(s), errors.New("token friend 已存在"))
+	if len(*got) != 1 || (*got)[0].Action != "store.update" || (*got)[0].Outcome != audit.OutcomeFailed {
+		t.Fatalf("rejected update = %+v", *got)
+	}
+
+	// 改到一半失败：改过的字段照记，标失败
+	*got = nil
+	after := cloneStore(s)
+	after.Nodes[0].Port = 8443
+	auditChanges(s, after, errors.New("disk full"))
+	if len(*got) != 1 || (*got)[0].Action != "node.update" || (*got)[0].Error != "disk full" {
+		t.Fatalf("failed update = %+v", *got)
+	}
+}
@@ -0,0 +1,450 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/audit"
+)
+
+// nodes.json 静态加密 (opt-in，`proxy-manager store encrypt` 开启)。
+//
+// 两把 key：
+//   - master.key   root 0600。加密只有服务端内核需要的字段 (Reality
+//     private_key)，subscribe daemon 拿不到，渲染客户端配置也用不到。
+//   - subscribe.key 由 master 派生，root:proxy-manager 0640。加密客户端
+//     凭据 (uuid / password / obfs_password / 多用户凭据) 和订阅 token——
+//     daemon 渲染订阅、校验 token 必须能解开。
+//
+// 密文以字符串形式原位存放："enc:v1:<m|s>:<base64(nonce||AES-256-GCM)>"，
+// 所以 schema / diff / 快照都不用改。subscribe.key 存在即视为开启；开启后
+// 写盘时拿不到对应 key 的明文字段会直接报错，不会悄悄写回明文。
+
+const (
+	MasterKeyPath    = StoreDir + "/master.key"
+	SubscribeKeyPath = StoreDir + "/subscribe.key"
+
+	encPrefix       = "enc:v1:"
+	keyIDMaster     = "m"
+	keyIDSubscribe  = "s"
+	subscribeKeyTag = "proxy-manager/subscribe/v1"
+)
+
+// serverOnlyParams 只有内核 config 用得到，用 master key。
+var serverOnlyParams = map[string]bool{"private_key": true}
+
+// clientSecretParams 会进客户端配置，用 subscribe key。
+var clientSecretParams = map[string]bool{"uuid": true, "password": true, "obfs_password": true}
+
+// keyring holds whichever keys this process can read. nil = unavailable
+// (the subscribe daemon never has master).
+// on = 写盘时要加密；keyring{} 表示明文。
+type keyring struct {
+	on        bool
+	master    []byte
+	subscribe []byte
+}
+
+func loadKeyring() keyring {
+	return keyring{
+		on:        EncryptionEnabled(),
+		master:    readKeyFile(MasterKeyPath),
+		subscribe: readKeyFile(SubscribeKeyPath),
+	}
+}
+
+func readKeyFile(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil
+	}
+	return key
+}
+
+// EncryptionEnabled reports whether nodes.json secrets are encrypted at rest.
+func EncryptionEnabled() bool {
+	return fileExists(SubscribeKeyPath)
+}
+
+func newKeyring() (keyring, error) {
+	master := make([]byte, 32)
+	if _, err := rand.Read(master); err != nil {
+		return keyring{}, fmt.Errorf("rand: %w", err)
+	}
+	return keyring{on: true, master: master, subscribe: deriveSubscribeKey(master)}, nil
+}
+
+func deriveSubscribeKey(master []byte) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(subscribeKeyTag))
+	return mac.Sum(nil)
+}
+
+// writeKeyring persists both key files. suffix lets Rekey stage them next to
+// the live ones before swapping.
+func writeKeyring(k keyring, suffix string) error {
+	if err := os.MkdirAll(StoreDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(MasterKeyPath+suffix, []byte(hex.EncodeToString(k.master)+"\n"), 0600); err != nil {
+		return fmt.Errorf("写 master.key 失败: %w", err)
+	}
+	if err := os.WriteFile(SubscribeKeyPath+suffix, []byte(hex.EncodeToString(k.subscribe)+"\n"), 0640); err != nil {
+		return fmt.Errorf("写 subscribe.key 失败: %w", err)
+	}
+	// subscribe daemon 按组读 subscribe.key；用户不存在 (还没 enable 订阅)
+	// 时留 root:root，subscribe enable 时 prepareRuntimeDirs 会再处理。
+	if u, err := user.Lookup("proxy-manager"); err == nil {
+		if gid, err := strconv.Atoi(u.Gid); err == nil {
+			_ = os.Chown(SubscribeKeyPath+suffix, 0, gid)
+		}
+	}
+	return nil
+}
+
+func (k keyring) key(id string) []byte {
+	switch id {
+	case keyIDMaster:
+		return k.master
+	case keyIDSubscribe:
+		return k.subscribe
+	}
+	return nil
+}
+
+func isEncrypted(v string) bool { return strings.HasPrefix(v, encPrefix) }
+
+func (k keyring) encrypt(id, plain string) (string, error) {
+	if plain == "" || isEncrypted(plain) {
+		return plain, nil
+	}
+	key := k.key(id)
+	if key == nil {
+		if id == keyIDMaster {
+			return "", fmt.Errorf("nodes.json 已加密，写入私钥需要 %s (请用 root 运行)", MasterKeyPath)
+		}
+		return "", fmt.Errorf("nodes.json 已加密，但读不到 %s", SubscribeKeyPath)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), []byte(id))
+	return encPrefix + id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt returns v unchanged if it isn't ciphertext. A master-key value
+// without the master key is left encrypted (the daemon's normal case).
+func (k keyring) decrypt(v string) (string, error) {
+	if !isEncrypted(v) {
+		return v, nil
+	}
+	rest := strings.TrimPrefix(v, encPrefix)
+	id, b64, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", fmt.Errorf("密文格式错误")
+	}
+	key := k.key(id)
+	if key == nil {
+		if id == keyIDMaster {
+			return v, nil
+		}
+		return "", fmt.Errorf("nodes.json 已加密，但读不到 %s", SubscribeKeyPath)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("密文过短")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("解密失败 (key 不匹配?): %w", err)
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// eachSecret calls fn on every sensitive string field with the key id it
+// belongs to, replacing the field with fn's result.
+func eachSecret(s *Store, fn func(id, v string) (string, error)) error {
+	apply := func(id string, p *string) error {
+		out, err := fn(id, *p)
+		if err != nil {
+			return err
+		}
+		*p = out
+		return nil
+	}
+	for i := range s.Nodes {
+		n := &s.Nodes[i]
+		for k, v := range n.Params {
+			str, ok := v.(string)
+			if !ok {
+				continue
+			}
+			id := ""
+			switch {
+			case serverOnlyParams[k]:
+				id = keyIDMaster
+			case clientSecretParams[k]:
+				id = keyIDSubscribe
+			default:
+				continue
+			}
+			if err := apply(id, &str); err != nil {
+				return fmt.Errorf("%s.%s: %w", n.ID, k, err)
+			}
+			n.Params[k] = str
+		}
+		for j := range n.Users {
+			if err := apply(keyIDSubscribe, &n.Users[j].UUID); err != nil {
+				return err
+			}
+			if err := apply(keyIDSubscribe, &n.Users[j].Password); err != nil {
+				return err
+			}
+		}
+	}
+	sub := &s.Subscribe
+	for _, p := range []*string{&sub.Token, &sub.PreviousToken, &sub.MetricsToken, &sub.AdminToken} {
+		if err := apply(keyIDSubscribe, p); err != nil {
+			return err
+		}
+	}
+	for i := range sub.Tokens {
+		if err := apply(keyIDSubscribe, &sub.Tokens[i].Token); err != nil {
+			return err
+		}
+	}
+	for i := range sub.Upstreams {
+		if err := apply(keyIDSubscribe, &sub.Upstreams[i].URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decryptStore(s *Store, k keyring) error {
+	return eachSecret(s, func(_, v string) (string, error) { return k.decrypt(v) })
+}
+
+// encodeStore marshals s for disk, encrypting a copy when k is enabled so
+// the caller's in-memory store keeps plaintext.
+func encodeStore(s *Store, k keyring) ([]byte, error) {
+	if !k.on {
+		return json.MarshalIndent(s, "", "  ")
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var cp Store
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return nil, err
+	}
+	if err := eachSecret(&cp, k.encrypt); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&cp, "", "  ")
+}
+
+// decodeStore parses on-disk bytes into a migrated, decrypted store.
+func decodeStore(data []byte, k keyring) (*Store, error) {
+	var s Store
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse store: %w", err)
+	}
+	Migrate(&s)
+	if err := decryptStore(&s, k); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// EnableEncryption generates the key pair (if absent) and rewrites
+// nodes.json and existing snapshots with secrets encrypted.
+func EnableEncryption() (err error) {
+	defer func() { audit.Record(audit.Entry{Action: "store.encrypt"}, err) }()
+	if EncryptionEnabled() {
+		return fmt.Errorf("nodes.json 已经是加密状态 (换 key 用 store rekey)")
+	}
+	k, err := newKeyring()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	defer lockStore(true)()
+	s, err := loadLocked()
+	if err != nil {
+		return err
+	}
+	if err := writeKeyring(k, ""); err != nil {
+		return err
+	}
+	if err := saveLocked(s); err != nil {
+		return err
+	}
+	return recodeSnapshots(keyring{}, k)
+}
+
+// Rekey re-encrypts nodes.json and snapshots under a fresh master key.
+//
+// 顺序：新 key 先写到 .new → 用新 key 写 nodes.json / 快照 → rename 替换
+// 旧 key。中途失败时 .new 文件还在，手工改名即可恢复。
+func Rekey() (err error) {
+	defer func() { audit.Record(audit.Entry{Action: "store.rekey"}, err) }()
+	if !EncryptionEnabled() {
+		return fmt.Errorf("nodes.json 未加密，先运行 proxy-manager store encrypt")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	defer lockStore(true)()
+	old := loadKeyring()
+	if old.master == nil {
+		return fmt.Errorf("读不到 %s (请用 root 运行)", MasterKeyPath)
+	}
+	s, err := loadLocked()
+	if err != nil {
+		return err
+	}
+	next, err := newKeyring()
+	if err != nil {
+		return err
+	}
+	if err := writeKeyring(next, ".new"); err != nil {
+		return err
+	}
+	data, err := encodeStore(s, next)
+	if err != nil {
+		return err
+	}
+	if err := writeStoreFile(data); err != nil {
+		return err
+	}
+	if err := recodeSnapshots(old, next); err != nil {
+		return err
+	}
+	if err := os.Rename(MasterKeyPath+".new", MasterKeyPath); err != nil {
+		return err
+	}
+	return os.Rename(SubscribeKeyPath+".new", SubscribeKeyPath)
+}
+
+// DisableEncryption writes nodes.json and snapshots back in plaintext and
+// removes the key files.
+func DisableEncryption() (err error) {
+	defer func() { audit.Record(audit.Entry{Action: "store.decrypt"}, err) }()
+	mu.Lock()
+	defer mu.Unlock()
+	defer lockStore(true)()
+	old := loadKeyring()
+	if old.master == nil {
+		return fmt.Errorf("读不到 %s (请用 root 运行)", MasterKeyPath)
+	}
+	s, err := loadLocked()
+	if err != nil {
+		return err
+	}
+	data, err := encodeStore(s, keyring{})
+	if err != nil {
+		return err
+	}
+	if err := recodeSnapshots(old, keyring{}); err != nil {
+		return err
+	}
+	return finishDecrypt(data, writeStoreFile, SubscribeKeyPath, MasterKeyPath)
+}
+
+// finishDecrypt writes the plaintext store and only then removes the key
+// files. 反过来的话写盘失败 (磁盘满 / EIO) 会留下加密的 nodes.json 和缺了
+// 的 key，CLI 和 daemon 之后都 Load 不了。
+func finishDecrypt(data []byte, write func([]byte) error, keyPaths ...string) error {
+	if err := write(data); err != nil {
+		return err
+	}
+	for _, p := range keyPaths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// recodeSnapshots 快照也是 nodes.json 的完整副本，换 key 时一起重写，否则
+// 旧快照在 rekey 之后就 restore 不了了。
+func recodeSnapshots(from, to keyring) error {
+	snaps, err := ListSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, sn := range snaps {
+		path := filepath.Join(snapshotDir, sn.ID+".json")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		s, err := decodeStore(data, from)
+		if err != nil {
+			return fmt.Errorf("快照 %s: %w", sn.ID, err)
+		}
+		out, err := encodeStore(s, to)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, out, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientCopy returns n without server-only params (private_key), for output
+// that leaves the server (json subscription / export). 额外用户的凭据
+// (Users) 和运维备注 (Note) 也不出去：拿到这份 json 的客户端只该有节点的
+// 默认凭据。Tags 留着：upstream 合并进来的节点要靠它匹配 token 的 --tags
+// 和 ?tag=。
+func (n Node) ClientCopy() Node {
+	n.Users, n.Note = nil, ""
+	params := make(map[string]any, len(n.Params))
+	for k, v := range n.Params {
+		if !serverOnlyParams[k] {
+			params[k] = v
+		}
+	}
+	n.Params = params
+	return n
+}
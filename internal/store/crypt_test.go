@@ -0,0 +1,100 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeStoreRoundTrip(t *testing.T) {
+	k, err := newKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Store{
+		Version:   StoreVersion,
+		Subscribe: SubscribeConfig{Token: "maintoken"},
+		Nodes: []Node{{
+			ID:   "vless-reality-1.2.3.4",
+			Type: TypeVLESSReality,
+			Params: map[string]any{
+				"uuid":        "11111111-2222-4333-8444-555555555555",
+				"private_key": "server-secret",
+				"public_key":  "pub",
+			},
+			Users: []User{{Name: "alice", UUID: "alice-uuid"}},
+		}},
+	}
+	data, err := encodeStore(s, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"maintoken", "server-secret", "alice-uuid", "11111111-2222"} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("plaintext %q leaked into encoded store", secret)
+		}
+	}
+	if !strings.Contains(string(data), `"public_key": "pub"`) {
+		t.Fatal("non-secret params should stay in plaintext")
+	}
+	if s.Nodes[0].Params["private_key"] != "server-secret" {
+		t.Fatal("encodeStore must not mutate the caller's store")
+	}
+
+	// subscribe daemon: only the subscribe key
+	daemon := keyring{on: true, subscribe: k.subscribe}
+	got, err := decodeStore(data, daemon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Subscribe.Token != "maintoken" || got.Nodes[0].Users[0].UUID != "alice-uuid" {
+		t.Fatal("daemon should decrypt client secrets")
+	}
+	if pk, _ := got.Nodes[0].Params["private_key"].(string); !isEncrypted(pk) {
+		t.Fatal("daemon must not be able to read the private key")
+	}
+
+	full, err := decodeStore(data, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Nodes[0].Params["private_key"] != "server-secret" {
+		t.Fatal("root should decrypt server-only secrets")
+	}
+}
+
+// 写 nodes.json 失败时 key 文件必须还在，否则加密的 store 再也读不出来。
+func TestFinishDecryptKeepsKeysOnWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	keys := []string{filepath.Join(dir, "subscribe.key"), filepath.Join(dir, "master.key")}
+	for _, p := range keys {
+		if err := os.WriteFile(p, []byte("key\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	failing := func([]byte) error { return errors.New("no space left on device") }
+	if err := finishDecrypt([]byte("{}"), failing, keys...); err == nil {
+		t.Fatal("write failure should be returned")
+	}
+	for _, p := range keys {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("%s removed after failed write: %v", filepath.Base(p), err)
+		}
+	}
+
+	var written []byte
+	ok := func(b []byte) error { written = b; return nil }
+	if err := finishDecrypt([]byte("{}"), ok, keys...); err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != "{}" {
+		t.Fatalf("store not written: %q", written)
+	}
+	for _, p := range keys {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed after a successful write", filepath.Base(p))
+		}
+	}
+}
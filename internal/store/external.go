@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 外部节点：不是本机装的，由 `node import` 从分享链接 / Clash / sing-box
+// 配置解析进来 (format.ParseNodes)，只为了出现在本机订阅里。ID 按
+// 类型 + 地址生成，同一个节点重复导入是更新而不是多一份。
+
+// ExternalIDPrefix 开头的 ID 都是外部节点；本机节点的 ID 是 `<type>-<ip>`。
+const ExternalIDPrefix = "ext-"
+
+// ExternalID derives the stable ID of an imported node.
+func ExternalID(t NodeType, server string, port int) string {
+	host := strings.NewReplacer(":", "-", "[", "", "]", "").Replace(strings.ToLower(server))
+	return ExternalIDPrefix + string(t) + "-" + host + "-" + strconv.Itoa(port)
+}
+
+// ImportExternal upserts imported nodes in one write. Existing entries keep
+// Note / Disabled / CreatedAt and gain the new tags; everything that came
+// from the link (name, params) is replaced. Returns how many were new.
+func ImportExternal(nodes []Node) (added int, err error) {
+	for _, n := range nodes {
+		if !n.External || !strings.HasPrefix(n.ID, ExternalIDPrefix) {
+			return 0, fmt.Errorf("%s 不是外部节点", n.ID)
+		}
+		for _, t := range n.Tags {
+			if err := ValidateTag(t); err != nil {
+				return 0, err
+			}
+		}
+	}
+	now := time.Now().UTC()
+	err = Update(func(s *Store) error {
+		added = 0
+	next:
+		for _, n := range nodes {
+			for i := range s.Nodes {
+				cur := &s.Nodes[i]
+				if cur.ID != n.ID {
+					continue
+				}
+				if !cur.External {
+					return fmt.Errorf("%s 是本机节点，不能被导入覆盖", n.ID)
+				}
+				n.Note, n.Disabled, n.CreatedAt = cur.Note, cur.Disabled, cur.CreatedAt
+				n.Tags = mergeTags(cur.Tags, n.Tags)
+				*cur = n
+				continue next
+			}
+			if n.CreatedAt.IsZero() {
+				n.CreatedAt = now
+			}
+			s.Nodes = append(s.Nodes, n)
+			added++
+		}
+		return nil
+	})
+	return added, err
+}
+
+func mergeTags(a, b []string) []string {
+	out := append([]string{}, a...)
+	for _, t := range b {
+		if !containsString(out, t) {
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package store
+
+// 内核名，也是 Store.Kernels 的 key。
+const (
+	KernelXray    = "xray-core"
+	KernelSingbox = "sing-box"
+)
+
+// KernelFor maps a node type to the kernel binary that serves it. Reality
+// 从 v4.0.7 起跑 xray，其余协议都是 sing-box。
+func KernelFor(t NodeType) string {
+	if t == TypeVLESSReality {
+		return KernelXray
+	}
+	return KernelSingbox
+}
+
+// SetKernelVersion records the version of kernel name installed on this
+// machine, for when the binary can't be asked (install/kernel.go). 不进快照
+// 和审计：升级本身已经记过 kernel.upgrade。
+func SetKernelVersion(name, version string) error {
+	if version == "" {
+		return nil
+	}
+	return update(func(s *Store) error {
+		if s.Kernels == nil {
+			s.Kernels = map[string]string{}
+		}
+		s.Kernels[name] = version
+		return nil
+	}, false)
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HasTag reports whether tag names n's type or is one of n.Tags. 类型也算
+// tag，这样 "hysteria2" 这种按协议筛选不用给每个节点手工打标。
+func (n Node) HasTag(tag string) bool {
+	if tag == string(n.Type) {
+		return true
+	}
+	for _, t := range n.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyTag is HasTag over a list (OR). An empty list matches nothing.
+func (n Node) HasAnyTag(tags []string) bool {
+	for _, t := range tags {
+		if n.HasTag(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Published returns the nodes that belong in a subscription / export:
+// enabled, and matching at least one of tags when tags is non-empty.
+func Published(nodes []Node, tags []string) []Node {
+	out := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Disabled {
+			continue
+		}
+		if len(tags) > 0 && !n.HasAnyTag(tags) {
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// ValidateTag rejects tags that would break the comma-separated CLI flags
+// and query strings they travel through.
+func ValidateTag(tag string) error {
+	if tag == "" || len(tag) > 64 || strings.ContainsAny(tag, ", \t\n&#?/") {
+		return fmt.Errorf("tag %q 无效: ≤64 位，不含空白 / 逗号 / & # ? /", tag)
+	}
+	return nil
+}
+
+// SetNodeTags replaces the node's tags (nil clears them). Duplicates are
+// dropped, order kept.
+func SetNodeTags(nodeID string, tags []string) (Node, error) {
+	var clean []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		if err := ValidateTag(t); err != nil {
+			return Node{}, err
+		}
+		if !seen[t] {
+			seen[t] = true
+			clean = append(clean, t)
+		}
+	}
+	return updateNode(nodeID, func(n *Node) error {
+		n.Tags = clean
+		return nil
+	})
+}
+
+// SetNodeNote sets the free-form operator note ("" clears it).
+func SetNodeNote(nodeID, note string) (Node, error) {
+	return updateNode(nodeID, func(n *Node) error {
+		n.Note = strings.TrimSpace(note)
+		return nil
+	})
+}
+
+// SetNodeDisabled takes a node out of (or back into) rotation. Credentials
+// are untouched; stopping the unit is the install layer's job.
+func SetNodeDisabled(nodeID string, disabled bool) (Node, error) {
+	return updateNode(nodeID, func(n *Node) error {
+		n.Disabled = disabled
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublished(t *testing.T) {
+	nodes := []Node{
+		{ID: "reality", Type: TypeVLESSReality, Tags: []string{"region=hk", "fast"}},
+		{ID: "hy2", Type: TypeHysteria2, Tags: []string{"region=jp"}},
+		{ID: "anytls", Type: TypeAnyTLS, Tags: []string{"region=hk"}, Disabled: true},
+		{ID: "ext", Type: TypeAnyTLSReality, External: true},
+	}
+	for _, c := range []struct {
+		name string
+		tags []string
+		want string
+	}{
+		{"no filter skips disabled", nil, "reality,hy2,ext"},
+		{"key=value tag", []string{"region=hk"}, "reality"}, // anytls 也是 hk 但下线了
+		{"plain tag", []string{"fast"}, "reality"},
+		{"type as tag", []string{"hysteria2"}, "hy2"},
+		{"disabled by type", []string{"anytls"}, ""},
+		{"any of several", []string{"region=jp", "anytls-reality"}, "hy2,ext"},
+		{"key alone is not key=value", []string{"region"}, ""},
+		{"value must match exactly", []string{"region=h"}, ""},
+		{"unknown", []string{"nope"}, ""},
+	} {
+		var ids []string
+		for _, n := range Published(nodes, c.tags) {
+			ids = append(ids, n.ID)
+		}
+		if got := strings.Join(ids, ","); got != c.want {
+			t.Errorf("%s: Published(%v) = %q, want %q", c.name, c.tags, got, c.want)
+		}
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	for tag, ok := range map[string]bool{
+		"hk":                    true,
+		"region=hk":             true,
+		"":                      false,
+		"a,b":                   false,
+		"a b":                   false,
+		"a&b":                   false,
+		"a/b":                   false,
+		strings.Repeat("x", 64): true,
+		strings.Repeat("x", 65): false,
+	} {
+		if err := ValidateTag(tag); (err == nil) != ok {
+			t.Errorf("ValidateTag(%q) err = %v, want ok=%v", tag, err, ok)
+		}
+	}
+}
+
+// node tag / node disable 落到 nodes.json 之后 Published 看得到。
+func TestSetNodeTagsAndDisabled(t *testing.T) {
+	useTempStore(t)
+	if err := Update(func(s *Store) error {
+		s.Nodes = []Node{{ID: "hy2", Type: TypeHysteria2}}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := SetNodeTags("hy2", []string{"region=hk", "fast", "region=hk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(n.Tags, ",") != "region=hk,fast" {
+		t.Errorf("tags = %v, want duplicates dropped in order", n.Tags)
+	}
+	if _, err := SetNodeTags("hy2", []string{"bad,tag"}); err == nil {
+		t.Error("invalid tag accepted")
+	}
+	if _, err := SetNodeDisabled("hy2", true); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Published(s.Nodes, []string{"region=hk"}); len(got) != 0 {
+		t.Errorf("disabled node published: %+v", got)
+	}
+	if _, err := SetNodeDisabled("hy2", false); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = Load()
+	if got := Published(s.Nodes, []string{"fast"}); len(got) != 1 || strings.Join(got[0].Tags, ",") != "region=hk,fast" {
+		t.Errorf("re-enabled node = %+v", got)
+	}
+}
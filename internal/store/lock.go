@@ -0,0 +1,48 @@
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// LockPath 是 nodes.json 的跨进程 advisory lock。
+//
+// mu 只挡得住同一进程里的 goroutine；CLI (root) 和 proxy-manager-subscribe
+// daemon 是两个进程，两个并发的 install / edit 也是。所有读走 LOCK_SH、所有
+// read-modify-write 走 LOCK_EX (见 Update)，跟 mu 叠加使用。
+//
+// 单独用一个 .lock 文件而不是直接 flock nodes.json：saveLocked 是
+// tmp + rename，rename 之后旧 inode 上的锁就不再代表新文件了。
+const LockPath = StoreDir + "/nodes.json.lock"
+
+var lockPath = LockPath
+
+// lockStore takes the advisory lock and returns the release func.
+//
+// Best-effort：锁文件打不开 (非 root 只读诊断、StoreDir 还没建) 时退化成
+// 只有进程内 mu，不让读路径因此失败——真正的写操作在那种环境下本来也会
+// 在 saveLocked 报权限错。
+func lockStore(exclusive bool) func() {
+	if exclusive {
+		_ = os.MkdirAll(storeDir, 0755)
+	}
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		// daemon 对锁文件只有读权限时 (老部署还没 chown 过)，只读 fd 也能 flock
+		if f, err = os.Open(lockPath); err != nil {
+			return func() {}
+		}
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return func() {}
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+}
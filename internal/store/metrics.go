@@ -0,0 +1,62 @@
+package store
+
+import (
+	"fmt"
+	"net"
+)
+
+// RotateMetricsToken issues a new bearer token for /metrics on the
+// subscription port. 旧 token 立即失效 (只有 Prometheus 在用，改一处配置)。
+func RotateMetricsToken() (string, error) {
+	token, err := generateToken(16)
+	if err != nil {
+		return "", err
+	}
+	err = Update(func(s *Store) error {
+		s.Subscribe.MetricsToken = token
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateMetricsListen accepts host:port on a loopback address only: the
+// plain-HTTP metrics listener has no auth, so it must not face the network.
+func ValidateMetricsListen(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "" {
+		return fmt.Errorf("监听地址格式错误: %s (示例: 127.0.0.1:9101)", addr)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("metrics 明文端口只能监听 loopback (127.0.0.1 / ::1)，远程抓取用 token")
+	}
+	return nil
+}
+
+// SetMetricsListen sets (or with "" clears) the loopback metrics listener.
+func SetMetricsListen(addr string) error {
+	if addr != "" {
+		if err := ValidateMetricsListen(addr); err != nil {
+			return err
+		}
+	}
+	return Update(func(s *Store) error {
+		s.Subscribe.MetricsListen = addr
+		return nil
+	})
+}
+
+// DisableMetrics drops both the token and the listener.
+func DisableMetrics() error {
+	return Update(func(s *Store) error {
+		s.Subscribe.MetricsToken = ""
+		s.Subscribe.MetricsListen = ""
+		return nil
+	})
+}
@@ -2,49 +2,128 @@ package store
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/audit"
 )
 
-// LegacyPaths is the set of pre-store .txt config files this package can
-// migrate. Kept exported so install/uninstall code can detect/remove them.
+// LegacyPaths is the set of pre-store .txt config files LoadOrMigrate
+// imports. Exported for doctor and backup (old archives still carry them).
 var LegacyPaths = []string{
 	"/etc/reality-proxy-config.txt",
 	"/etc/hysteria2-proxy-config.txt",
 	"/etc/anytls-proxy-config.txt",
+	"/etc/anytls-reality-proxy-config.txt",
 }
 
-// LoadOrMigrate reads the store, importing from legacy .txt files on the first
-// call (when nodes.json doesn't yet exist). Idempotent and safe to call from
-// any code path before reading nodes.
+// LoadOrMigrate reads the store, bringing it up to date on disk first:
+// schema migrations (see migrations) and a one-time import of any legacy
+// .txt file still lying around. Safe to call from any code path before
+// reading nodes.
+//
+// 导入成功落盘后 .txt 即删除，此后 nodes.json 是唯一的数据源。非 root 写
+// 不了时只在内存里升级/导入，.txt 留着，下次 root 跑时再落盘。
 func LoadOrMigrate() (*Store, error) {
-	if _, err := os.Stat(StorePath); err == nil {
-		return Load()
-	} else if !os.IsNotExist(err) {
+	mu.Lock()
+	defer mu.Unlock()
+	defer lockStore(true)()
+	_, statErr := os.Stat(storePath)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return nil, statErr
+	}
+	s, err := readLocked()
+	if err != nil {
 		return nil, err
 	}
+	applied := applyMigrations(s)
+	if err := decryptStore(s, loadKeyring()); err != nil {
+		return nil, err
+	}
+	imported := importLegacyTxt(s)
+	s.checkNodes()
+	if len(applied) == 0 && len(imported) == 0 && statErr == nil {
+		return s, nil
+	}
+	// Best-effort：非 root 写不了时照样返回内存里的结果。升级前的原文件
+	// 留一份快照，migration 写坏了还能 restore。
+	snapshotBeforeSave(s)
+	if err := saveLocked(s); err != nil {
+		return s, nil
+	}
+	for _, p := range imported {
+		applied = append(applied, "导入 "+p)
+		_ = os.Remove(p)
+	}
+	if len(applied) > 0 {
+		audit.Record(audit.Entry{Action: "store.migrate", Detail: strings.Join(applied, "; ")}, nil)
+	}
+	return s, nil
+}
+
+// importLegacyTxt merges every readable legacy .txt into s and returns the
+// paths it consumed. 老格式一种协议只有一个节点，所以按 Type 对应：store
+// 里没有该协议就新增；已有就只补它缺的 params (v4.0.25 起 anytls-reality
+// 的 private_key 只写进了 .txt)，store 里已有的值一律不覆盖。
+func importLegacyTxt(s *Store) []string {
+	return importLegacy(s, readLegacyTxt)
+}
+
+// ImportLegacyData is the .txt import over in-memory contents keyed by path,
+// for backup restore to check an old archive before anything hits disk.
+func ImportLegacyData(s *Store, files map[string][]byte) []string {
+	return importLegacy(s, func(path string) (map[string]string, error) {
+		data, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return parseLegacyTxt(bytes.NewReader(data))
+	})
+}
 
-	s := &Store{Version: StoreVersion}
+func importLegacy(s *Store, read func(path string) (map[string]string, error)) []string {
+	var imported []string
 	for _, p := range LegacyPaths {
-		if _, err := os.Stat(p); err != nil {
+		kv, err := read(p)
+		if err != nil {
+			continue // 不存在，或非 root 读不了
+		}
+		legacy, ok := legacyToNode(kv)
+		if !ok {
 			continue
 		}
-		kv, err := readLegacyTxt(p)
-		if err != nil {
-			return nil, fmt.Errorf("read legacy %s: %w", p, err)
+		if v := kv["SINGBOX_VERSION"]; v != "" {
+			// 字段名是历史遗留，reality 的 .txt 里存的其实是 xray 版本
+			k := KernelFor(legacy.Type)
+			if s.Kernels[k] == "" {
+				if s.Kernels == nil {
+					s.Kernels = map[string]string{}
+				}
+				s.Kernels[k] = v
+			}
 		}
-		node, ok := legacyToNode(kv)
+		imported = append(imported, p)
+		existing, ok := s.LocalNode(legacy.Type)
 		if !ok {
+			legacy.CreatedAt = time.Now().UTC()
+			s.Nodes = append(s.Nodes, legacy)
 			continue
 		}
-		s.Nodes = append(s.Nodes, node)
+		if existing.Params == nil {
+			existing.Params = map[string]any{}
+		}
+		for k, v := range legacy.Params {
+			if cur, ok := existing.Params[k]; !ok || cur == "" {
+				existing.Params[k] = v
+			}
+		}
 	}
-	// Best-effort persist: callers may be non-root (e.g. local diagnostics),
-	// in which case we still return the in-memory store rather than fail.
-	_ = Save(s)
-	return s, nil
+	return imported
 }
 
 func readLegacyTxt(path string) (map[string]string, error) {
@@ -53,8 +132,12 @@ func readLegacyTxt(path string) (map[string]string, error) {
 		return nil, err
 	}
 	defer f.Close()
+	return parseLegacyTxt(f)
+}
+
+func parseLegacyTxt(r io.Reader) (map[string]string, error) {
 	out := map[string]string{}
-	sc := bufio.NewScanner(f)
+	sc := bufio.NewScanner(r)
 	for sc.Scan() {
 		line := strings.TrimSpace(sc.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
@@ -118,10 +201,92 @@ func legacyToNode(kv map[string]string) (Node, bool) {
 				"padding_name": kv["PADDING_NAME"],
 			},
 		}, true
+	case "anytls-reality":
+		return Node{
+			ID:     fmt.Sprintf("anytls-reality-%s", ip),
+			Name:   fmt.Sprintf("AnyTLS-Reality@%s", ip),
+			Type:   TypeAnyTLSReality,
+			Server: ip,
+			Port:   atoi(kv["PORT"]),
+			Params: map[string]any{
+				"password":    kv["PASSWORD"],
+				"private_key": kv["PRIVATE_KEY"],
+				"public_key":  kv["PUBLIC_KEY"],
+				"short_id":    kv["SHORT_ID"],
+				"server_name": kv["SERVER_NAME"],
+			},
+		}, true
 	}
 	return Node{}, false
 }
 
+// migration upgrades a store from version to-1 to version to. apply must be
+// idempotent: a store written by an older binary after a newer one read it
+// may carry a lower version than its contents suggest.
+type migration struct {
+	to    int
+	name  string
+	apply func(*Store)
+}
+
+// migrations 是 nodes.json 的 schema 升级链，按 to 递增。加新 schema 改动：
+// 在末尾追加一项 + StoreVersion 加一，不要改已发布的项。
+var migrations = []migration{
+	{to: 2, name: "节点 ID/Name 按 ServerIP 唯一化 (v4.0.33)", apply: rewriteStaticIDs},
+}
+
+// applyMigrations runs every migration newer than s.Version in order and
+// returns the names of those applied.
+func applyMigrations(s *Store) []string {
+	var applied []string
+	for _, m := range migrations {
+		if s.Version >= m.to {
+			continue
+		}
+		m.apply(s)
+		s.Version = m.to
+		applied = append(applied, m.name)
+	}
+	return applied
+}
+
+// Migrate brings a store parsed outside this package (snapshot, backup) up
+// to StoreVersion in memory.
+func Migrate(s *Store) []string {
+	if s.Version == 0 {
+		s.Version = 1
+	}
+	return applyMigrations(s)
+}
+
+// SchemaStatus describes the on-disk schema relative to this binary, for
+// `doctor`.
+type SchemaStatus struct {
+	OnDisk  int      // nodes.json 里记录的版本 (文件不存在时 = Current)
+	Current int      // 本 binary 的 StoreVersion
+	Pending []string // 还没落盘的 migration
+}
+
+// ReadSchemaStatus reports the version recorded in nodes.json and which
+// migrations are still pending. OnDisk > Current means the file was written
+// by a newer binary.
+func ReadSchemaStatus() (SchemaStatus, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	defer lockStore(false)()
+	s, err := readLocked()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	st := SchemaStatus{OnDisk: s.Version, Current: StoreVersion}
+	for _, m := range migrations {
+		if s.Version < m.to {
+			st.Pending = append(st.Pending, m.name)
+		}
+	}
+	return st, nil
+}
+
 // rewriteStaticIDs 把 v4.0.32 之前用的"全 VPS 共享"的静态 ID/Name 改写成
 // 按 ServerIP 唯一化的形式。XSurge 合并多个订阅的节点时按 node.id 索引
 // nodeOverrides;静态 ID 撞 key 导致重命名串台 (一个订阅改名,所有订阅
@@ -130,8 +295,8 @@ func legacyToNode(kv map[string]string) (Node, bool) {
 // 唯一 ID,不必等用户重装协议。
 //
 // 幂等:已经唯一化的 ID 不再处理 (前缀匹配 + "-" 后还有内容才算静态)。
-// 不写盘:loadLocked 是只读路径,subscribe 用非 root 用户跑没写权;下次
-// install/edit 走 saveLocked 时自然落盘。
+// 作为 migration 2 运行:loadLocked 每次在内存里应用 (subscribe daemon 只读
+// 也能拿到新 ID),LoadOrMigrate / 任意一次 Update 负责落盘。
 func rewriteStaticIDs(s *Store) {
 	for i := range s.Nodes {
 		n := &s.Nodes[i]
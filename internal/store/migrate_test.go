@@ -0,0 +1,70 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyMigrationsFromV1(t *testing.T) {
+	s := &Store{
+		Version: 1,
+		Nodes: []Node{
+			{ID: "vless-reality", Name: "VLESS-Reality", Type: TypeVLESSReality, Server: "1.2.3.4"},
+		},
+	}
+	applied := applyMigrations(s)
+	if len(applied) != len(migrations) {
+		t.Fatalf("expected %d migrations applied, got %v", len(migrations), applied)
+	}
+	if s.Version != StoreVersion {
+		t.Fatalf("version = %d, want %d", s.Version, StoreVersion)
+	}
+	if s.Nodes[0].ID != "vless-reality-1.2.3.4" {
+		t.Fatalf("static ID not rewritten: %s", s.Nodes[0].ID)
+	}
+	// 已是最新版本 → 不再跑任何 migration
+	if again := applyMigrations(s); len(again) != 0 {
+		t.Fatalf("migrations should be applied once, re-applied %v", again)
+	}
+}
+
+func TestImportLegacyTxt(t *testing.T) {
+	dir := t.TempDir()
+	reality := filepath.Join(dir, "reality-proxy-config.txt")
+	anytlsReality := filepath.Join(dir, "anytls-reality-proxy-config.txt")
+	writeFile(t, reality, "TYPE=reality\nSERVER_IP=1.2.3.4\nPORT=443\nUUID=u\nPRIVATE_KEY=priv\nSINGBOX_VERSION=v25.1.1\n")
+	writeFile(t, anytlsReality, "TYPE=anytls-reality\nSERVER_IP=1.2.3.4\nPORT=8443\nPASSWORD=old\nPRIVATE_KEY=priv2\n")
+	defer func(old []string) { LegacyPaths = old }(LegacyPaths)
+	LegacyPaths = []string{reality, anytlsReality, filepath.Join(dir, "missing.txt")}
+
+	// anytls-reality 已在 store 里但缺 private_key (v4.0.25 的老节点)
+	s := &Store{Nodes: []Node{{
+		ID: "anytls-reality-1.2.3.4", Type: TypeAnyTLSReality, Server: "1.2.3.4", Port: 9443,
+		Params: map[string]any{"password": "new"},
+	}}}
+	imported := importLegacyTxt(s)
+	if len(imported) != 2 {
+		t.Fatalf("imported = %v", imported)
+	}
+	if len(s.Nodes) != 2 || s.Nodes[1].ID != "vless-reality-1.2.3.4" || s.Nodes[1].Port != 443 {
+		t.Fatalf("reality node not added: %+v", s.Nodes)
+	}
+	atr := s.Nodes[0]
+	if atr.Params["private_key"] != "priv2" {
+		t.Fatalf("missing private_key not filled from .txt: %v", atr.Params)
+	}
+	if atr.Params["password"] != "new" || atr.Port != 9443 {
+		t.Fatalf("store values must win over .txt: %+v", atr)
+	}
+	if s.Kernels[KernelXray] != "v25.1.1" {
+		t.Fatalf("kernel version not imported: %v", s.Kernels)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,8 +1,9 @@
 // Package store implements the unified nodes.json storage layer.
 //
-// Each installed proxy node is a single entry in a flat list. Per-protocol
-// .txt config files (legacy) are kept for backward compatibility but the
-// JSON store is the canonical source of truth going forward.
+// Each installed proxy node is a single entry in a flat list, and it is the
+// only source of truth: kernel config.json files are rendered from it
+// (install/render.go). The per-protocol .txt files older versions wrote are
+// imported once by LoadOrMigrate and then removed.
 package store
 
 import (
@@ -19,9 +20,18 @@ import (
 )
 
 const (
-	StorePath    = "/etc/proxy-manager/nodes.json"
-	StoreDir     = "/etc/proxy-manager"
-	StoreVersion = 1
+	StorePath = "/etc/proxy-manager/nodes.json"
+	StoreDir  = "/etc/proxy-manager"
+	// StoreVersion 是当前 binary 写出的 schema 版本；老版本文件在 load 时
+	// 按 migrate.go 里的 migrations 链逐个升级。
+	StoreVersion = 2
+)
+
+// storePath / storeDir (以及 lockPath、snapshotDir) 是实际读写的位置，
+// 测试里换成临时目录。
+var (
+	storePath = StorePath
+	storeDir  = StoreDir
 )
 
 // NodeType enumerates the supported protocol kinds. Strings, not iota, so
@@ -38,7 +48,12 @@ const (
 )
 
 // Node is a single installed proxy. Params holds protocol-specific fields;
-// keys vary by Type. Generators consume Type+Params to render client configs.
+// keys vary by Type. Generators read them through DecodeParams (params.go),
+// which returns the typed struct for Type and rejects incomplete nodes.
+//
+// Params 里的 uuid / password 是节点的默认凭据 (default 用户)；Users 是额外
+// 共享这台 VPS 的人，每人一份独立凭据，内核 config 的 clients/users 数组
+// 由两者合并生成 (见 install/users.go)。
 type Node struct {
 	ID        string         `json:"id"`
 	Name      string         `json:"name"`
@@ -46,7 +61,19 @@ type Node struct {
 	Server    string         `json:"server"`
 	Port      int            `json:"port"`
 	Params    map[string]any `json:"params"`
+	Users     []User         `json:"users,omitempty"`
 	CreatedAt time.Time      `json:"created_at"`
+
+	// 运维元数据 (labels.go)，不进客户端配置。Disabled 的节点凭据保留，
+	// 只是订阅 / 导出里不出现、systemd unit 停着。
+	Tags     []string `json:"tags,omitempty"`
+	Note     string   `json:"note,omitempty"`
+	Disabled bool     `json:"disabled,omitempty"`
+
+	// External 节点不在本机 (node import 从分享链接 / 客户端配置导入)：
+	// 只进订阅和导出，没有 systemd unit 和内核 config，安装 / 卸载 /
+	// 重建 / 按协议名查找都不碰它。
+	External bool `json:"external,omitempty"`
 }
 
 // SubscribeConfig is reserved for PR2 (subscription server). The token field
@@ -61,6 +88,31 @@ type SubscribeConfig struct {
 	Port                   int       `json:"port,omitempty"`
 	PreviousToken          string    `json:"previous_token,omitempty"`
 	PreviousTokenExpiresAt time.Time `json:"previous_token_expires_at,omitempty"`
+
+	// Tokens 是按人发放的附加 token (见 tokens.go)。上面的 Token 仍是管理员
+	// 自己用的主 token，看得到全部节点。
+	Tokens []AccessToken `json:"tokens,omitempty"`
+
+	// Upstreams 是要合并进本机订阅的其他服务器 (upstreams.go)。
+	Upstreams []Upstream `json:"upstreams,omitempty"`
+
+	// 主 token 的 Subscription-Userinfo 展示字段 (quota.go)：流量额度和
+	// 套餐 / VPS 到期日，只给客户端看，不会让主 token 失效。
+	QuotaBytes    int64     `json:"quota_bytes,omitempty"`
+	PlanExpiresAt time.Time `json:"plan_expires_at,omitempty"`
+
+	// UpdateIntervalHours 是 Profile-Update-Interval，0 = DefaultUpdateInterval。
+	UpdateIntervalHours int `json:"update_interval_hours,omitempty"`
+
+	// /metrics 的访问方式 (metrics.go)：HTTPS 端口上凭 bearer token，或者
+	// 只监听 loopback 的明文端口。两个都空 = 不暴露。
+	MetricsToken  string `json:"metrics_token,omitempty"`
+	MetricsListen string `json:"metrics_listen,omitempty"`
+
+	// /api/v1 管理接口 (admin.go)：bearer token 或 AdminClientCA 签发的客户
+	// 端证书 (mTLS)，两个都空 = 不开。
+	AdminToken    string `json:"admin_token,omitempty"`
+	AdminClientCA string `json:"admin_client_ca,omitempty"`
 }
 
 // PreviousTokenGracePeriod 控制 RotateToken 后旧 token 还能用多久。
@@ -72,20 +124,85 @@ type Store struct {
 	Version   int             `json:"version"`
 	Subscribe SubscribeConfig `json:"subscribe"`
 	Nodes     []Node          `json:"nodes"`
+
+	// Kernels 是本机各内核装的版本 (KernelXray / KernelSingbox → "v1.12.0")，
+	// 取代旧 .txt 里的 SINGBOX_VERSION。
+	Kernels map[string]string `json:"kernels,omitempty"`
+
+	// Invalid 是读盘时 params 校验不过的节点 (checkNodes)，不落盘。节点本身
+	// 仍在 Nodes 里：store 照常可用，订阅 / 导出跳过它们。
+	Invalid []*NodeError `json:"-"`
 }
 
+// mu serialises store access inside one process; lockStore (lock.go) does
+// the same across processes. Always take mu first, then the flock.
 var mu sync.Mutex
 
 // Load reads the store file. If it doesn't exist, returns an empty store.
-// Migration from legacy .txt configs is performed by callers of LoadOrMigrate.
+// Importing legacy .txt configs is left to LoadOrMigrate. Nodes whose params
+// don't validate are reported in Invalid, not as an error.
 func Load() (*Store, error) {
 	mu.Lock()
 	defer mu.Unlock()
+	defer lockStore(false)()
 	return loadLocked()
 }
 
+// Update is the read-modify-write primitive: it holds both locks across
+// load → fn → save so concurrent CLI runs and the subscribe daemon can't
+// lose each other's changes. fn returning an error aborts without saving.
+//
+// 落盘前会把旧版本存一份快照 (snapshot.go)。
+func Update(fn func(*Store) error) error {
+	return update(fn, true)
+}
+
+// update is Update with the snapshot optional, for high-frequency
+// bookkeeping writes (kernel versions) that aren't worth a history entry.
+func update(fn func(*Store) error, snapshot bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+	defer lockStore(true)()
+	s, err := loadLocked()
+	if err != nil {
+		return err
+	}
+	// snapshot=false 的是内核版本这类记账写入，不进审计
+	var before *Store
+	if snapshot {
+		before = cloneStore(s)
+	}
+	if err := fn(s); err != nil {
+		if snapshot {
+			auditChanges(before, s, err)
+		}
+		return err
+	}
+	if !snapshot {
+		return saveLocked(s)
+	}
+	snapshotBeforeSave(s)
+	err = saveLocked(s)
+	auditChanges(before, s, err)
+	return err
+}
+
 func loadLocked() (*Store, error) {
-	data, err := os.ReadFile(StorePath)
+	s, err := readLocked()
+	if err != nil {
+		return nil, err
+	}
+	applyMigrations(s)
+	if err := decryptStore(s, loadKeyring()); err != nil {
+		return nil, err
+	}
+	s.checkNodes()
+	return s, nil
+}
+
+// readLocked parses nodes.json as-is, without running migrations.
+func readLocked() (*Store, error) {
+	data, err := os.ReadFile(storePath)
 	if os.IsNotExist(err) {
 		return &Store{Version: StoreVersion}, nil
 	}
@@ -97,35 +214,67 @@ func loadLocked() (*Store, error) {
 		return nil, fmt.Errorf("parse store: %w", err)
 	}
 	if s.Version == 0 {
-		s.Version = StoreVersion
+		s.Version = 1 // v1 之前没写 version 字段
 	}
-	rewriteStaticIDs(&s)
 	return &s, nil
 }
 
-// Save writes the store atomically (temp file + rename).
+// Save writes the store atomically (temp file + rename). It overwrites
+// whatever is on disk; prefer Update for read-modify-write.
 func Save(s *Store) error {
 	mu.Lock()
 	defer mu.Unlock()
-	return saveLocked(s)
+	defer lockStore(true)()
+	before, err := loadLocked()
+	if err != nil {
+		before = &Store{}
+	}
+	snapshotBeforeSave(s)
+	err = saveLocked(s)
+	auditChanges(before, s, err)
+	return err
+}
+
+// snapshotBeforeSave snapshots the on-disk store if s would change it.
+// 比较的是解密后的内容——加密写盘每次 nonce 都不同，比字节没有意义。
+func snapshotBeforeSave(s *Store) {
+	cur, err := os.ReadFile(storePath)
+	if err != nil {
+		return
+	}
+	if old, err := decodeStore(cur, loadKeyring()); err == nil && sameStore(old, s) {
+		return
+	}
+	snapshotLocked(cur)
+}
+
+func sameStore(a, b *Store) bool {
+	ja, err1 := json.Marshal(a)
+	jb, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(ja) == string(jb)
 }
 
 func saveLocked(s *Store) error {
 	if s.Version == 0 {
 		s.Version = StoreVersion
 	}
-	if err := os.MkdirAll(StoreDir, 0755); err != nil {
-		return fmt.Errorf("mkdir store dir: %w", err)
-	}
-	data, err := json.MarshalIndent(s, "", "  ")
+	data, err := encodeStore(s, loadKeyring())
 	if err != nil {
 		return fmt.Errorf("marshal store: %w", err)
 	}
-	tmp := StorePath + ".tmp"
+	return writeStoreFile(data)
+}
+
+// writeStoreFile is the tmp + rename half of saveLocked.
+func writeStoreFile(data []byte) error {
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return fmt.Errorf("mkdir store dir: %w", err)
+	}
+	tmp := storePath + ".tmp"
 	if err := os.WriteFile(tmp, data, 0600); err != nil {
 		return fmt.Errorf("write store tmp: %w", err)
 	}
-	if err := os.Rename(tmp, StorePath); err != nil {
+	if err := os.Rename(tmp, storePath); err != nil {
 		return fmt.Errorf("rename store: %w", err)
 	}
 	// install 跑 root 写出 root-owned 的 nodes.json，但 subscribe service
@@ -147,114 +296,145 @@ func chownToSubscribeUser() {
 	if err1 != nil || err2 != nil {
 		return
 	}
-	_ = os.Chown(StorePath, uid, gid)
-	_ = os.Chown(StoreDir, uid, gid)
+	_ = os.Chown(storePath, uid, gid)
+	_ = os.Chown(lockPath, uid, gid)
+	_ = os.Chown(storeDir, uid, gid)
+}
+
+// LocalNode returns this machine's node of type t (one per protocol);
+// external nodes are skipped.
+func (s *Store) LocalNode(t NodeType) (*Node, bool) {
+	for i := range s.Nodes {
+		if s.Nodes[i].Type == t && !s.Nodes[i].External {
+			return &s.Nodes[i], true
+		}
+	}
+	return nil, false
+}
+
+// FindNode resolves a CLI node reference: exact ID first, then Type (or a
+// short alias like "reality") as long as exactly one local node has that
+// type. External nodes are only reachable by ID.
+func (s *Store) FindNode(ref string) (*Node, error) {
+	for i := range s.Nodes {
+		if s.Nodes[i].ID == ref {
+			return &s.Nodes[i], nil
+		}
+	}
+	t := NodeType(ref)
+	switch ref {
+	case "reality", "vless":
+		t = TypeVLESSReality
+	case "hy2":
+		t = TypeHysteria2
+	}
+	var found *Node
+	for i := range s.Nodes {
+		if s.Nodes[i].Type != t || s.Nodes[i].External {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s 匹配多个节点，请用完整节点 ID", ref)
+		}
+		found = &s.Nodes[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("未找到节点: %s", ref)
+	}
+	return found, nil
 }
 
 // Upsert inserts a node, replacing any existing node with the same ID.
+// Tags / Note / Disabled are carried over from the existing node: install
+// paths rebuild Node from protocol config and know nothing about them.
+// Users 同理，调用方没给 (nil) 就沿用旧的，重装不会把多用户清掉。
 func Upsert(node Node) error {
-	mu.Lock()
-	defer mu.Unlock()
-	s, err := loadLocked()
-	if err != nil {
-		return err
-	}
 	if node.CreatedAt.IsZero() {
 		node.CreatedAt = time.Now().UTC()
 	}
-	replaced := false
+	return Update(func(s *Store) error {
+		s.upsert(node)
+		return nil
+	})
+}
+
+func (s *Store) upsert(node Node) {
 	for i, n := range s.Nodes {
 		if n.ID == node.ID {
+			node.Tags, node.Note, node.Disabled = n.Tags, n.Note, n.Disabled
+			if node.Users == nil {
+				node.Users = n.Users
+			}
 			s.Nodes[i] = node
-			replaced = true
-			break
+			return
 		}
 	}
-	if !replaced {
-		s.Nodes = append(s.Nodes, node)
-	}
-	return saveLocked(s)
+	s.Nodes = append(s.Nodes, node)
 }
 
 // RemoveByID removes a node. Missing IDs are not an error.
 func RemoveByID(id string) error {
-	mu.Lock()
-	defer mu.Unlock()
-	s, err := loadLocked()
-	if err != nil {
-		return err
-	}
-	out := s.Nodes[:0]
-	for _, n := range s.Nodes {
-		if n.ID != id {
-			out = append(out, n)
+	return Update(func(s *Store) error {
+		out := s.Nodes[:0]
+		for _, n := range s.Nodes {
+			if n.ID != id {
+				out = append(out, n)
+			}
 		}
-	}
-	s.Nodes = out
-	return saveLocked(s)
+		s.Nodes = out
+		return nil
+	})
 }
 
-// RemoveByType removes all nodes whose Type matches. Used by uninstall flows
-// where the legacy .txt format only supports one node per protocol.
+// RemoveByType removes all local nodes whose Type matches. Used by uninstall
+// flows, which manage one node per protocol on this machine.
 func RemoveByType(t NodeType) error {
-	mu.Lock()
-	defer mu.Unlock()
-	s, err := loadLocked()
-	if err != nil {
-		return err
-	}
-	out := s.Nodes[:0]
-	for _, n := range s.Nodes {
-		if n.Type != t {
-			out = append(out, n)
+	return Update(func(s *Store) error {
+		out := s.Nodes[:0]
+		for _, n := range s.Nodes {
+			if n.Type != t || n.External {
+				out = append(out, n)
+			}
 		}
-	}
-	s.Nodes = out
-	return saveLocked(s)
+		s.Nodes = out
+		return nil
+	})
 }
 
 // EnsureSubscribeToken returns the subscribe token, generating one if absent.
 // PR2 will consume this; PR1 just persists it on first call.
 func EnsureSubscribeToken() (string, error) {
-	mu.Lock()
-	defer mu.Unlock()
-	s, err := loadLocked()
-	if err != nil {
-		return "", err
-	}
-	if s.Subscribe.Token != "" {
-		return s.Subscribe.Token, nil
-	}
-	token, err := generateToken(16)
-	if err != nil {
-		return "", err
-	}
-	s.Subscribe.Token = token
-	if err := saveLocked(s); err != nil {
-		return "", err
-	}
-	return token, nil
+	var token string
+	err := Update(func(s *Store) error {
+		if s.Subscribe.Token == "" {
+			t, err := generateToken(16)
+			if err != nil {
+				return err
+			}
+			s.Subscribe.Token = t
+		}
+		token = s.Subscribe.Token
+		return nil
+	})
+	return token, err
 }
 
 // RotateToken regenerates the subscribe token. 旧 token 移到 PreviousToken,
 // 在 PreviousTokenGracePeriod 期内仍然接受,给客户端换 URL 的时间。
 func RotateToken() (string, error) {
-	mu.Lock()
-	defer mu.Unlock()
-	s, err := loadLocked()
-	if err != nil {
-		return "", err
-	}
 	token, err := generateToken(16)
 	if err != nil {
 		return "", err
 	}
-	if s.Subscribe.Token != "" {
-		s.Subscribe.PreviousToken = s.Subscribe.Token
-		s.Subscribe.PreviousTokenExpiresAt = time.Now().Add(PreviousTokenGracePeriod)
-	}
-	s.Subscribe.Token = token
-	if err := saveLocked(s); err != nil {
+	err = Update(func(s *Store) error {
+		if s.Subscribe.Token != "" {
+			s.Subscribe.PreviousToken = s.Subscribe.Token
+			s.Subscribe.PreviousTokenExpiresAt = time.Now().Add(PreviousTokenGracePeriod)
+		}
+		s.Subscribe.Token = token
+		return nil
+	})
+	if err != nil {
 		return "", err
 	}
 	return token, nil
@@ -270,5 +450,5 @@ func generateToken(nBytes int) (string, error) {
 
 // FilePath returns the canonical store file path. Useful for tests/diagnostics.
 func FilePath() string {
-	return filepath.Clean(StorePath)
+	return filepath.Clean(storePath)
 }
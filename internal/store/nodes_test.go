@@ -0,0 +1,39 @@
+package store
+
+import "testing"
+
+func TestUpsertKeepsUsers(t *testing.T) {
+	alice := User{Name: "alice", Password: "alice-pw"}
+	s := &Store{Nodes: []Node{{
+		ID: "hy2", Type: TypeHysteria2, Port: 443,
+		Tags: []string{"hk"}, Note: "主力", Disabled: true,
+		Users: []User{alice},
+	}}}
+
+	// 重装：install 从协议配置重建 Node，不带 Users / Tags / Note
+	s.upsert(Node{ID: "hy2", Type: TypeHysteria2, Port: 8443})
+	if len(s.Nodes) != 1 {
+		t.Fatalf("nodes = %d, want 1", len(s.Nodes))
+	}
+	n := s.Nodes[0]
+	if n.Port != 8443 {
+		t.Errorf("port = %d, want the new 8443", n.Port)
+	}
+	if len(n.Users) != 1 || n.Users[0].Name != "alice" {
+		t.Errorf("users = %+v, want alice kept", n.Users)
+	}
+	if len(n.Tags) != 1 || n.Note != "主力" || !n.Disabled {
+		t.Errorf("tags / note / disabled not carried over: %+v", n)
+	}
+
+	// 显式给了空表就是清掉
+	s.upsert(Node{ID: "hy2", Type: TypeHysteria2, Port: 8443, Users: []User{}})
+	if len(s.Nodes[0].Users) != 0 {
+		t.Errorf("explicit empty users = %+v, want cleared", s.Nodes[0].Users)
+	}
+
+	s.upsert(Node{ID: "reality", Type: TypeVLESSReality})
+	if len(s.Nodes) != 2 || s.Nodes[1].ID != "reality" {
+		t.Errorf("new node not appended: %+v", s.Nodes)
+	}
+}
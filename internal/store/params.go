@@ -0,0 +1,225 @@
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Node.Params 在磁盘上仍是自由的 JSON object (加密、快照 diff 都按 key 处理)，
+// 但生成器和内核 config 只通过下面的类型化结构读它：缺字段 / 类型不对在
+// Validate 时报出来，而不是渲染出一行 public-key= 为空的配置。
+//
+// private_key 是服务端专用字段，不参与 Validate——subscribe 守护进程读不到
+// master key，看到的是密文；需要它的地方 (install) 自己检查。
+
+// ErrInvalidParams wraps every params decode / validation failure so callers
+// can tell a broken node apart from an unsupported format.
+var ErrInvalidParams = errors.New("invalid node params")
+
+// Params is the typed view of Node.Params for one NodeType.
+type Params interface {
+	Validate() error
+}
+
+// RealityParams is Params for TypeVLESSReality.
+type RealityParams struct {
+	UUID       string `json:"uuid"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	ShortID    string `json:"short_id"`
+	ServerName string `json:"server_name"`
+	Flow       string `json:"flow"`
+}
+
+// Hysteria2Params is Params for TypeHysteria2.
+type Hysteria2Params struct {
+	Password     string `json:"password"`
+	Domain       string `json:"domain"`
+	EnableObfs   bool   `json:"enable_obfs"`
+	ObfsPassword string `json:"obfs_password"`
+
+	// SNI 只有 node import 进来的节点会设 (见 Endpoint)。
+	SNI string `json:"sni,omitempty"`
+}
+
+// AnyTLSParams is Params for TypeAnyTLS. PaddingName 存的是 PaddingSchemes
+// 的显示名 (e.g. "默认")，不是 scheme 本身。
+type AnyTLSParams struct {
+	Password    string `json:"password"`
+	Domain      string `json:"domain"`
+	PaddingName string `json:"padding_name"`
+	SNI         string `json:"sni,omitempty"` // 同 Hysteria2Params.SNI
+}
+
+// tlsEndpoint picks the dial address and TLS server name for a
+// certificate-based node. 本机节点 Server 是 IP、证书绑 Domain，客户端直接
+// 连域名；导入的节点连接地址是对方给的 Server (可能是 IP / 别的域名)，
+// SNI 单独存，两者不能混。
+func tlsEndpoint(server, domain, sni string) (host, serverName string) {
+	switch {
+	case sni != "":
+		return server, sni
+	case domain != "":
+		return domain, domain
+	}
+	return server, server
+}
+
+// Endpoint returns the address clients dial and the TLS server name.
+func (p Hysteria2Params) Endpoint(server string) (host, sni string) {
+	return tlsEndpoint(server, p.Domain, p.SNI)
+}
+
+// Endpoint returns the address clients dial and the TLS server name.
+func (p AnyTLSParams) Endpoint(server string) (host, sni string) {
+	return tlsEndpoint(server, p.Domain, p.SNI)
+}
+
+// AnyTLSRealityParams is Params for TypeAnyTLSReality.
+type AnyTLSRealityParams struct {
+	Password   string `json:"password"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	ShortID    string `json:"short_id"`
+	ServerName string `json:"server_name"`
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate checks the fields a client config needs.
+func (p RealityParams) Validate() error {
+	if !uuidRe.MatchString(p.UUID) {
+		return fmt.Errorf("uuid 缺失或格式不对: %q", p.UUID)
+	}
+	if err := validateReality(p.PublicKey, p.ShortID, p.ServerName); err != nil {
+		return err
+	}
+	if p.Flow != "" && p.Flow != "xtls-rprx-vision" {
+		return fmt.Errorf("不支持的 flow: %q", p.Flow)
+	}
+	return nil
+}
+
+// Validate checks the fields a client config needs.
+func (p Hysteria2Params) Validate() error {
+	if p.Password == "" {
+		return fmt.Errorf("password 缺失")
+	}
+	if p.Domain == "" && p.SNI == "" {
+		return fmt.Errorf("domain 缺失 (hysteria2 证书绑域名)")
+	}
+	if p.EnableObfs && p.ObfsPassword == "" {
+		return fmt.Errorf("enable_obfs=true 但 obfs_password 缺失")
+	}
+	return nil
+}
+
+// Validate checks the fields a client config needs.
+func (p AnyTLSParams) Validate() error {
+	if p.Password == "" {
+		return fmt.Errorf("password 缺失")
+	}
+	if p.Domain == "" && p.SNI == "" {
+		return fmt.Errorf("domain 缺失 (anytls 证书绑域名)")
+	}
+	return nil
+}
+
+// Validate checks the fields a client config needs.
+func (p AnyTLSRealityParams) Validate() error {
+	if p.Password == "" {
+		return fmt.Errorf("password 缺失")
+	}
+	return validateReality(p.PublicKey, p.ShortID, p.ServerName)
+}
+
+// validateReality 校验两种 Reality 节点共有的客户端字段。public key 是 x25519
+// 公钥的 base64url (无 padding) = 43 字符；short id 是 0-16 位偶数长 hex。
+func validateReality(publicKey, shortID, serverName string) error {
+	if len(publicKey) != 43 {
+		return fmt.Errorf("public_key 缺失或长度不对: %q", publicKey)
+	}
+	if len(shortID) > 16 || len(shortID)%2 != 0 {
+		return fmt.Errorf("short_id 长度不对: %q", shortID)
+	}
+	if _, err := hex.DecodeString(shortID); err != nil {
+		return fmt.Errorf("short_id 不是 hex: %q", shortID)
+	}
+	if serverName == "" {
+		return fmt.Errorf("server_name 缺失")
+	}
+	return nil
+}
+
+// DecodeParams decodes n.Params into the typed struct for n.Type and
+// validates it. The returned error wraps ErrInvalidParams.
+func (n Node) DecodeParams() (Params, error) {
+	var p Params
+	switch n.Type {
+	case TypeVLESSReality:
+		p = &RealityParams{}
+	case TypeHysteria2:
+		p = &Hysteria2Params{}
+	case TypeAnyTLS:
+		p = &AnyTLSParams{}
+	case TypeAnyTLSReality:
+		p = &AnyTLSRealityParams{}
+	default:
+		return nil, fmt.Errorf("%w: 未知节点类型 %q", ErrInvalidParams, n.Type)
+	}
+	raw, err := json.Marshal(n.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	if n.Server == "" {
+		return nil, fmt.Errorf("%w: server 缺失", ErrInvalidParams)
+	}
+	if n.Port <= 0 || n.Port > 65535 {
+		return nil, fmt.Errorf("%w: port 不在 1-65535: %d", ErrInvalidParams, n.Port)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	return p, nil
+}
+
+// Validate reports whether n can be rendered into a client config.
+func (n Node) Validate() error {
+	_, err := n.DecodeParams()
+	return err
+}
+
+// NodeError is a node that failed Validate.
+type NodeError struct {
+	ID  string
+	Err error
+}
+
+func (e *NodeError) Error() string { return e.ID + ": " + e.Err.Error() }
+func (e *NodeError) Unwrap() error { return e.Err }
+
+// checkNodes fills s.Invalid. 读盘时就把每个节点的 params 解一遍，坏节点
+// 在 doctor / admin API 里直接可见，不用等渲染订阅时被跳过才发现。
+func (s *Store) checkNodes() {
+	_, s.Invalid = SplitValid(s.Nodes)
+}
+
+// SplitValid partitions nodes into renderable ones and the ones whose params
+// are incomplete. Order is preserved.
+func SplitValid(nodes []Node) (valid []Node, invalid []*NodeError) {
+	valid = make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if err := n.Validate(); err != nil {
+			invalid = append(invalid, &NodeError{ID: n.ID, Err: err})
+			continue
+		}
+		valid = append(valid, n)
+	}
+	return valid, invalid
+}
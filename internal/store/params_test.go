@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecodeParams(t *testing.T) {
+	n := Node{
+		ID: "vless-reality-1.2.3.4", Type: TypeVLESSReality, Server: "1.2.3.4", Port: 443,
+		Params: map[string]any{
+			"uuid":        "b831381d-6324-4d53-ad4f-8cda48b30811",
+			"public_key":  "Z84J2IelR9ch3k8VtlVhhs5ycBUlXA7wHBWcBrjqnAw",
+			"short_id":    "6ba85179e30d4fc2",
+			"server_name": "www.apple.com",
+			"flow":        "xtls-rprx-vision",
+		},
+	}
+	p, err := n.DecodeParams()
+	if err != nil {
+		t.Fatalf("valid node rejected: %v", err)
+	}
+	if rp := p.(*RealityParams); rp.ShortID != "6ba85179e30d4fc2" {
+		t.Fatalf("short_id not decoded: %+v", rp)
+	}
+
+	// 缺 public_key：以前会渲染出 public-key= 空行，现在必须报错
+	delete(n.Params, "public_key")
+	if err := n.Validate(); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("missing public_key: err = %v", err)
+	}
+
+	// 类型不对 (enable_obfs 写成字符串) 也算不完整
+	h := Node{ID: "hysteria2-1.2.3.4", Type: TypeHysteria2, Server: "1.2.3.4", Port: 8443,
+		Params: map[string]any{"password": "x", "domain": "a.example.com", "enable_obfs": "true"}}
+	if err := h.Validate(); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("mistyped enable_obfs: err = %v", err)
+	}
+
+	valid, invalid := SplitValid([]Node{n, h})
+	if len(valid) != 0 || len(invalid) != 2 || invalid[0].ID != n.ID {
+		t.Fatalf("SplitValid = %v, %v", valid, invalid)
+	}
+}
+
+// 读盘时就校验：坏节点进 Invalid，其余照常读出，Invalid 不落盘。
+func TestLoadReportsInvalidNodes(t *testing.T) {
+	useTempStore(t)
+	defer func(old []string) { LegacyPaths = old }(LegacyPaths)
+	LegacyPaths = nil
+	good := Node{ID: "hy2", Type: TypeHysteria2, Server: "1.2.3.4", Port: 443,
+		Params: map[string]any{"password": "pw", "domain": "hy.example.com"}}
+	bad := Node{ID: "anytls", Type: TypeAnyTLS, Server: "1.2.3.4", Port: 8443,
+		Params: map[string]any{"domain": "at.example.com"}}
+	if err := Update(func(s *Store) error { s.Nodes = []Node{good, bad}; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, load := range map[string]func() (*Store, error){"Load": Load, "LoadOrMigrate": LoadOrMigrate} {
+		s, err := load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(s.Nodes) != 2 {
+			t.Errorf("%s: nodes = %d, want both kept", name, len(s.Nodes))
+		}
+		if len(s.Invalid) != 1 || s.Invalid[0].ID != "anytls" || !errors.Is(s.Invalid[0], ErrInvalidParams) {
+			t.Errorf("%s: Invalid = %v", name, s.Invalid)
+		}
+	}
+
+	data, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "nvalid") {
+		t.Errorf("Invalid written to disk:\n%s", data)
+	}
+}
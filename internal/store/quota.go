@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Subscription-Userinfo (Clash / Stash / Shadowrocket / Surge 显示剩余流量
+// 和到期日) 用到的配置。主 token 用 SubscribeConfig 的 QuotaBytes /
+// PlanExpiresAt；labelled token 用自己的 QuotaBytes / ExpiresAt——后者到期
+// 真的会让 URL 失效。
+
+// DefaultUpdateInterval 是没配置时下发的 Profile-Update-Interval (小时)。
+const DefaultUpdateInterval = 24
+
+// QuotaChange 是 `subscribe quota` 的一次修改，nil 字段保持不变。
+type QuotaChange struct {
+	QuotaBytes          *int64
+	ExpiresAt           *time.Time // 零值 = 清除
+	UpdateIntervalHours *int       // 只对主配置有效
+}
+
+// SetQuota applies c to the labelled token, or to the main subscription
+// config when label is empty.
+func SetQuota(label string, c QuotaChange) error {
+	if c.QuotaBytes != nil && *c.QuotaBytes < 0 {
+		return fmt.Errorf("流量额度不能为负")
+	}
+	if c.UpdateIntervalHours != nil && *c.UpdateIntervalHours < 0 {
+		return fmt.Errorf("更新间隔不能为负")
+	}
+	return Update(func(s *Store) error {
+		if label == "" {
+			if c.QuotaBytes != nil {
+				s.Subscribe.QuotaBytes = *c.QuotaBytes
+			}
+			if c.ExpiresAt != nil {
+				s.Subscribe.PlanExpiresAt = c.ExpiresAt.UTC()
+			}
+			if c.UpdateIntervalHours != nil {
+				s.Subscribe.UpdateIntervalHours = *c.UpdateIntervalHours
+			}
+			return nil
+		}
+		if c.UpdateIntervalHours != nil {
+			return fmt.Errorf("更新间隔是全局的，不能按 token 设置")
+		}
+		for i := range s.Subscribe.Tokens {
+			t := &s.Subscribe.Tokens[i]
+			if t.Label != label {
+				continue
+			}
+			if c.QuotaBytes != nil {
+				t.QuotaBytes = *c.QuotaBytes
+			}
+			if c.ExpiresAt != nil {
+				t.ExpiresAt = c.ExpiresAt.UTC()
+			}
+			return nil
+		}
+		return fmt.Errorf("token %s 不存在", label)
+	})
+}
+
+// UpdateInterval returns the Profile-Update-Interval in hours.
+func (c SubscribeConfig) UpdateInterval() int {
+	if c.UpdateIntervalHours > 0 {
+		return c.UpdateIntervalHours
+	}
+	return DefaultUpdateInterval
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nodes.json 快照：每次 Update 落盘前，把磁盘上的旧版本复制一份到
+// SnapshotDir/<id>.json，保留最近 MaxSnapshots 份。误操作 (edit 打错字段、
+// 误卸载、rotate 错 token) 用 `proxy-manager store restore <id>` 找回。
+//
+// 快照里是完整 nodes.json，含私钥 / 凭据——目录 0700、文件 0600，只给 root。
+
+const (
+	SnapshotDir  = StoreDir + "/snapshots"
+	MaxSnapshots = 20
+
+	snapshotIDLayout = "20060102-150405"
+)
+
+var snapshotDir = SnapshotDir
+
+// Snapshot describes one saved copy of nodes.json.
+type Snapshot struct {
+	ID   string
+	Time time.Time
+	Size int64
+}
+
+// snapshotLocked saves cur (the on-disk bytes about to be replaced) into
+// SnapshotDir. Best-effort: a failed snapshot must not block the write it
+// precedes.
+func snapshotLocked(cur []byte) {
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		return
+	}
+	id := time.Now().Format(snapshotIDLayout)
+	path := filepath.Join(snapshotDir, id+".json")
+	for i := 1; fileExists(path); i++ {
+		// 同一秒里多次写 (e.g. install 连着 upsert + ensure token)
+		path = filepath.Join(snapshotDir, fmt.Sprintf("%s-%d.json", id, i))
+	}
+	if err := os.WriteFile(path, cur, 0600); err != nil {
+		return
+	}
+	pruneSnapshots()
+}
+
+func pruneSnapshots() {
+	snaps, err := ListSnapshots()
+	if err != nil || len(snaps) <= MaxSnapshots {
+		return
+	}
+	for _, s := range snaps[MaxSnapshots:] {
+		_ = os.Remove(filepath.Join(snapshotDir, s.ID+".json"))
+	}
+}
+
+// ListSnapshots returns the saved snapshots, newest first.
+func ListSnapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(snapshotDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".json")
+		t, err := time.ParseInLocation(snapshotIDLayout, id[:min(len(id), len(snapshotIDLayout))], time.Local)
+		if err != nil {
+			t = info.ModTime()
+		}
+		out = append(out, Snapshot{ID: id, Time: t, Size: info.Size()})
+	}
+	// ID 是时间戳 + 同秒序号，字典序即时间序
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+// LoadSnapshot parses a snapshot, applying migrations like Load does.
+func LoadSnapshot(id string) (*Store, error) {
+	if id == "" || strings.ContainsAny(id, "/\\") || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("无效快照 ID: %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(snapshotDir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("快照不存在: %s (proxy-manager store snapshots 查看列表)", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeStore(data, loadKeyring())
+}
+
+// RestoreSnapshot replaces nodes.json with the snapshot. The current state is
+// itself snapshotted first (Update does that), so a restore can be undone.
+// Returns the store as it was before the restore.
+func RestoreSnapshot(id string) (before *Store, err error) {
+	snap, err := LoadSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	err = Update(func(s *Store) error {
+		cp := *s
+		before = &cp
+		*s = *snap
+		return nil
+	})
+	return before, err
+}
+
+// StoreDiff lists what differs between two stores, by node ID and field
+// name only — values are never printed since most of them are secrets.
+type StoreDiff struct {
+	Added     []string            // node IDs only in the newer store
+	Removed   []string            // node IDs only in the older store
+	Changed   map[string][]string // node ID → changed field names
+	Subscribe []string            // changed subscribe fields
+}
+
+// Empty reports whether the two stores are equivalent.
+func (d StoreDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Subscribe) == 0
+}
+
+// Diff compares from → to.
+func Diff(from, to *Store) StoreDiff {
+	d := StoreDiff{Changed: map[string][]string{}}
+	old := map[string]Node{}
+	for _, n := range from.Nodes {
+		old[n.ID] = n
+	}
+	seen := map[string]bool{}
+	for _, n := range to.Nodes {
+		seen[n.ID] = true
+		o, ok := old[n.ID]
+		if !ok {
+			d.Added = append(d.Added, n.ID)
+			continue
+		}
+		if fields := diffNode(o, n); len(fields) > 0 {
+			d.Changed[n.ID] = fields
+		}
+	}
+	for _, n := range from.Nodes {
+		if !seen[n.ID] {
+			d.Removed = append(d.Removed, n.ID)
+		}
+	}
+	d.Subscribe = refineSlice(diffStructFields(from.Subscribe, to.Subscribe), "tokens",
+		diffByName(from.Subscribe.Tokens, to.Subscribe.Tokens, func(t AccessToken) string { return t.Label }))
+	d.Subscribe = refineSlice(d.Subscribe, "upstreams",
+		diffByName(from.Subscribe.Upstreams, to.Subscribe.Upstreams, func(u Upstream) string { return u.Label }))
+	return d
+}
+
+func diffNode(a, b Node) []string {
+	var out []string
+	fields := refineSlice(diffStructFields(a, b), "users",
+		diffByName(a.Users, b.Users, func(u User) string { return u.Name }))
+	for _, f := range fields {
+		if f != "params" {
+			out = append(out, f)
+		}
+	}
+	keys := map[string]bool{}
+	for k := range a.Params {
+		keys[k] = true
+	}
+	for k := range b.Params {
+		keys[k] = true
+	}
+	for k := range keys {
+		if !reflect.DeepEqual(a.Params[k], b.Params[k]) {
+			out = append(out, "params."+k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// refineSlice 把 field 换成 names 里具体条目的 "field.name"——"users" 变了
+// 不如 "users.alice" 有用 (审计里要能看出是谁的凭据被换了)。
+func refineSlice(fields []string, field string, names []string) []string {
+	out := make([]string, 0, len(fields)+len(names))
+	for _, f := range fields {
+		if f != field {
+			out = append(out, f)
+			continue
+		}
+		for _, n := range names {
+			out = append(out, field+"."+n)
+		}
+	}
+	return out
+}
+
+// diffByName returns the names of entries added, removed or changed between
+// a and b, sorted.
+func diffByName[T any](a, b []T, name func(T) string) []string {
+	old := map[string]T{}
+	for _, x := range a {
+		old[name(x)] = x
+	}
+	var out []string
+	for _, x := range b {
+		o, ok := old[name(x)]
+		if !ok || !reflect.DeepEqual(o, x) {
+			out = append(out, name(x))
+		}
+		delete(old, name(x))
+	}
+	for n := range old {
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// diffStructFields compares exported fields and reports their json names.
+func diffStructFields(a, b any) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTempStore points nodes.json, its lock and the snapshots at a temp dir
+// and swallows audit entries.
+func useTempStore(t *testing.T) string {
+	dir := t.TempDir()
+	oldPath, oldDir, oldLock, oldSnap := storePath, storeDir, lockPath, snapshotDir
+	storePath = filepath.Join(dir, "nodes.json")
+	storeDir = dir
+	lockPath = filepath.Join(dir, "nodes.json.lock")
+	snapshotDir = filepath.Join(dir, "snapshots")
+	t.Cleanup(func() { storePath, storeDir, lockPath, snapshotDir = oldPath, oldDir, oldLock, oldSnap })
+	captureAudit(t)
+	return dir
+}
+
+func TestSnapshotRotation(t *testing.T) {
+	useTempStore(t)
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < MaxSnapshots+5; i++ {
+		id := old.Add(time.Duration(i) * time.Second).Format(snapshotIDLayout)
+		if err := os.WriteFile(filepath.Join(snapshotDir, id+".json"), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshotLocked([]byte(`{"version":2}`))
+
+	snaps, err := ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != MaxSnapshots {
+		t.Fatalf("kept %d snapshots, want %d", len(snaps), MaxSnapshots)
+	}
+	if snaps[0].Size != int64(len(`{"version":2}`)) {
+		t.Errorf("newest snapshot = %+v, want the one just written", snaps[0])
+	}
+	// 最老的 6 份 (5 份超额 + 新写的 1 份) 被删
+	oldestKept := old.Add(6 * time.Second).Format(snapshotIDLayout)
+	if last := snaps[len(snaps)-1]; last.ID != oldestKept {
+		t.Errorf("oldest kept = %s, want %s", last.ID, oldestKept)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	useTempStore(t)
+	a := Node{ID: "a", Type: TypeHysteria2, Params: map[string]any{"password": "pw-a"}}
+	b := Node{ID: "b", Type: TypeAnyTLS, Params: map[string]any{"password": "pw-b"}}
+	if err := Update(func(s *Store) error { s.Nodes = []Node{a}; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(func(s *Store) error { s.Nodes = []Node{b}; s.Subscribe.Token = "tok"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	snaps, _ := ListSnapshots()
+	if len(snaps) != 1 {
+		t.Fatalf("snapshots = %+v, want the pre-b state only", snaps)
+	}
+
+	before, err := RestoreSnapshot(snaps[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before.Nodes) != 1 || before.Nodes[0].ID != "b" || before.Subscribe.Token != "tok" {
+		t.Errorf("before = %+v, want the b state", before)
+	}
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Nodes) != 1 || s.Nodes[0].ID != "a" || s.Subscribe.Token != "" {
+		t.Errorf("restored = %+v, want the a state", s)
+	}
+	// restore 之前的状态也进了快照，可以撤销
+	if snaps, _ := ListSnapshots(); len(snaps) != 2 {
+		t.Errorf("snapshots after restore = %+v, want 2", snaps)
+	}
+
+	for _, id := range []string{"", "../nodes", ".hidden", "a/b", "missing"} {
+		if _, err := RestoreSnapshot(id); err == nil {
+			t.Errorf("RestoreSnapshot(%q) should fail", id)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	hy2 := Node{ID: "hy2", Type: TypeHysteria2, Port: 443, Params: map[string]any{"password": "pw"},
+		Users: []User{{Name: "alice", Password: "alice-pw"}}}
+	reality := Node{ID: "reality", Type: TypeVLESSReality, Params: map[string]any{"uuid": "u"}}
+	with := func(n Node, fn func(*Node)) Node {
+		n.Params = map[string]any{}
+		for k, v := range hy2.Params {
+			n.Params[k] = v
+		}
+		n.Users = append([]User(nil), n.Users...)
+		fn(&n)
+		return n
+	}
+
+	for _, c := range []struct {
+		name     string
+		from, to Store
+		want     StoreDiff
+	}{
+		{"same", Store{Nodes: []Node{hy2}}, Store{Nodes: []Node{hy2}}, StoreDiff{}},
+		{"added", Store{Nodes: []Node{hy2}}, Store{Nodes: []Node{hy2, reality}},
+			StoreDiff{Added: []string{"reality"}}},
+		{"removed", Store{Nodes: []Node{hy2, reality}}, Store{Nodes: []Node{reality}},
+			StoreDiff{Removed: []string{"hy2"}}},
+		{"port and param", Store{Nodes: []Node{hy2}},
+			Store{Nodes: []Node{with(hy2, func(n *Node) { n.Port = 8443; n.Params["password"] = "new" })}},
+			StoreDiff{Changed: map[string][]string{"hy2": {"params.password", "port"}}}},
+		{"param added", Store{Nodes: []Node{hy2}},
+			Store{Nodes: []Node{with(hy2, func(n *Node) { n.Params["enable_obfs"] = true })}},
+			StoreDiff{Changed: map[string][]string{"hy2": {"params.enable_obfs"}}}},
+		{"user", Store{Nodes: []Node{hy2}},
+			Store{Nodes: []Node{with(hy2, func(n *Node) { n.Users[0].Disabled = true; n.Users = append(n.Users, User{Name: "bob"}) })}},
+			StoreDiff{Changed: map[string][]string{"hy2": {"users.alice", "users.bob"}}}},
+		{"tags and note", Store{Nodes: []Node{hy2}},
+			Store{Nodes: []Node{with(hy2, func(n *Node) { n.Tags = []string{"hk"}; n.Note = "x"; n.Disabled = true })}},
+			StoreDiff{Changed: map[string][]string{"hy2": {"disabled", "note", "tags"}}}},
+		{"subscribe", Store{Subscribe: SubscribeConfig{Token: "a", Port: 443}},
+			Store{Subscribe: SubscribeConfig{Token: "b", Port: 443, QuotaBytes: 1}},
+			StoreDiff{Subscribe: []string{"token", "quota_bytes"}}},
+		{"labelled tokens", Store{Subscribe: SubscribeConfig{Tokens: []AccessToken{{Label: "bob"}, {Label: "carol"}}}},
+			Store{Subscribe: SubscribeConfig{Tokens: []AccessToken{{Label: "bob", Token: "new"}, {Label: "dave"}}}},
+			StoreDiff{Subscribe: []string{"tokens.bob", "tokens.carol", "tokens.dave"}}},
+		{"upstreams", Store{}, Store{Subscribe: SubscribeConfig{Upstreams: []Upstream{{Label: "peer"}}}},
+			StoreDiff{Subscribe: []string{"upstreams.peer"}}},
+	} {
+		// %v 不区分 nil 和空 slice，map 按 key 排序
+		got := Diff(&c.from, &c.to)
+		if fmt.Sprintf("%v", got) != fmt.Sprintf("%v", c.want) {
+			t.Errorf("%s: Diff = %+v, want %+v", c.name, got, c.want)
+		}
+		if got.Empty() != (c.name == "same") {
+			t.Errorf("%s: Empty = %v", c.name, got.Empty())
+		}
+	}
+}
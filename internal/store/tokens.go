@@ -0,0 +1,152 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// AccessToken 是发给某个人的订阅 token，只暴露 Nodes / Tags 圈定的节点。
+// 跟主 token 互相独立：revoke / rotate 一个不影响别人的 URL。
+//
+// Nodes 列节点 ID；Tags 匹配节点类型 (如 "hysteria2") 或节点的 Tags (如
+// "region=hk")。两者都为空表示全部节点。User 非空时按该用户的凭据渲染 (见 Node.ForUser)，没有这个
+// 用户或用户被禁用的节点直接不出现在订阅里。
+type AccessToken struct {
+	Label     string    `json:"label"`
+	Token     string    `json:"token"`
+	Nodes     []string  `json:"nodes,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	User      string    `json:"user,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	RotatedAt time.Time `json:"rotated_at,omitempty"`
+
+	// QuotaBytes 是 Subscription-Userinfo 里的 total，只展示不限速。
+	QuotaBytes int64 `json:"quota_bytes,omitempty"`
+}
+
+// Expired reports whether the token has a deadline and it has passed.
+func (t AccessToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// Allows reports whether n is inside the token's scope.
+func (t AccessToken) Allows(n Node) bool {
+	if len(t.Nodes) == 0 && len(t.Tags) == 0 {
+		return true
+	}
+	for _, id := range t.Nodes {
+		if id == n.ID {
+			return true
+		}
+	}
+	return n.HasAnyTag(t.Tags)
+}
+
+// Scope filters nodes down to what the token may see, substituting the
+// token's user credential when one is bound. 没绑用户的拿节点的默认凭据：
+// Users 表 (别人的 uuid / password) 和运维备注都不给。Tags 留着，后面
+// ?tag= 还要筛。
+func (t AccessToken) Scope(nodes []Node) []Node {
+	out := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if !t.Allows(n) {
+			continue
+		}
+		n.Note = ""
+		if t.User == "" {
+			n.Users = nil
+			out = append(out, n)
+			continue
+		}
+		for _, u := range n.Users {
+			if u.Name == t.User && !u.Disabled {
+				out = append(out, n.ForUser(u))
+				break
+			}
+		}
+	}
+	return out
+}
+
+// FindAccessToken returns the token with the given label.
+func (c SubscribeConfig) FindAccessToken(label string) (AccessToken, bool) {
+	for _, t := range c.Tokens {
+		if t.Label == label {
+			return t, true
+		}
+	}
+	return AccessToken{}, false
+}
+
+// AddAccessToken creates a new labelled token. Token is generated; the caller
+// fills Label / Nodes / Tags / User / ExpiresAt.
+func AddAccessToken(t AccessToken) (AccessToken, error) {
+	if t.Label == "" {
+		return AccessToken{}, fmt.Errorf("label 不能为空")
+	}
+	token, err := generateToken(16)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	t.Token = token
+	t.CreatedAt = time.Now().UTC()
+	err = Update(func(s *Store) error {
+		if _, ok := s.Subscribe.FindAccessToken(t.Label); ok {
+			return fmt.Errorf("token %s 已存在 (要换新 URL 用 rotate)", t.Label)
+		}
+		s.Subscribe.Tokens = append(s.Subscribe.Tokens, t)
+		return nil
+	})
+	if err != nil {
+		return AccessToken{}, err
+	}
+	return t, nil
+}
+
+// RevokeAccessToken deletes the labelled token; its URL stops working on the
+// next request (the daemon reloads the store per request).
+func RevokeAccessToken(label string) error {
+	return Update(func(s *Store) error {
+		out := s.Subscribe.Tokens[:0]
+		found := false
+		for _, t := range s.Subscribe.Tokens {
+			if t.Label == label {
+				found = true
+				continue
+			}
+			out = append(out, t)
+		}
+		if !found {
+			return fmt.Errorf("token %s 不存在", label)
+		}
+		s.Subscribe.Tokens = out
+		return nil
+	})
+}
+
+// RotateAccessToken issues a new secret for the labelled token, keeping its
+// scope. 跟主 token 不同，这里没有宽限期：单人 token 被 rotate 通常就是
+// 泄露了，旧 URL 应立即失效。
+func RotateAccessToken(label string) (AccessToken, error) {
+	token, err := generateToken(16)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	var out AccessToken
+	err = Update(func(s *Store) error {
+		for i := range s.Subscribe.Tokens {
+			if s.Subscribe.Tokens[i].Label == label {
+				s.Subscribe.Tokens[i].Token = token
+				s.Subscribe.Tokens[i].RotatedAt = time.Now().UTC()
+				out = s.Subscribe.Tokens[i]
+				return nil
+			}
+		}
+		return fmt.Errorf("token %s 不存在", label)
+	})
+	if err != nil {
+		return AccessToken{}, err
+	}
+	return out, nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenScope(t *testing.T) {
+	nodes := []Node{
+		{ID: "reality", Type: TypeVLESSReality, Params: map[string]any{"uuid": "default-uuid"},
+			Users: []User{{Name: "alice", UUID: "alice-uuid"}}},
+		{ID: "hy2", Type: TypeHysteria2, Tags: []string{"region=hk"}, Note: "ops note",
+			Params: map[string]any{"password": "default-pw"},
+			Users:  []User{{Name: "alice", Password: "alice-pw"}, {Name: "bob", Password: "bob-pw", Disabled: true}}},
+		{ID: "anytls", Type: TypeAnyTLS, Params: map[string]any{"password": "default-pw"}},
+	}
+	ids := func(ns []Node) string {
+		var out []string
+		for _, n := range ns {
+			out = append(out, n.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	for _, c := range []struct {
+		name string
+		tok  AccessToken
+		want string
+	}{
+		{"unscoped", AccessToken{}, "reality,hy2,anytls"},
+		{"node id", AccessToken{Nodes: []string{"anytls"}}, "anytls"},
+		{"type as tag", AccessToken{Tags: []string{"vless-reality"}}, "reality"},
+		{"node tag", AccessToken{Tags: []string{"region=hk"}}, "hy2"},
+		{"ids or tags", AccessToken{Nodes: []string{"anytls"}, Tags: []string{"region=hk"}}, "hy2,anytls"},
+		{"user", AccessToken{User: "alice"}, "reality-alice,hy2-alice"},
+		{"disabled user", AccessToken{User: "bob"}, ""},
+	} {
+		if got := ids(c.tok.Scope(nodes)); got != c.want {
+			t.Errorf("%s: Scope = %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	// 没绑用户：拿默认凭据，Users 表和备注不给，Tags 留给 ?tag=
+	hy2 := AccessToken{}.Scope(nodes)[1]
+	if hy2.Users != nil || hy2.Note != "" || len(hy2.Tags) != 1 || hy2.Params["password"] != "default-pw" {
+		t.Errorf("unbound scope = %+v", hy2)
+	}
+	alice := AccessToken{User: "alice"}.Scope(nodes)[1]
+	if alice.Params["password"] != "alice-pw" || alice.Users != nil {
+		t.Errorf("alice scope = %+v", alice)
+	}
+	if nodes[1].Note == "" || len(nodes[1].Users) != 2 {
+		t.Error("Scope modified the caller's nodes")
+	}
+}
+
+func TestAccessTokenExpired(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	if (AccessToken{}).Expired(now) {
+		t.Error("token without deadline expired")
+	}
+	if (AccessToken{ExpiresAt: now.Add(time.Second)}).Expired(now) {
+		t.Error("token expired early")
+	}
+	if !(AccessToken{ExpiresAt: now}).Expired(now) {
+		t.Error("token still valid at its deadline")
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Upstream 是另一台 proxy-manager 的 json 订阅 URL。subscribe 守护进程拉取
+// 它们的节点合并进本机订阅，客户端加一个 URL 就能拿到整个机群。
+//
+// URL 里带着对方的 token，跟本机 token 一样按凭据对待 (加密、不下发)。
+type Upstream struct {
+	Label     string    `json:"label"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateUpstreamURL accepts https://host[:port]/s/json/<token>. 只收 json
+// 格式：合并是按 Node 结构做的，其他格式是渲染结果，没法还原。
+func ValidateUpstreamURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("URL 解析失败: %w", err)
+	}
+	// URL 带着对方的 token，响应里是全部节点凭据，明文 http 一个都不能走
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("upstream 必须是 https:// URL: %s", raw)
+	}
+	if u.Host == "" || !strings.HasPrefix(u.Path, "/s/json/") || len(u.Path) == len("/s/json/") {
+		return nil, fmt.Errorf("upstream 必须是对方的 json 订阅 URL (https://host:port/s/json/<token>)")
+	}
+	return u, nil
+}
+
+// AddUpstream records a peer. Label defaults to the URL's host.
+func AddUpstream(label, rawURL string) (Upstream, error) {
+	u, err := ValidateUpstreamURL(rawURL)
+	if err != nil {
+		return Upstream{}, err
+	}
+	if label == "" {
+		label = u.Hostname()
+	}
+	up := Upstream{Label: label, URL: rawURL, CreatedAt: time.Now().UTC()}
+	err = Update(func(s *Store) error {
+		for _, x := range s.Subscribe.Upstreams {
+			if x.Label == label {
+				return fmt.Errorf("upstream %s 已存在", label)
+			}
+			if x.URL == rawURL {
+				return fmt.Errorf("该 URL 已作为 upstream %s 存在", x.Label)
+			}
+		}
+		s.Subscribe.Upstreams = append(s.Subscribe.Upstreams, up)
+		return nil
+	})
+	if err != nil {
+		return Upstream{}, err
+	}
+	return up, nil
+}
+
+// RemoveUpstream deletes the peer with the given label.
+func RemoveUpstream(label string) error {
+	return Update(func(s *Store) error {
+		out := s.Subscribe.Upstreams[:0]
+		found := false
+		for _, x := range s.Subscribe.Upstreams {
+			if x.Label == label {
+				found = true
+				continue
+			}
+			out = append(out, x)
+		}
+		if !found {
+			return fmt.Errorf("upstream %s 不存在", label)
+		}
+		s.Subscribe.Upstreams = out
+		return nil
+	})
+}
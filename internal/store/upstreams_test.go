@@ -0,0 +1,19 @@
+package store
+
+import "testing"
+
+func TestValidateUpstreamURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://b.example.com:8443/s/json/abc123": true,
+		"http://b.example.com:8443/s/json/abc123":  false, // token 和节点凭据会明文传
+		"HTTP://b.example.com/s/json/abc123":       false,
+		"ftp://b.example.com/s/json/abc123":        false,
+		"https://b.example.com/s/surge/abc123":     false,
+		"https://b.example.com/s/json/":            false,
+		"https:///s/json/abc123":                   false,
+	} {
+		if _, err := ValidateUpstreamURL(raw); (err == nil) != ok {
+			t.Errorf("ValidateUpstreamURL(%q) err = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
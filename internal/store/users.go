@@ -0,0 +1,153 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultUserName 是节点自带凭据 (Params 里的 uuid / password) 在内核 config
+// 里的名字。它不在 Node.Users 里出现，不能被 user remove 删掉——要换默认
+// 凭据走 edit (等同 rotate)。
+const DefaultUserName = "default"
+
+// User is one extra credential on a multi-user node. Exactly one of UUID
+// (vless-reality) or Password (hysteria2 / anytls / anytls-reality) is set,
+// matching the node's protocol.
+type User struct {
+	Name      string    `json:"name"`
+	UUID      string    `json:"uuid,omitempty"`
+	Password  string    `json:"password,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Disabled  bool      `json:"disabled,omitempty"`
+}
+
+// Credential returns whichever secret the user carries.
+func (u User) Credential() string {
+	if u.UUID != "" {
+		return u.UUID
+	}
+	return u.Password
+}
+
+// ActiveUsers returns the non-disabled extra users, in insertion order.
+func (n Node) ActiveUsers() []User {
+	out := make([]User, 0, len(n.Users))
+	for _, u := range n.Users {
+		if !u.Disabled {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// ForUser returns a copy of the node whose credential is u's, so the format
+// generators render a client config for that user instead of the default.
+// ID / Name 带上用户名后缀，客户端同时导入多份时不撞 key。
+func (n Node) ForUser(u User) Node {
+	out := n
+	out.ID = n.ID + "-" + u.Name
+	out.Name = n.Name + "-" + u.Name
+	out.Users = nil
+	out.Params = make(map[string]any, len(n.Params))
+	for k, v := range n.Params {
+		out.Params[k] = v
+	}
+	if n.Type == TypeVLESSReality {
+		out.Params["uuid"] = u.UUID
+	} else {
+		out.Params["password"] = u.Password
+	}
+	return out
+}
+
+// ValidateUserName rejects names that would collide with the default user or
+// break the kernel config / CLI round trip.
+func ValidateUserName(name string) error {
+	if name == "" {
+		return fmt.Errorf("用户名不能为空")
+	}
+	if name == DefaultUserName {
+		return fmt.Errorf("%q 是节点默认凭据的保留名", DefaultUserName)
+	}
+	if len(name) > 32 || strings.ContainsAny(name, " \t/\\\"'@") {
+		return fmt.Errorf("用户名只能是 ≤32 位、不含空白 / 引号 / @ / 斜杠的字符串")
+	}
+	return nil
+}
+
+// AddUser appends u to the node identified by nodeID. Duplicate names are
+// rejected so remove/disable stay unambiguous.
+func AddUser(nodeID string, u User) (Node, error) {
+	if err := ValidateUserName(u.Name); err != nil {
+		return Node{}, err
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+	return updateNode(nodeID, func(n *Node) error {
+		for _, existing := range n.Users {
+			if existing.Name == u.Name {
+				return fmt.Errorf("用户 %s 已存在", u.Name)
+			}
+		}
+		n.Users = append(n.Users, u)
+		return nil
+	})
+}
+
+// RemoveUser drops the named user. Missing users are an error (unlike
+// RemoveByID) because the CLI wants to tell the operator about typos.
+func RemoveUser(nodeID, name string) (Node, error) {
+	return updateNode(nodeID, func(n *Node) error {
+		out := n.Users[:0]
+		found := false
+		for _, u := range n.Users {
+			if u.Name == name {
+				found = true
+				continue
+			}
+			out = append(out, u)
+		}
+		if !found {
+			return fmt.Errorf("用户 %s 不存在", name)
+		}
+		n.Users = out
+		return nil
+	})
+}
+
+// SetUserDisabled flips the disabled flag without discarding the credential,
+// so a user can be re-enabled later with the same client config.
+func SetUserDisabled(nodeID, name string, disabled bool) (Node, error) {
+	return updateNode(nodeID, func(n *Node) error {
+		for i := range n.Users {
+			if n.Users[i].Name == name {
+				n.Users[i].Disabled = disabled
+				return nil
+			}
+		}
+		return fmt.Errorf("用户 %s 不存在", name)
+	})
+}
+
+// updateNode is the read-modify-write shared by the per-node mutators.
+func updateNode(nodeID string, fn func(*Node) error) (Node, error) {
+	var out Node
+	err := Update(func(s *Store) error {
+		for i := range s.Nodes {
+			if s.Nodes[i].ID == nodeID {
+				if err := fn(&s.Nodes[i]); err != nil {
+					return err
+				}
+				out = s.Nodes[i]
+				return nil
+			}
+		}
+		return fmt.Errorf("未找到节点: %s", nodeID)
+	})
+	if err != nil {
+		return Node{}, err
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package subscribe
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// /api/v1 是给外部面板 / 自动化用的管理接口 (store.SubscribeConfig 里配了
+// AdminToken 或 AdminClientCA 才开，否则跟不存在的路径一样 404)。
+//
+// 认证二选一：Authorization: Bearer <admin token>，或者 AdminClientCA 签发
+// 的客户端证书 (Serve 里 VerifyClientCertIfGiven，握手时已经验过链)。失败
+// 跟订阅 token 错一样计入 ban 计数。
+//
+//	GET  /api/v1/nodes            节点列表 (ClientCopy：不含 private_key / Users)
+//	GET  /api/v1/nodes/{id}
+//	其余                           转给 root helper (internal/admin)
+//
+// daemon 不是 root，改 systemd / 写快照的操作都转给 AdminSocketPath 上的
+// helper，AdminCallerHeader 带上认证出来的调用方，helper 记审计。
+
+const (
+	// AdminSocketPath 是 root helper (`proxy-manager admin-helper`) 的 Unix
+	// socket。目录和 socket 属组 ServiceUser，只有 root 和 daemon 进得去。
+	AdminSocketPath = "/run/proxy-manager/admin.sock"
+
+	// AdminCallerHeader 是 daemon 转给 helper 时附上的调用方："token" 或
+	// "mtls:<证书 CN>"。客户端自己带的同名 header 会被覆盖。
+	AdminCallerHeader = "X-Proxy-Manager-Caller"
+)
+
+// adminCaller authenticates an /api/v1 request. 证书优先：配了 CA 又带了
+// 验过的证书就不看 token。
+func adminCaller(r *http.Request, cfg store.SubscribeConfig) (string, bool) {
+	if cfg.AdminClientCA != "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return "mtls:" + r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && validToken(cfg.AdminToken, token) {
+		return "token", true
+	}
+	return "", false
+}
+
+// adminProxy forwards to the root helper over AdminSocketPath.
+var adminProxy = &httputil.ReverseProxy{
+	Rewrite: func(pr *httputil.ProxyRequest) {
+		pr.Out.URL.Scheme = "http"
+		pr.Out.URL.Host = "admin-helper"
+		pr.Out.Host = "admin-helper"
+		pr.Out.Header.Del("Authorization")
+	},
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", AdminSocketPath)
+		},
+		ResponseHeaderTimeout: 2 * time.Minute, // kernels/check 要挨个问 GitHub
+	},
+	ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+		writeAPIError(w, http.StatusServiceUnavailable, "admin helper 未运行 (systemctl status proxy-manager-admin)")
+	},
+}
+
+func serveAPI(w http.ResponseWriter, r *http.Request) {
+	s, _, err := stores.load()
+	if err != nil {
+		metrics.incStoreError()
+		writeAPIError(w, http.StatusInternalServerError, "store unavailable")
+		return
+	}
+	if s.Subscribe.AdminToken == "" && s.Subscribe.AdminClientCA == "" {
+		http.NotFound(w, r)
+		return
+	}
+	caller, ok := adminCaller(r, s.Subscribe)
+	if !ok {
+		rl.recordUnauth(clientIP(r), time.Now())
+		metrics.incTokenRejection()
+		http.NotFound(w, r)
+		return
+	}
+	rl.recordAuth(clientIP(r))
+
+	if r.Method == http.MethodGet {
+		if r.URL.Path == "/api/v1/nodes" {
+			serveAPINodes(w, s.Nodes, "")
+			return
+		}
+		if id, found := strings.CutPrefix(r.URL.Path, "/api/v1/nodes/"); found && id != "" && !strings.Contains(id, "/") {
+			serveAPINodes(w, s.Nodes, id)
+			return
+		}
+	}
+	r.Header.Set(AdminCallerHeader, caller)
+	adminProxy.ServeHTTP(w, r)
+}
+
+// serveAPINodes answers GET /api/v1/nodes[/{id}] from the cached store.
+func serveAPINodes(w http.ResponseWriter, nodes []store.Node, id string) {
+	if id == "" {
+		out := make([]store.Node, 0, len(nodes))
+		for _, n := range nodes {
+			out = append(out, n.ClientCopy())
+		}
+		writeAPIJSON(w, http.StatusOK, out)
+		return
+	}
+	for _, n := range nodes {
+		if n.ID == id {
+			writeAPIJSON(w, http.StatusOK, n.ClientCopy())
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "节点 "+id+" 不存在")
+}
+
+func writeAPIJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, code int, msg string) {
+	writeAPIJSON(w, code, map[string]string{"error": msg})
+}
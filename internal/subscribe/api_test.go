@@ -0,0 +1,45 @@
+package subscribe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestAdminCaller(t *testing.T) {
+	cfg := store.SubscribeConfig{AdminToken: "admintoken"}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "panel"}}
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	cases := []struct {
+		name   string
+		cfg    store.SubscribeConfig
+		auth   string
+		tls    *tls.ConnectionState
+		caller string
+		ok     bool
+	}{
+		{"token", cfg, "Bearer admintoken", nil, "token", true},
+		{"wrong token", cfg, "Bearer nope", nil, "", false},
+		{"no scheme", cfg, "admintoken", nil, "", false},
+		{"empty token never matches", store.SubscribeConfig{AdminClientCA: "/ca.pem"}, "Bearer ", nil, "", false},
+		{"client cert", store.SubscribeConfig{AdminClientCA: "/ca.pem"}, "", verified, "mtls:panel", true},
+		// 没配 CA 时 daemon 不会要证书；就算有也不认
+		{"cert without CA", cfg, "", verified, "", false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/api/v1/nodes", nil)
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		r.TLS = c.tls
+		caller, ok := adminCaller(r, c.cfg)
+		if caller != c.caller || ok != c.ok {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", c.name, caller, ok, c.caller, c.ok)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package subscribe
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Mamaaz/proxy-manager/internal/format"
+)
+
+// AutoFormat 是 /s/auto/{token}：按 User-Agent 选格式，一个 URL 发给所有
+// 客户端。?format=<name> 强制指定；认不出的 UA 给 base64 分享链接列表
+// (uri)，几乎所有客户端都能导入。
+const AutoFormat = "auto"
+
+// fallbackFormat 是 UA 认不出来时的格式。
+const fallbackFormat = "uri"
+
+// uaFormats 按顺序匹配 (小写子串)，先写更具体的：XSurge 的 UA 里带
+// "surge"，Hiddify 的 UA 里同时带 "clashmeta" 和 "sing-box"。
+var uaFormats = []struct {
+	substr string
+	format string
+}{
+	{"xsurge", "json"}, // 自家 Mac 客户端读节点列表
+	{"surfboard", "surfboard"},
+	{"surge", "surge-profile"},
+	{"stash", "stash"},
+	{"shadowrocket", "shadowrocket"},
+	{"quantumult", "qx"},
+	{"loon", "loon"},
+	// Hiddify 自带的 sing-box 版本跟官方差得远，完整配置容易被拒，给链接
+	// 列表让它自己拼。
+	{"hiddify", fallbackFormat},
+	{"v2rayn", fallbackFormat}, // v2rayN / v2rayNG
+	{"nekobox", fallbackFormat},
+	{"nekoray", fallbackFormat},
+	{"mihomo", "mihomo-profile"},
+	{"clash", "mihomo-profile"}, // clash-verge / ClashX Meta / ClashMetaForAndroid
+	{"sing-box", "singbox-profile"},
+	{"sfa/", "singbox-profile"}, // sing-box 官方 Android / iOS / macOS app
+	{"sfi/", "singbox-profile"},
+	{"sfm/", "singbox-profile"},
+}
+
+// formatForUserAgent picks the format name for a client's User-Agent.
+func formatForUserAgent(ua string) string {
+	ua = strings.ToLower(ua)
+	for _, m := range uaFormats {
+		if strings.Contains(ua, m.substr) {
+			return m.format
+		}
+	}
+	return fallbackFormat
+}
+
+var singboxUAVersion = regexp.MustCompile(`sing-box[ /]v?1\.(\d+)`)
+
+// resolveAuto turns /s/auto into a concrete formatter. For sing-box clients
+// the UA also tells us the core version, which singbox-profile needs to pick
+// its layout, unless the URL already sets ?version=.
+func resolveAuto(r *http.Request, params url.Values) (format.Formatter, bool) {
+	name := params.Get("format")
+	if name == "" {
+		name = formatForUserAgent(r.UserAgent())
+	}
+	f, ok := format.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	if f.Name() == "singbox-profile" && params.Get("version") == "" {
+		if m := singboxUAVersion.FindStringSubmatch(strings.ToLower(r.UserAgent())); m != nil {
+			if minor, _ := strconv.Atoi(m[1]); minor >= 10 {
+				params.Set("version", "1."+m[1])
+			}
+		}
+	}
+	return f, true
+}
@@ -0,0 +1,52 @@
+package subscribe
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormatForUserAgent(t *testing.T) {
+	for ua, want := range map[string]string{
+		"Surge iOS/3200":                   "surge-profile",
+		"Surge Mac/2745":                   "surge-profile",
+		"XSurge/1.4 (macOS 15.1)":          "json",
+		"Stash/2.7.1 Clash/1.9.0":          "stash",
+		"Shadowrocket/2070 CFNetwork/1568": "shadowrocket",
+		"Quantumult%20X/1.5.2":             "qx",
+		"Loon/3.2.9":                       "loon",
+		"Surfboard/2.24.4":                 "surfboard",
+		"clash-verge/v1.7.7":               "mihomo-profile",
+		"mihomo/1.18.10":                   "mihomo-profile",
+		"ClashMetaForAndroid/2.11.5.Meta":  "mihomo-profile",
+		"SFA/1.11.4 (sing-box 1.11.4)":     "singbox-profile",
+		"v2rayNG/1.9.16":                   "uri",
+		"HiddifyNext/2.5.7 (android) like ClashMeta v2ray sing-box": "uri",
+		"curl/8.5.0": "uri",
+		"":           "uri",
+	} {
+		if got := formatForUserAgent(ua); got != want {
+			t.Errorf("%q → %s, want %s", ua, got, want)
+		}
+	}
+}
+
+func TestResolveAuto(t *testing.T) {
+	r := httptest.NewRequest("GET", "/s/auto/tok", nil)
+	r.Header.Set("User-Agent", "SFI/1.10.7 (sing-box 1.10.7)")
+	params := r.URL.Query()
+	f, ok := resolveAuto(r, params)
+	if !ok || f.Name() != "singbox-profile" || params.Get("version") != "1.10" {
+		t.Errorf("sing-box UA: %v %v version=%q", f, ok, params.Get("version"))
+	}
+
+	r = httptest.NewRequest("GET", "/s/auto/tok?format=clash-profile", nil)
+	r.Header.Set("User-Agent", "Surge iOS/3200")
+	if f, ok := resolveAuto(r, r.URL.Query()); !ok || f.Name() != "mihomo-profile" {
+		t.Errorf("?format= override: %v %v", f, ok)
+	}
+
+	r = httptest.NewRequest("GET", "/s/auto/tok?format=nope", nil)
+	if _, ok := resolveAuto(r, r.URL.Query()); ok {
+		t.Error("unknown ?format= accepted")
+	}
+}
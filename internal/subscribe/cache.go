@@ -0,0 +1,208 @@
+package subscribe
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// 订阅客户端大多每隔几分钟到几小时拉一次，内容几乎总是一样的。这里两层缓存：
+//
+//   - storeCache：nodes.json 的 mtime + size 不变就不重新读 / 解密；
+//   - renderCache：同一 (格式, URL) 在 store 没变时直接复用渲染
+//     结果，带 ETag / Last-Modified，客户端带 If-None-Match 回来就给 304，
+//     Accept-Encoding 里有 gzip 就给压缩后的 body。
+//
+// 合并了 upstream 的结果最多缓存 UpstreamCacheTTL：上游节点只在渲染时刷新，
+// 过期后下一次请求重新拉。
+
+// storeStamp identifies one version of nodes.json on disk.
+type storeStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statStore() (storeStamp, error) {
+	fi, err := os.Stat(store.StorePath)
+	if os.IsNotExist(err) {
+		return storeStamp{}, nil // store.Load 也把不存在当成空 store
+	}
+	if err != nil {
+		return storeStamp{}, err
+	}
+	return storeStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+type storeCache struct {
+	mu    sync.Mutex
+	ok    bool
+	stamp storeStamp
+	s     *store.Store
+}
+
+// load returns the store, re-reading nodes.json only when its stamp changed.
+// The result is a shallow copy: callers may reassign its fields (Nodes,
+// Subscribe) but must not modify the nodes in place.
+func (c *storeCache) load() (*store.Store, storeStamp, error) {
+	st, err := statStore()
+	if err != nil {
+		return nil, storeStamp{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.ok || c.stamp != st {
+		// stat 和 Load 之间被改写时，缓存的内容比 stamp 新；下一次 stat
+		// 看到新 stamp 会再读一次，不会卡在旧内容上。
+		s, err := store.Load()
+		if err != nil {
+			return nil, storeStamp{}, err
+		}
+		c.s, c.stamp, c.ok = s, st, true
+	}
+	cp := *c.s
+	return &cp, c.stamp, nil
+}
+
+// maxRenderEntries caps the render cache. Keys include the raw query, so an
+// authenticated client could otherwise grow it without bound; on overflow
+// the whole map is dropped and refilled by whatever is still being fetched.
+const maxRenderEntries = 256
+
+type renderKey struct {
+	format string // 解析后的格式名 (auto 按 UA 解析出来的)
+	url    string // requestURL：token、query、Host 都会进输出
+	params string // 解析后的 query (auto 可能补了 version)
+}
+
+// rendered is one cached subscription response.
+type rendered struct {
+	body        []byte
+	contentType string
+	etag        string
+	modified    time.Time
+	skipped     string // SkippedNodesHeader
+
+	stamp   storeStamp
+	expires time.Time // 零值 = 只随 store 失效
+
+	gzipOnce sync.Once
+	gzipped  []byte
+}
+
+func newRendered(body []byte, contentType string, now time.Time) *rendered {
+	sum := sha256.Sum256(body)
+	return &rendered{
+		body:        body,
+		contentType: contentType,
+		// 弱 ETag：同一内容的 gzip / 原文两种表示共用一个
+		etag:     `W/"` + hex.EncodeToString(sum[:12]) + `"`,
+		modified: now.UTC().Truncate(time.Second),
+	}
+}
+
+// gzipBody compresses the body once, on first use.
+func (e *rendered) gzipBody() []byte {
+	e.gzipOnce.Do(func() {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(e.body)
+		_ = zw.Close()
+		e.gzipped = buf.Bytes()
+	})
+	return e.gzipped
+}
+
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[renderKey]*rendered
+}
+
+func (c *renderCache) get(k renderKey, stamp storeStamp, now time.Time) *rendered {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[k]
+	if e == nil || e.stamp != stamp || (!e.expires.IsZero() && now.After(e.expires)) {
+		return nil
+	}
+	return e
+}
+
+// put stores e under k. Re-rendering identical bytes (e.g. an unrelated
+// store write) keeps the old Last-Modified so If-Modified-Since still hits.
+func (c *renderCache) put(k renderKey, e *rendered) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.entries[k]; old != nil && old.etag == e.etag {
+		e.modified = old.modified
+	}
+	if c.entries == nil || len(c.entries) >= maxRenderEntries {
+		c.entries = make(map[renderKey]*rendered)
+	}
+	c.entries[k] = e
+}
+
+// writeRendered sends e, answering conditional requests with 304 and
+// compressing when the client accepts gzip.
+func writeRendered(w http.ResponseWriter, r *http.Request, e *rendered) {
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	h.Set("ETag", e.etag)
+	h.Set("Last-Modified", e.modified.Format(http.TimeFormat))
+	h.Set("Cache-Control", "private, no-cache") // 可以存，但每次都要回来验证
+	if e.skipped != "" {
+		h.Set(SkippedNodesHeader, e.skipped)
+	}
+	if notModified(r, e) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", e.contentType)
+	body := e.body
+	if acceptsGzip(r) {
+		body = e.gzipBody()
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	_, _ = w.Write(body)
+}
+
+// notModified implements RFC 9110 §13.2.2: If-None-Match wins, and
+// If-Modified-Since is only looked at when it is absent.
+func notModified(r *http.Request, e *rendered) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(e.etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !e.modified.After(t)
+	}
+	return false
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip with a non-zero q.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			continue
+		}
+		q = strings.ReplaceAll(q, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
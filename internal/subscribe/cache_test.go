@@ -0,0 +1,92 @@
+package subscribe
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteRenderedConditional(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := newRendered([]byte("vless://example\n"), "text/plain; charset=utf-8", now)
+
+	get := func(hdr map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/s/uri/tok", nil)
+		for k, v := range hdr {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		writeRendered(w, r, e)
+		return w
+	}
+
+	w := get(nil)
+	if w.Code != http.StatusOK || w.Body.String() != "vless://example\n" {
+		t.Fatalf("plain: %d %q", w.Code, w.Body)
+	}
+	etag := w.Header().Get("ETag")
+
+	cases := []struct {
+		hdr  map[string]string
+		want int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", ` + etag[2:]}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": now.Format(http.TimeFormat)}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": now.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+		// If-None-Match 不匹配时不再看 If-Modified-Since
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": now.Format(http.TimeFormat)}, http.StatusOK},
+	}
+	for _, c := range cases {
+		if got := get(c.hdr).Code; got != c.want {
+			t.Errorf("%v: status %d, want %d", c.hdr, got, c.want)
+		}
+	}
+
+	w = get(map[string]string{"Accept-Encoding": "br, gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("not compressed: %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "vless://example\n" {
+		t.Errorf("gunzip = %q", b)
+	}
+	if w = get(map[string]string{"Accept-Encoding": "gzip;q=0"}); w.Header().Get("Content-Encoding") != "" {
+		t.Error("gzip;q=0 still compressed")
+	}
+}
+
+func TestRenderCacheInvalidation(t *testing.T) {
+	now := time.Now()
+	st := storeStamp{modTime: now, size: 10}
+	k := renderKey{format: "uri", url: "https://h/s/uri/tok"}
+	var c renderCache
+
+	e := newRendered([]byte("a"), "text/plain", now)
+	e.stamp = st
+	c.put(k, e)
+	if c.get(k, st, now) != e {
+		t.Fatal("miss on same stamp")
+	}
+	if c.get(k, storeStamp{modTime: now, size: 11}, now) != nil {
+		t.Error("hit after store changed")
+	}
+
+	// 同样的内容重新渲染：Last-Modified 不动
+	again := newRendered([]byte("a"), "text/plain", now.Add(time.Hour))
+	again.stamp, again.expires = st, now.Add(UpstreamCacheTTL)
+	c.put(k, again)
+	if !again.modified.Equal(e.modified) {
+		t.Errorf("modified moved: %v -> %v", e.modified, again.modified)
+	}
+	if c.get(k, st, now.Add(UpstreamCacheTTL+time.Second)) != nil {
+		t.Error("hit after upstream TTL")
+	}
+}
@@ -0,0 +1,281 @@
+package subscribe
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/format"
+)
+
+// /metrics 输出 Prometheus text format (0.0.4)。只有几个计数器和一个直方图，
+// 不值得为此拉 client_golang 进来，手写。
+//
+// 两种访问方式 (store.SubscribeConfig)：
+//   - MetricsToken：HTTPS 端口上的 /metrics，Authorization: Bearer <token>；
+//     token 错了跟订阅 token 错一样计入 ban 计数。
+//   - MetricsListen：只监听 loopback 的明文端口，不要 token，给本机
+//     node_exporter / 反向代理 / SSH 隧道用。
+
+// renderBuckets 是渲染耗时直方图的桶 (秒)。
+var renderBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1}
+
+type histogram struct {
+	counts []uint64 // 按 renderBuckets，非累积
+	sum    float64
+	count  uint64
+}
+
+type requestKey struct {
+	format string
+	code   int
+}
+
+type metricsState struct {
+	mu              sync.Mutex
+	requests        map[requestKey]uint64
+	tokenRejections uint64
+	rateLimited     uint64
+	storeErrors     uint64
+	render          map[string]*histogram
+}
+
+var metrics = &metricsState{
+	requests: make(map[requestKey]uint64),
+	render:   make(map[string]*histogram),
+}
+
+// certDomain 是 Serve 申请证书的域名，cert expiry 指标按它读 autocert 缓存。
+var certDomain string
+
+func (m *metricsState) countRequest(formatName string, code int) {
+	m.mu.Lock()
+	m.requests[requestKey{formatName, code}]++
+	m.mu.Unlock()
+}
+
+func (m *metricsState) incTokenRejection() {
+	m.mu.Lock()
+	m.tokenRejections++
+	m.mu.Unlock()
+}
+
+func (m *metricsState) incRateLimited() {
+	m.mu.Lock()
+	m.rateLimited++
+	m.mu.Unlock()
+}
+
+func (m *metricsState) incStoreError() {
+	m.mu.Lock()
+	m.storeErrors++
+	m.mu.Unlock()
+}
+
+func (m *metricsState) observeRender(formatName string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.render[formatName]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(renderBuckets))}
+		m.render[formatName] = h
+	}
+	sec := d.Seconds()
+	for i, le := range renderBuckets {
+		if sec <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += sec
+	h.count++
+}
+
+// metricsFormat maps a request path to the format label. 只认注册过的格式，
+// 乱写的路径归到 "unknown"，免得扫描器把 label 基数撑爆。
+func metricsFormat(path string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(path, "/s/"), "/")
+	if name == AutoFormat {
+		return name
+	}
+	if f, ok := format.Lookup(name); ok {
+		return f.Name()
+	}
+	return "unknown"
+}
+
+// statusRecorder 记下 handler 写出的状态码。
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// metricsMiddleware counts /s/ requests by format and status. 包在 rate limit
+// 外面，429 也算进去。
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/s/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		metrics.countRequest(metricsFormat(r.URL.Path), rec.code)
+	})
+}
+
+// serveMetricsAuth is /metrics on the public HTTPS port.
+func serveMetricsAuth(w http.ResponseWriter, r *http.Request) {
+	s, _, err := stores.load()
+	if err != nil {
+		metrics.incStoreError()
+		http.Error(w, "store unavailable", http.StatusInternalServerError)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !validToken(s.Subscribe.MetricsToken, token) {
+		now := time.Now()
+		rl.recordUnauth(clientIP(r), now)
+		metrics.incTokenRejection()
+		http.NotFound(w, r)
+		return
+	}
+	rl.recordAuth(clientIP(r))
+	serveMetrics(w, r)
+}
+
+// serveMetrics writes the exposition without auth (loopback listener).
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.write(w, time.Now())
+}
+
+// metricsHandler serves only /metrics, for the loopback listener.
+func metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", serveMetrics)
+	return mux
+}
+
+func (m *metricsState) write(w io.Writer, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP proxy_manager_subscribe_requests_total Subscription requests by format and HTTP status.")
+	fmt.Fprintln(w, "# TYPE proxy_manager_subscribe_requests_total counter")
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].format != keys[j].format {
+			return keys[i].format < keys[j].format
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "proxy_manager_subscribe_requests_total{format=%q,code=\"%d\"} %d\n", k.format, k.code, m.requests[k])
+	}
+
+	counter(w, "proxy_manager_subscribe_token_rejections_total", "Requests with an unknown or expired token.", m.tokenRejections)
+	counter(w, "proxy_manager_subscribe_rate_limited_total", "Requests denied by the per-IP rate limiter or an active ban.", m.rateLimited)
+	gauge(w, "proxy_manager_subscribe_banned_ips", "Source IPs currently banned for repeated bad tokens.", float64(rl.banned(now)))
+	counter(w, "proxy_manager_subscribe_store_load_errors_total", "Failures reading nodes.json.", m.storeErrors)
+
+	fmt.Fprintln(w, "# HELP proxy_manager_subscribe_render_duration_seconds Time to render a subscription (cache misses only).")
+	fmt.Fprintln(w, "# TYPE proxy_manager_subscribe_render_duration_seconds histogram")
+	names := make([]string, 0, len(m.render))
+	for name := range m.render {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := m.render[name]
+		var cum uint64
+		for i, le := range renderBuckets {
+			cum += h.counts[i]
+			fmt.Fprintf(w, "proxy_manager_subscribe_render_duration_seconds_bucket{format=%q,le=%q} %d\n",
+				name, strconv.FormatFloat(le, 'g', -1, 64), cum)
+		}
+		fmt.Fprintf(w, "proxy_manager_subscribe_render_duration_seconds_bucket{format=%q,le=\"+Inf\"} %d\n", name, h.count)
+		fmt.Fprintf(w, "proxy_manager_subscribe_render_duration_seconds_sum{format=%q} %g\n", name, h.sum)
+		fmt.Fprintf(w, "proxy_manager_subscribe_render_duration_seconds_count{format=%q} %d\n", name, h.count)
+	}
+
+	if certDomain != "" {
+		if notAfter, err := certExpiry(CertCacheDir, certDomain); err == nil {
+			fmt.Fprintln(w, "# HELP proxy_manager_subscribe_cert_expiry_seconds Seconds until the subscription certificate expires.")
+			fmt.Fprintln(w, "# TYPE proxy_manager_subscribe_cert_expiry_seconds gauge")
+			fmt.Fprintf(w, "proxy_manager_subscribe_cert_expiry_seconds{domain=%q} %g\n", certDomain, notAfter.Sub(now).Seconds())
+		}
+	}
+}
+
+func counter(w io.Writer, name, help string, v uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+func gauge(w io.Writer, name, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, v)
+}
+
+// CertExpiry returns when the autocert certificate for domain expires.
+func CertExpiry(domain string) (time.Time, error) {
+	return certExpiry(CertCacheDir, domain)
+}
+
+// certExpiry reads the leaf NotAfter from the autocert cache. autocert 存的
+// 文件名是域名 (ECDSA) 或 域名+rsa，内容是私钥 PEM + 证书链 PEM。
+func certExpiry(dir, domain string) (time.Time, error) {
+	var lastErr error
+	for _, name := range []string{domain, domain + "+rsa"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return cert.NotAfter, nil // 第一张是 leaf
+		}
+		lastErr = fmt.Errorf("%s: no certificate", name)
+	}
+	return time.Time{}, lastErr
+}
@@ -0,0 +1,77 @@
+package subscribe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	m := &metricsState{requests: map[requestKey]uint64{}, render: map[string]*histogram{}}
+	m.countRequest("surge", 200)
+	m.countRequest("surge", 200)
+	m.countRequest(metricsFormat("/s/nope/abc"), 404)
+	m.incTokenRejection()
+	m.observeRender("surge", 3*time.Millisecond)
+	m.observeRender("surge", 2*time.Second)
+
+	var b strings.Builder
+	m.write(&b, time.Now())
+	out := b.String()
+	for _, want := range []string{
+		`proxy_manager_subscribe_requests_total{format="surge",code="200"} 2`,
+		`proxy_manager_subscribe_requests_total{format="unknown",code="404"} 1`,
+		"proxy_manager_subscribe_token_rejections_total 1",
+		`proxy_manager_subscribe_render_duration_seconds_bucket{format="surge",le="0.0025"} 0`,
+		`proxy_manager_subscribe_render_duration_seconds_bucket{format="surge",le="0.005"} 1`,
+		`proxy_manager_subscribe_render_duration_seconds_bucket{format="surge",le="1"} 1`,
+		`proxy_manager_subscribe_render_duration_seconds_bucket{format="surge",le="+Inf"} 2`,
+		`proxy_manager_subscribe_render_duration_seconds_count{format="surge"} 2`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestCertExpiry(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	notAfter := time.Now().Add(60 * 24 * time.Hour).Truncate(time.Second)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sub.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	if err := os.WriteFile(filepath.Join(dir, "sub.example.com"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := certExpiry(dir, "sub.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(notAfter.UTC()) {
+		t.Errorf("NotAfter = %v, want %v", got, notAfter)
+	}
+	if _, err := certExpiry(dir, "other.example.com"); err == nil {
+		t.Error("missing cert: no error")
+	}
+}
@@ -108,6 +108,19 @@ func (l *limiter) recordAuth(ip string) {
 	}
 }
 
+// banned 返回当前在 ban 期内的 IP 数 (/metrics 用)。
+func (l *limiter) banned(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, st := range l.ips {
+		if now.Before(st.bannedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
 func (l *limiter) evictLocked(now time.Time) {
 	for ip, st := range l.ips {
 		if now.Sub(st.lastSeen) > rlIdleEvict && now.After(st.bannedUntil) {
@@ -148,6 +161,7 @@ func rateLimitMiddleware(l *limiter, next http.Handler) http.Handler {
 			if retrySec < 1 {
 				retrySec = 1
 			}
+			metrics.incRateLimited()
 			w.Header().Set("Retry-After", itoa(retrySec))
 			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 			return
@@ -14,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Mamaaz/proxy-manager/internal/store"
 	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
@@ -81,7 +82,42 @@ func Serve(opts ServeOptions) error {
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	errCh := make(chan error, 2)
+	certDomain = opts.Domain
+	servers := []*http.Server{httpServer, httpsServer}
+	errCh := make(chan error, 3)
+
+	s, err := store.Load()
+	if err != nil {
+		s = &store.Store{} // handler 每次请求自己再读，这里只影响下面两个可选项
+	}
+	// /api/v1 的 mTLS：只在握手时要证书但不强制，订阅客户端照常连。CA 换了
+	// 要重启 daemon (subscribe admin enable 会做)。
+	if s.Subscribe.AdminClientCA != "" {
+		pool, err := store.LoadClientCAs(s.Subscribe.AdminClientCA)
+		if err != nil {
+			return fmt.Errorf("admin client CA: %w", err)
+		}
+		httpsServer.TLSConfig.ClientCAs = pool
+		httpsServer.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	// /metrics 的 loopback 明文端口 (store 里配了才开)。
+	if s.Subscribe.MetricsListen != "" {
+		if err := store.ValidateMetricsListen(s.Subscribe.MetricsListen); err != nil {
+			return err
+		}
+		metricsServer := &http.Server{
+			Addr:              s.Subscribe.MetricsListen,
+			Handler:           metricsHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, metricsServer)
+		go func() {
+			log.Printf("subscribe: metrics listening on %s", metricsServer.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("metrics listener: %w", err)
+			}
+		}()
+	}
 	go func() {
 		log.Printf("subscribe: ACME http-01 listening on %s", httpAddr)
 		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -101,11 +137,11 @@ func Serve(opts ServeOptions) error {
 
 	select {
 	case err := <-errCh:
-		shutdown(httpServer, httpsServer)
+		shutdown(servers...)
 		return err
 	case sig := <-stop:
 		log.Printf("subscribe: received %s, shutting down", sig)
-		shutdown(httpServer, httpsServer)
+		shutdown(servers...)
 		return nil
 	}
 }
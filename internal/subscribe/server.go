@@ -1,13 +1,20 @@
 // Package subscribe implements the HTTPS subscription endpoint that serves
-// installed nodes in Surge / Clash Meta / sing-box / xray / raw JSON formats.
+// installed nodes in every format registered with internal/format (Surge,
+// Clash / Mihomo, sing-box, xray, the mobile clients, raw JSON).
 //
 // Endpoints
 //
-//	GET /s/{format}/{token}
+//	GET /s/{format}/{token}[?tag=region=hk]
+//	GET /s/auto/{token}[?format=NAME] (按 User-Agent 选格式，见 auto.go)
+//	GET /s/json/{token}?local=1 (只给本机节点，供其他服务器作为 upstream 拉取)
 //	GET /healthz                (200 OK, no auth — for monitoring)
+//	GET /metrics                (Prometheus, Bearer metrics token — 见 metrics.go)
+//	    /api/v1/...             (管理接口，admin token 或 mTLS — 见 api.go)
 //
-// Authentication is a single token in the URL path, compared in constant time.
-// Rotating the token via `proxy-manager subscribe rotate-token` issues a new
+// Authentication is a token in the URL path, compared in constant time. The
+// main token sees every node; labelled tokens (store.AccessToken, managed by
+// `proxy-manager subscribe token ...`) only see the nodes they are scoped to.
+// Rotating the main token via `proxy-manager subscribe rotate-token` issues a new
 // token; the old token stays valid for store.PreviousTokenGracePeriod (7 days)
 // so clients have a window to update without going dark mid-rotation.
 //
@@ -16,12 +23,12 @@
 package subscribe
 
 import (
+	"bytes"
 	"crypto/subtle"
-	"encoding/json"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 	"time"
@@ -34,9 +41,15 @@ import (
 // 共享 ban 状态。Handler 创建,所以每次 daemon 重启清零 (单 binary 自用够)。
 var rl = newLimiter()
 
+// stores / renders 是 cache.go 里的 store 和渲染结果缓存，同样进程级。
+var (
+	stores  storeCache
+	renders renderCache
+)
+
 // Handler returns the http.Handler that serves all subscription routes.
-// It re-loads the store on every request so new installs/uninstalls take
-// effect without restarting the daemon.
+// It re-reads the store whenever nodes.json changes on disk (cache.go), so
+// new installs/uninstalls take effect without restarting the daemon.
 func Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -44,12 +57,18 @@ func Handler() http.Handler {
 		_, _ = io.WriteString(w, "ok\n")
 	})
 	mux.HandleFunc("/s/", serveSubscribe)
+	mux.HandleFunc("/metrics", serveMetricsAuth)
+	mux.HandleFunc("/api/v1/", serveAPI)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 	})
-	return logMiddleware(rateLimitMiddleware(rl, mux))
+	return logMiddleware(metricsMiddleware(rateLimitMiddleware(rl, mux)))
 }
 
+// SkippedNodesHeader lists (comma-separated IDs) nodes left out of the
+// response because their params failed store.Node.Validate.
+const SkippedNodesHeader = "X-Proxy-Manager-Skipped"
+
 func serveSubscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -62,37 +81,157 @@ func serveSubscribe(w http.ResponseWriter, r *http.Request) {
 	}
 	formatName, token := parts[0], parts[1]
 
-	s, err := store.Load()
+	s, stamp, err := stores.load()
 	if err != nil {
+		metrics.incStoreError()
 		http.Error(w, "store unavailable", http.StatusInternalServerError)
 		return
 	}
 	ip := clientIP(r)
-	if !acceptToken(s.Subscribe, token, time.Now()) {
-		rl.recordUnauth(ip, time.Now())
+	now := time.Now()
+	scope, ok := lookupToken(s.Subscribe, token, now)
+	if !ok {
+		rl.recordUnauth(ip, now)
+		metrics.incTokenRejection()
 		http.NotFound(w, r) // 404 not 401 to avoid revealing token presence
 		return
 	}
 	rl.recordAuth(ip)
 
+	params := r.URL.Query()
+	var f format.Formatter
+	auto := formatName == AutoFormat
+	if auto {
+		// 同一个 URL 按 UA 出不同内容，缓存不能混用
+		w.Header().Set("Vary", "User-Agent")
+		f, ok = resolveAuto(r, params)
+		formatName = params.Get("format")
+	} else {
+		f, ok = format.Lookup(formatName)
+	}
+	if !ok {
+		http.Error(w, format.UnknownFormatError(formatName).Error(), http.StatusBadRequest)
+		return
+	}
+	setProfileHeaders(w.Header(), r, s, scope, now)
+
+	// 记录每个 token 最后一次同步 (synclog.go)。?local=1 是别的服务器当
+	// upstream 来拉，不是客户端，记下来只会盖掉真正有用的那条。
+	if params.Get("local") != "1" {
+		rec := SyncRecord{At: now.UTC(), Format: f.Name(), IP: ip, UserAgent: truncateUA(r.UserAgent())}
+		if auto {
+			rec.Format = AutoFormat + ":" + rec.Format
+		}
+		if err := syncs.record(syncKey(s.Subscribe, token, scope), rec); err != nil {
+			log.Printf("record sync: %v", err)
+		}
+	}
+
+	// 路径里已经有 token；auto 解析出的格式 / version 不在 URL 里，单独进 key。
+	key := renderKey{format: f.Name(), url: requestURL(r), params: params.Encode()}
+	if e := renders.get(key, stamp, now); e != nil {
+		writeRendered(w, r, e)
+		return
+	}
+
+	// 合并上游服务器的节点 (upstream.go)。?local=1 是上游之间互相拉取时用
+	// 的，只给本机节点，防止互为上游时循环展开。
+	var expires time.Time
+	if len(s.Subscribe.Upstreams) > 0 && params.Get("local") != "1" {
+		s.Nodes = mergeNodes(s.Nodes, upstreams.nodes(s.Subscribe.Upstreams, now))
+		expires = now.Add(UpstreamCacheTTL)
+	}
+
+	// 订阅输出 (尤其 json) 不能带出 token 表 / 上游 URL (带着对方 token)；
+	// 分发出去的 token 连主 token / 域名配置都不该看到。
+	s.Subscribe.Tokens = nil
+	s.Subscribe.Upstreams = nil
+	s.Subscribe.MetricsToken = ""
+	s.Subscribe.AdminToken = ""
+	if scope != nil {
+		s.Nodes = scope.Scope(s.Nodes)
+		s.Subscribe = store.SubscribeConfig{}
+	}
+
+	// 下线维护 (node disable) 的节点不下发；?tag= 进一步按 tag 筛 (可重复或
+	// 逗号分隔，命中任意一个即可)。
+	s.Nodes = store.Published(s.Nodes, queryTags(r))
+
+	// params 不完整的节点不下发——客户端导入一行 public-key= 为空的配置只会
+	// 静默连不上。记日志 + 响应头，doctor 里也能看到同样的报错。
+	valid, invalid := store.SplitValid(s.Nodes)
+	var skipped []string
+	for _, e := range invalid {
+		log.Printf("skip node %v", e)
+		skipped = append(skipped, e.ID)
+	}
+	s.Nodes = valid
+
 	// Stable order so identical store state always renders identical output.
 	sort.SliceStable(s.Nodes, func(i, j int) bool { return s.Nodes[i].ID < s.Nodes[j].ID })
 
-	if err := writeFormat(w, formatName, s); err != nil {
+	// 先渲染到 buffer：参数错误 (e.g. ?version=0.9) 要能回 400，而不是半截 200。
+	var buf bytes.Buffer
+	start := time.Now()
+	if err := f.Render(&buf, s.Nodes, format.Options{URL: requestURL(r), Params: params}); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metrics.observeRender(f.Name(), time.Since(start))
+	e := newRendered(buf.Bytes(), f.ContentType(), now)
+	e.skipped = strings.Join(skipped, ",")
+	e.stamp, e.expires = stamp, expires
+	renders.put(key, e)
+	writeRendered(w, r, e)
+}
+
+// requestURL is the URL the client fetched, token and ?tag= included — what
+// a self-updating profile (surge-profile) should point back at.
+func requestURL(r *http.Request) string {
+	u := url.URL{Scheme: "https", Host: r.Host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	if r.TLS == nil {
+		u.Scheme = "http"
+	}
+	return u.String()
+}
+
+// queryTags collects ?tag=a&tag=b,c into [a b c].
+func queryTags(r *http.Request) []string {
+	var out []string
+	for _, v := range r.URL.Query()["tag"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				out = append(out, t)
+			}
+		}
 	}
+	return out
 }
 
 // acceptToken 接受当前 token,或者 rotate 之后还在宽限期里的旧 token。
 // rotate 后给客户端 7 天时间重新拿 URL,避免一刀切断订阅。
 func acceptToken(cfg store.SubscribeConfig, supplied string, now time.Time) bool {
+	_, ok := lookupToken(cfg, supplied, now)
+	return ok
+}
+
+// lookupToken 在 acceptToken 的基础上返回 token 的可见范围：主 token (含
+// 宽限期内的旧 token) 返回 nil scope = 全部节点；命中未过期的 labelled
+// token 返回该 token。
+func lookupToken(cfg store.SubscribeConfig, supplied string, now time.Time) (*store.AccessToken, bool) {
 	if validToken(cfg.Token, supplied) {
-		return true
+		return nil, true
+	}
+	if cfg.PreviousToken != "" && now.Before(cfg.PreviousTokenExpiresAt) && validToken(cfg.PreviousToken, supplied) {
+		return nil, true
 	}
-	if cfg.PreviousToken != "" && now.Before(cfg.PreviousTokenExpiresAt) {
-		return validToken(cfg.PreviousToken, supplied)
+	for i := range cfg.Tokens {
+		t := cfg.Tokens[i]
+		if validToken(t.Token, supplied) && !t.Expired(now) {
+			return &t, true
+		}
 	}
-	return false
+	return nil, false
 }
 
 // validToken returns true iff configured and supplied tokens match in
@@ -105,81 +244,6 @@ func validToken(configured, supplied string) bool {
 	return subtle.ConstantTimeCompare([]byte(configured), []byte(supplied)) == 1
 }
 
-func writeFormat(w http.ResponseWriter, name string, s *store.Store) error {
-	switch name {
-	case "json":
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		return enc.Encode(s)
-	case "surge":
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		for _, n := range s.Nodes {
-			line, err := format.ToSurge(&n)
-			if err != nil {
-				continue
-			}
-			fmt.Fprintln(w, line)
-		}
-		return nil
-	case "clash", "mihomo":
-		// Mihomo (formerly Clash.Meta) is the active fork; it accepts standard
-		// Clash YAML/JSON. Same handler — alias for discoverability.
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		proxies := make([]map[string]any, 0, len(s.Nodes))
-		for _, n := range s.Nodes {
-			entry, err := format.ToClash(&n)
-			if err != nil {
-				continue
-			}
-			proxies = append(proxies, entry)
-		}
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		return enc.Encode(map[string]any{"proxies": proxies})
-	case "qx", "quantumultx":
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		for _, n := range s.Nodes {
-			line, err := format.ToQX(&n)
-			if err != nil {
-				continue
-			}
-			fmt.Fprintln(w, line)
-		}
-		return nil
-	case "singbox", "sing-box":
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		outbounds := make([]map[string]any, 0, len(s.Nodes)*2)
-		for _, n := range s.Nodes {
-			entries, err := format.ToSingbox(&n)
-			if err != nil {
-				continue
-			}
-			outbounds = append(outbounds, entries...)
-		}
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		return enc.Encode(map[string]any{"outbounds": outbounds})
-	case "xray":
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		outbounds := make([]map[string]any, 0, len(s.Nodes))
-		for _, n := range s.Nodes {
-			if !format.NeedsBridge(&n) {
-				continue
-			}
-			entries, err := format.ToXray(&n)
-			if err != nil {
-				continue
-			}
-			outbounds = append(outbounds, entries...)
-		}
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		return enc.Encode(map[string]any{"outbounds": outbounds})
-	}
-	return fmt.Errorf("unknown format: %s (supported: json, surge, clash, mihomo, singbox, xray, qx)", name)
-}
-
 func logMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Mask the token in logs: /s/surge/abc123 -> /s/surge/***
@@ -0,0 +1,100 @@
+package subscribe
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/format"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestLookupScopedToken(t *testing.T) {
+	now := time.Now()
+	cfg := store.SubscribeConfig{
+		Token: "maintoken",
+		Tokens: []store.AccessToken{
+			{Label: "friend", Token: "friendtoken", Tags: []string{"hysteria2"}},
+			{Label: "gone", Token: "expiredtoken", ExpiresAt: now.Add(-time.Minute)},
+		},
+	}
+	if scope, ok := lookupToken(cfg, "maintoken", now); !ok || scope != nil {
+		t.Fatal("main token should be accepted with full scope")
+	}
+	scope, ok := lookupToken(cfg, "friendtoken", now)
+	if !ok || scope == nil || scope.Label != "friend" {
+		t.Fatal("labelled token should be accepted with its own scope")
+	}
+	nodes := []store.Node{
+		{ID: "vless-reality-1.2.3.4", Type: store.TypeVLESSReality},
+		{ID: "hysteria2-1.2.3.4", Type: store.TypeHysteria2},
+	}
+	if got := scope.Scope(nodes); len(got) != 1 || got[0].Type != store.TypeHysteria2 {
+		t.Fatalf("scoped token should only see hysteria2, got %+v", got)
+	}
+	if acceptToken(cfg, "expiredtoken", now) {
+		t.Fatal("expired labelled token must NOT be accepted")
+	}
+}
+
+// 没绑用户的 labelled token 拿到的 json 里不能有别人的凭据 (Users) 和备注。
+func TestScopedJSONHidesUsers(t *testing.T) {
+	tok := store.AccessToken{Label: "friend", Token: "friendtoken", Tags: []string{"hysteria2"}}
+	nodes := []store.Node{{
+		ID: "hysteria2-1.2.3.4", Type: store.TypeHysteria2, Server: "1.2.3.4", Port: 443,
+		Params: map[string]any{"password": "default-pw", "domain": "hy.example.com"},
+		Users:  []store.User{{Name: "alice", Password: "alice-pw"}},
+		Tags:   []string{"region=hk"},
+		Note:   "ops note",
+	}}
+	f, ok := format.Lookup("json")
+	if !ok {
+		t.Fatal("json format not registered")
+	}
+	var b bytes.Buffer
+	if err := f.Render(&b, tok.Scope(nodes), format.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, leak := range []string{`"users"`, "alice-pw", `"note"`} {
+		if strings.Contains(out, leak) {
+			t.Errorf("scoped json leaks %s:\n%s", leak, out)
+		}
+	}
+	if !strings.Contains(out, "default-pw") {
+		t.Errorf("scoped json should keep the node's default credential:\n%s", out)
+	}
+	if !strings.Contains(out, "region=hk") {
+		t.Errorf("scoped json should keep tags for upstream ?tag= matching:\n%s", out)
+	}
+}
+
+// ?tag= 可重复也可逗号分隔，命中任意一个即可。
+func TestQueryTags(t *testing.T) {
+	nodes := []store.Node{
+		{ID: "reality", Type: store.TypeVLESSReality, Tags: []string{"region=hk"}},
+		{ID: "hy2", Type: store.TypeHysteria2, Tags: []string{"region=jp"}},
+		{ID: "anytls", Type: store.TypeAnyTLS, Tags: []string{"region=us"}, Disabled: true},
+	}
+	for query, want := range map[string]string{
+		"":                               "reality,hy2",
+		"?tag=region=hk":                 "reality",
+		"?tag=region%3Djp":               "hy2",
+		"?tag=region=hk,region=jp":       "reality,hy2",
+		"?tag=region=hk&tag=hysteria2":   "reality,hy2",
+		"?tag=region=us":                 "",
+		"?tag=+region=jp+,&tag=":         "hy2",
+		"?tag=region=hk,anytls&tag=nope": "reality",
+	} {
+		r := httptest.NewRequest("GET", "/s/json/tok"+query, nil)
+		var ids []string
+		for _, n := range store.Published(nodes, queryTags(r)) {
+			ids = append(ids, n.ID)
+		}
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("%q: got %q, want %q", query, got, want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Mamaaz/proxy-manager/internal/format"
 	"github.com/Mamaaz/proxy-manager/internal/store"
 	"github.com/Mamaaz/proxy-manager/internal/utils"
 )
@@ -43,20 +44,17 @@ func Install(domain string, port int, email string) (urls map[string]string, err
 		return nil, fmt.Errorf("端口 80 不可用 (ACME http-01 需要): %w", err)
 	}
 
-	s, err := store.LoadOrMigrate()
-	if err != nil {
+	if _, err := store.LoadOrMigrate(); err != nil {
 		return nil, err
 	}
-	if s.Subscribe.Token == "" {
-		token, err := store.EnsureSubscribeToken()
-		if err != nil {
-			return nil, err
-		}
-		s.Subscribe.Token = token
+	if _, err := store.EnsureSubscribeToken(); err != nil {
+		return nil, err
 	}
-	s.Subscribe.Domain = domain
-	s.Subscribe.Port = port
-	if err := store.Save(s); err != nil {
+	if err := store.Update(func(s *store.Store) error {
+		s.Subscribe.Domain = domain
+		s.Subscribe.Port = port
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 
@@ -121,6 +119,10 @@ WantedBy=multi-user.target
 		return nil, fmt.Errorf("服务启动验证失败 (检查 journalctl -u %s)", ServiceName)
 	}
 
+	s, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
 	return Urls(s), nil
 }
 
@@ -138,12 +140,30 @@ func prepareRuntimeDirs() error {
 			return fmt.Errorf("创建目录 %s 失败: %w", p, err)
 		}
 	}
-	// chown -R 让 ServiceUser 能读写 nodes.json + autocert 缓存。
-	for _, p := range []string{"/var/lib/proxy-manager", "/etc/proxy-manager"} {
-		if out, err := exec.Command("chown", "-R", ServiceUser+":"+ServiceUser, p).CombinedOutput(); err != nil {
+	// chown -R 让 ServiceUser 能读写 autocert 缓存。
+	if out, err := exec.Command("chown", "-R", ServiceUser+":"+ServiceUser, "/var/lib/proxy-manager").CombinedOutput(); err != nil {
+		return fmt.Errorf("chown /var/lib/proxy-manager 失败: %v (%s)", err, strings.TrimSpace(string(out)))
+	}
+	// /etc/proxy-manager 不能 -R：master.key / 快照 / cloudflare.env 必须
+	// 留给 root。daemon 只需要目录本身 (tmp+rename 写 nodes.json)、nodes.json、
+	// 锁文件，以及按组读 subscribe.key。
+	for _, p := range []string{store.StoreDir, store.StorePath, store.LockPath} {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if out, err := exec.Command("chown", ServiceUser+":"+ServiceUser, p).CombinedOutput(); err != nil {
 			return fmt.Errorf("chown %s 失败: %v (%s)", p, err, strings.TrimSpace(string(out)))
 		}
 	}
+	if _, err := os.Stat(store.SubscribeKeyPath); err == nil {
+		_ = exec.Command("chown", "root:"+ServiceUser, store.SubscribeKeyPath).Run()
+	}
+	// 老版本 chown -R 过整个目录，这里把该归 root 的收回来
+	for _, p := range []string{store.MasterKeyPath, store.SnapshotDir} {
+		if _, err := os.Stat(p); err == nil {
+			_ = exec.Command("chown", "-R", "root:root", p).Run()
+		}
+	}
 	// CertCacheDir 由 autocert 自动建子目录但首次写需要权限。它已经在上面
 	// chown 链里 (它是 /var/lib/proxy-manager 的子目录)。
 	return nil
@@ -235,7 +255,18 @@ func Status() string {
 // Urls renders the four subscription URLs from the current subscribe config.
 // Empty map if subscribe is not configured.
 func Urls(s *store.Store) map[string]string {
-	if s.Subscribe.Token == "" || s.Subscribe.Domain == "" {
+	return UrlsFor(s, s.Subscribe.Token)
+}
+
+// URLNames is the order URL listings print in: auto first (the one to hand
+// out), then every registered format.
+func URLNames() []string {
+	return append([]string{AutoFormat}, format.Names()...)
+}
+
+// UrlsFor is Urls for an arbitrary token (e.g. a labelled AccessToken).
+func UrlsFor(s *store.Store, token string) map[string]string {
+	if token == "" || s.Subscribe.Domain == "" {
 		return nil
 	}
 	base := fmt.Sprintf("https://%s", s.Subscribe.Domain)
@@ -243,8 +274,8 @@ func Urls(s *store.Store) map[string]string {
 		base = fmt.Sprintf("https://%s:%d", s.Subscribe.Domain, s.Subscribe.Port)
 	}
 	out := map[string]string{}
-	for _, f := range []string{"surge", "clash", "mihomo", "singbox", "xray", "qx", "json"} {
-		out[f] = fmt.Sprintf("%s/s/%s/%s", base, f, s.Subscribe.Token)
+	for _, f := range URLNames() {
+		out[f] = fmt.Sprintf("%s/s/%s/%s", base, f, token)
 	}
 	return out
 }
@@ -0,0 +1,196 @@
+package subscribe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// 每个 token 最后一次被拉取的记录：什么时候、哪个格式、哪个 IP、什么 UA。
+// 回答 "rotate 之后客户端到底换没换 URL"。
+//
+// 存在 /var/lib/proxy-manager/sync.json 而不是 nodes.json：daemon 以
+// ServiceUser 跑，这个目录整个归它 (prepareRuntimeDirs)；nodes.json 每写
+// 一次还要留快照 / 审计，不该被客户端拉订阅驱动。
+
+// SyncStatePath is the per-token fetch history written by the daemon.
+const SyncStatePath = "/var/lib/proxy-manager/sync.json"
+
+// 主 token / 宽限期内的旧 token 在 sync.json 里的 key；labelled token 用 label。
+const (
+	SyncMainToken     = "(main)"
+	SyncPreviousToken = "(previous)"
+)
+
+// syncFlushInterval 限制同一 token 的落盘频率：格式 / IP / UA 都没变时
+// 一分钟内的重复拉取只更新内存。
+const syncFlushInterval = time.Minute
+
+// previousTokenWarnWindow: 旧 token 离失效不到这么久还有人在用就告警。
+const previousTokenWarnWindow = 48 * time.Hour
+
+// SyncRecord is the last fetch seen for one token.
+type SyncRecord struct {
+	At        time.Time `json:"at"`
+	Format    string    `json:"format"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// SyncState maps token keys (label / SyncMainToken / SyncPreviousToken) to
+// their last fetch.
+type SyncState map[string]SyncRecord
+
+// LoadSyncState reads sync.json; a missing file is an empty state.
+func LoadSyncState() (SyncState, error) {
+	return loadSyncState(SyncStatePath)
+}
+
+func loadSyncState(path string) (SyncState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return SyncState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := SyncState{}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return st, nil
+}
+
+// Keys returns the recorded token keys, sorted.
+func (st SyncState) Keys() []string {
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LastUsed returns the last fetch of labelled token t. 同名 token 被
+// revoke 后重建、或者 rotate 过，之前的记录属于旧 URL，不算。
+func (st SyncState) LastUsed(t store.AccessToken) (SyncRecord, bool) {
+	rec, ok := st[t.Label]
+	if !ok || rec.At.Before(t.CreatedAt) || rec.At.Before(t.RotatedAt) {
+		return SyncRecord{}, false
+	}
+	return rec, true
+}
+
+type syncLog struct {
+	mu      sync.Mutex
+	path    string
+	state   SyncState
+	flushed map[string]SyncRecord // 每个 key 最后落盘的版本
+}
+
+var syncs = &syncLog{path: SyncStatePath}
+
+// record notes a fetch for key, writing sync.json unless the same client
+// already fetched within syncFlushInterval.
+func (l *syncLog) record(key string, rec SyncRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.state == nil {
+		st, err := loadSyncState(l.path)
+		if err != nil {
+			st = SyncState{} // 坏文件直接覆盖，只是历史记录
+		}
+		l.state, l.flushed = st, make(map[string]SyncRecord, len(st))
+		for k, v := range st {
+			l.flushed[k] = v
+		}
+	}
+	l.state[key] = rec
+	prev, ok := l.flushed[key]
+	if ok && prev.Format == rec.Format && prev.IP == rec.IP && prev.UserAgent == rec.UserAgent &&
+		rec.At.Sub(prev.At) < syncFlushInterval {
+		return nil
+	}
+	data, err := json.MarshalIndent(l.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	for k, v := range l.state {
+		l.flushed[k] = v
+	}
+	return nil
+}
+
+// maxUALen 截断超长 User-Agent，sync.json 不该被一个怪客户端撑大。
+const maxUALen = 200
+
+func truncateUA(ua string) string {
+	if len(ua) > maxUALen {
+		return ua[:maxUALen]
+	}
+	return ua
+}
+
+// syncKey names the token that authenticated a request. lookupToken 对主
+// token 和旧 token 都返回 nil scope，这里再比一次区分开。
+func syncKey(cfg store.SubscribeConfig, supplied string, scope *store.AccessToken) string {
+	switch {
+	case scope != nil:
+		return scope.Label
+	case validToken(cfg.Token, supplied):
+		return SyncMainToken
+	default:
+		return SyncPreviousToken
+	}
+}
+
+// RotatedAt is when the main token was last rotated (zero if never).
+// 早于它的 (main) 记录其实是现在的旧 token 拉的，(previous) 记录则是更早
+// 一代的旧 token，都不该再算。
+func RotatedAt(cfg store.SubscribeConfig) time.Time {
+	if cfg.PreviousTokenExpiresAt.IsZero() {
+		return time.Time{}
+	}
+	return cfg.PreviousTokenExpiresAt.Add(-store.PreviousTokenGracePeriod)
+}
+
+// PreviousTokenWarning returns a warning when clients are still on the
+// pre-rotation token near the end of its grace period, or were right up to
+// it (then they are probably broken now; 过期后再提醒一个宽限期). Else "".
+func PreviousTokenWarning(cfg store.SubscribeConfig, st SyncState, now time.Time) string {
+	rec, ok := st[SyncPreviousToken]
+	if !ok || cfg.PreviousTokenExpiresAt.IsZero() || !rec.At.After(RotatedAt(cfg)) {
+		return ""
+	}
+	left := cfg.PreviousTokenExpiresAt.Sub(now)
+	client := rec.IP
+	if rec.UserAgent != "" {
+		client += " (" + rec.UserAgent + ")"
+	}
+	switch {
+	case left <= 0 && -left < store.PreviousTokenGracePeriod &&
+		!rec.At.Before(cfg.PreviousTokenExpiresAt.Add(-previousTokenWarnWindow)):
+		return fmt.Sprintf("旧 token 已于 %s 失效，失效前 %s 还在被 %s 使用，该客户端现在可能拉不到订阅",
+			cfg.PreviousTokenExpiresAt.Local().Format("2006-01-02 15:04"), rec.At.Local().Format("01-02 15:04"), client)
+	case left > 0 && left <= previousTokenWarnWindow:
+		return fmt.Sprintf("旧 token %s 后失效，但 %s 还在被 %s 使用 (%s)",
+			left.Round(time.Hour), rec.At.Local().Format("01-02 15:04"), client, rec.Format)
+	}
+	return ""
+}
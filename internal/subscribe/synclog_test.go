@@ -0,0 +1,105 @@
+package subscribe
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestSyncLogRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.json")
+	l := &syncLog{path: path}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := SyncRecord{At: now, Format: "surge-profile", IP: "203.0.113.7", UserAgent: "Surge iOS/2800"}
+
+	if err := l.record(SyncMainToken, rec); err != nil {
+		t.Fatal(err)
+	}
+	// 同一客户端 1 分钟内再拉：只更新内存
+	again := rec
+	again.At = now.Add(30 * time.Second)
+	if err := l.record(SyncMainToken, again); err != nil {
+		t.Fatal(err)
+	}
+	st, err := loadSyncState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st[SyncMainToken].At.Equal(now) {
+		t.Errorf("flushed within interval: %v", st[SyncMainToken].At)
+	}
+
+	// 换了格式 → 立即落盘，其他 key 一起带上
+	other := again
+	other.Format = "json"
+	if err := l.record("alice", other); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ = loadSyncState(path); !st[SyncMainToken].At.Equal(again.At) || st["alice"].Format != "json" {
+		t.Errorf("state = %+v", st)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("stat = %v, %v", fi, err)
+	}
+}
+
+func TestSyncKey(t *testing.T) {
+	cfg := store.SubscribeConfig{Token: "new-token", PreviousToken: "old-token"}
+	if got := syncKey(cfg, "new-token", nil); got != SyncMainToken {
+		t.Errorf("main = %q", got)
+	}
+	if got := syncKey(cfg, "old-token", nil); got != SyncPreviousToken {
+		t.Errorf("previous = %q", got)
+	}
+	if got := syncKey(cfg, "x", &store.AccessToken{Label: "bob"}); got != "bob" {
+		t.Errorf("labelled = %q", got)
+	}
+}
+
+func TestSyncStateLastUsed(t *testing.T) {
+	created := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	st := SyncState{"bob": {At: created.Add(time.Hour), Format: "clash"}}
+
+	if rec, ok := st.LastUsed(store.AccessToken{Label: "bob", CreatedAt: created}); !ok || rec.Format != "clash" {
+		t.Errorf("LastUsed = %+v, %v", rec, ok)
+	}
+	if _, ok := st.LastUsed(store.AccessToken{Label: "bob", CreatedAt: created, RotatedAt: created.Add(2 * time.Hour)}); ok {
+		t.Error("fetch before rotate counted")
+	}
+	if _, ok := st.LastUsed(store.AccessToken{Label: "bob", CreatedAt: created.Add(2 * time.Hour)}); ok {
+		t.Error("fetch of a revoked token with the same label counted")
+	}
+	if _, ok := st.LastUsed(store.AccessToken{Label: "carol", CreatedAt: created}); ok {
+		t.Error("never fetched token has a record")
+	}
+}
+
+func TestPreviousTokenWarning(t *testing.T) {
+	expires := time.Date(2026, 5, 8, 0, 0, 0, 0, time.UTC)
+	cfg := store.SubscribeConfig{PreviousTokenExpiresAt: expires}
+	rotated := RotatedAt(cfg)
+	cases := []struct {
+		name   string
+		usedAt time.Time
+		now    time.Time
+		want   string // 子串，"" = 不告警
+	}{
+		{"early in grace", rotated.Add(time.Hour), rotated.Add(2 * time.Hour), ""},
+		{"near expiry", expires.Add(-30 * time.Hour), expires.Add(-24 * time.Hour), "后失效"},
+		{"used before rotation", rotated.Add(-time.Hour), expires.Add(-24 * time.Hour), ""},
+		{"used up to expiry", expires.Add(-time.Hour), expires.Add(time.Hour), "可能拉不到订阅"},
+		{"stopped early", rotated.Add(time.Hour), expires.Add(time.Hour), ""},
+		{"long expired", expires.Add(-time.Hour), expires.Add(30 * 24 * time.Hour), ""},
+	}
+	for _, c := range cases {
+		st := SyncState{SyncPreviousToken: {At: c.usedAt, Format: "surge", IP: "203.0.113.7"}}
+		got := PreviousTokenWarning(cfg, st, c.now)
+		if (c.want == "") != (got == "") || !strings.Contains(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package subscribe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// 上游 (store.Upstream) 节点的拉取与缓存。
+//
+// 每个上游单独缓存 UpstreamCacheTTL；过期后由下一个订阅请求同步刷新。刷新
+// 失败时继续用上一次成功的结果 (stale-while-error)，最多 UpstreamStaleLimit，
+// 再久就当它没有节点——宁可少几个节点也不要下发早已轮换掉的凭据。
+//
+// 拉取时带 ?local=1：对方只返回它自己的节点，不再展开它的上游，两台机器
+// 互为上游也不会循环。
+
+const (
+	UpstreamCacheTTL   = 5 * time.Minute
+	UpstreamTimeout    = 5 * time.Second
+	UpstreamStaleLimit = 24 * time.Hour
+	// upstreamMaxBody 限制单个上游响应体，防止对方异常时把内存吃满。
+	upstreamMaxBody = 4 << 20
+)
+
+// upstreamClient is swapped out by tests.
+var upstreamClient = &http.Client{Timeout: UpstreamTimeout}
+
+type upstreamEntry struct {
+	mu        sync.Mutex
+	nodes     []store.Node
+	fetchedAt time.Time // 最近一次成功
+	checkedAt time.Time // 最近一次尝试 (成功或失败)
+	err       error
+}
+
+type upstreamCache struct {
+	mu      sync.Mutex
+	entries map[string]*upstreamEntry // key = URL
+}
+
+var upstreams = &upstreamCache{entries: map[string]*upstreamEntry{}}
+
+func (c *upstreamCache) entry(rawURL string) *upstreamEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[rawURL]
+	if !ok {
+		e = &upstreamEntry{}
+		c.entries[rawURL] = e
+	}
+	return e
+}
+
+// nodes returns every upstream's nodes, fetching the expired ones in
+// parallel. Upstreams are independent: one being down never blocks or
+// empties the others.
+func (c *upstreamCache) nodes(ups []store.Upstream, now time.Time) []store.Node {
+	results := make([][]store.Node, len(ups))
+	var wg sync.WaitGroup
+	for i, up := range ups {
+		wg.Add(1)
+		go func(i int, up store.Upstream) {
+			defer wg.Done()
+			results[i] = c.get(up, now)
+		}(i, up)
+	}
+	wg.Wait()
+	var out []store.Node
+	for _, r := range results {
+		out = append(out, r...)
+	}
+	return out
+}
+
+func (c *upstreamCache) get(up store.Upstream, now time.Time) []store.Node {
+	e := c.entry(up.URL)
+	// 同一个上游同一时间只拉一次，其他请求等它的结果
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now.Sub(e.checkedAt) >= UpstreamCacheTTL {
+		e.checkedAt = now
+		nodes, err := fetchUpstream(up.URL)
+		if err != nil {
+			e.err = err
+			log.Printf("upstream %s: %v (using cache from %s)", up.Label, err, e.fetchedAt.Format(time.RFC3339))
+		} else {
+			e.nodes, e.fetchedAt, e.err = nodes, now, nil
+		}
+	}
+	if now.Sub(e.fetchedAt) > UpstreamStaleLimit {
+		return nil
+	}
+	return e.nodes
+}
+
+// fetchUpstream GETs the peer's json subscription (local nodes only).
+func fetchUpstream(rawURL string) ([]store.Node, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("local", "1")
+	u.RawQuery = q.Encode()
+
+	resp, err := upstreamClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var s store.Store
+	if err := json.NewDecoder(io.LimitReader(resp.Body, upstreamMaxBody)).Decode(&s); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return s.Nodes, nil
+}
+
+// CheckUpstream fetches one upstream uncached, for `subscribe upstream list`.
+func CheckUpstream(up store.Upstream) ([]store.Node, error) {
+	return fetchUpstream(up.URL)
+}
+
+// mergeNodes appends remote nodes whose ID isn't already present. 本机节点
+// 优先：本机总是最新的，上游的可能是缓存。
+func mergeNodes(local, remote []store.Node) []store.Node {
+	seen := make(map[string]bool, len(local)+len(remote))
+	out := make([]store.Node, 0, len(local)+len(remote))
+	for _, n := range local {
+		seen[n.ID] = true
+		out = append(out, n)
+	}
+	for _, n := range remote {
+		if seen[n.ID] {
+			continue
+		}
+		seen[n.ID] = true
+		out = append(out, n)
+	}
+	return out
+}
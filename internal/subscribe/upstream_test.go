@@ -0,0 +1,93 @@
+package subscribe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/format"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestUpstreamMergeCacheAndStale(t *testing.T) {
+	var hits atomic.Int32
+	var down atomic.Bool
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Query().Get("local") != "1" {
+			t.Errorf("upstream fetched without local=1: %s", r.URL)
+		}
+		if down.Load() {
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(store.Store{Nodes: []store.Node{
+			{ID: "hysteria2-5.6.7.8", Type: store.TypeHysteria2},
+			{ID: "shared-id", Name: "remote"},
+		}})
+	}))
+	defer peer.Close()
+
+	c := &upstreamCache{entries: map[string]*upstreamEntry{}}
+	ups := []store.Upstream{{Label: "peer", URL: peer.URL + "/s/json/tok"}}
+	now := time.Unix(1000000, 0)
+
+	local := []store.Node{{ID: "shared-id", Name: "local"}}
+	merged := mergeNodes(local, c.nodes(ups, now))
+	if len(merged) != 2 || merged[0].Name != "local" || merged[1].ID != "hysteria2-5.6.7.8" {
+		t.Fatalf("merge = %+v", merged)
+	}
+
+	// TTL 内走缓存
+	c.nodes(ups, now.Add(UpstreamCacheTTL/2))
+	if hits.Load() != 1 {
+		t.Fatalf("expected cached result, upstream hit %d times", hits.Load())
+	}
+
+	// 过期 + 上游挂了 → 继续用旧结果
+	down.Store(true)
+	if got := c.nodes(ups, now.Add(UpstreamCacheTTL)); len(got) != 2 {
+		t.Fatalf("stale-while-error should keep serving cached nodes, got %d", len(got))
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expired entry should be refetched, hits = %d", hits.Load())
+	}
+
+	// 超过 stale 上限就不再下发
+	if got := c.nodes(ups, now.Add(UpstreamStaleLimit+time.Minute)); len(got) != 0 {
+		t.Fatalf("nodes older than the stale limit must be dropped, got %d", len(got))
+	}
+}
+
+// 上游给的是 json 格式 (ClientCopy)，合并进来的节点照样能按 tag 筛。
+func TestUpstreamNodesKeepTags(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _ := format.Lookup("json")
+		_ = f.Render(w, []store.Node{
+			{ID: "hysteria2-5.6.7.8", Type: store.TypeHysteria2, Tags: []string{"region=hk"}, Note: "peer note"},
+			{ID: "anytls-5.6.7.8", Type: store.TypeAnyTLS, Tags: []string{"region=jp"}},
+		}, format.Options{})
+	}))
+	defer peer.Close()
+
+	c := &upstreamCache{entries: map[string]*upstreamEntry{}}
+	ups := []store.Upstream{{Label: "peer", URL: peer.URL + "/s/json/tok"}}
+	merged := mergeNodes(nil, c.nodes(ups, time.Unix(1000000, 0)))
+	if len(merged) != 2 {
+		t.Fatalf("merge = %+v", merged)
+	}
+	if merged[0].Note != "" {
+		t.Errorf("upstream json leaked note %q", merged[0].Note)
+	}
+
+	if got := store.Published(merged, []string{"region=hk"}); len(got) != 1 || got[0].ID != "hysteria2-5.6.7.8" {
+		t.Errorf("?tag=region=hk = %+v", got)
+	}
+	tok := store.AccessToken{Label: "jp", Tags: []string{"region=jp"}}
+	if got := tok.Scope(merged); len(got) != 1 || got[0].ID != "anytls-5.6.7.8" {
+		t.Errorf("token --tags region=jp = %+v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package subscribe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/services"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+// Clash / Stash / Shadowrocket / Surge 都认的几个订阅响应头：
+//
+//	Subscription-Userinfo: upload=0; download=123; total=456; expire=1767139200
+//	Profile-Update-Interval: 24
+//	Content-Disposition: attachment; filename*=UTF-8''sub.example.com
+//
+// 每个格式都带 (客户端不认的头会忽略)。这些值不进渲染缓存：流量一直在变，
+// body 没变的时候客户端照样拿 304。
+
+// trafficTTL 限制 systemctl show 的调用频率。
+const trafficTTL = time.Minute
+
+// unitTraffic 读一个服务的收发字节数；测试里替换掉。
+var unitTraffic = func(svc services.Service) (ingress, egress int64, ok bool) {
+	return svc.Traffic()
+}
+
+type trafficCache struct {
+	mu     sync.Mutex
+	at     time.Time
+	byType map[store.NodeType]int64
+}
+
+var traffic trafficCache
+
+// used returns the bytes moved by the units behind nodes since they last
+// started. 代理一进一出，入站 ≈ 出站 ≈ 客户端上下行之和，分不出方向，所以
+// 取两者均值当作已用流量。没开 IPAccounting 的 unit 记 0。
+func (c *trafficCache) used(nodes []store.Node, now time.Time) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byType == nil || now.Sub(c.at) > trafficTTL {
+		c.byType = make(map[store.NodeType]int64)
+		for _, svc := range services.Services {
+			if in, out, ok := unitTraffic(svc); ok {
+				c.byType[svc.NodeType] = (in + out) / 2
+			}
+		}
+		c.at = now
+	}
+	var total int64
+	seen := map[store.NodeType]bool{}
+	for _, n := range nodes {
+		if n.External || seen[n.Type] {
+			continue
+		}
+		seen[n.Type] = true // 同一类型的节点共用一个 unit
+		total += c.byType[n.Type]
+	}
+	return total
+}
+
+// userinfo builds the Subscription-Userinfo value. upload 固定为 0，已用流量
+// 全记在 download 上 (见 trafficCache.used)；used < 0 = 不知道，两个都不写。
+// total 0 = 不限。
+func userinfo(used, total int64, expire time.Time) string {
+	v := fmt.Sprintf("total=%d", total)
+	if used >= 0 {
+		v = fmt.Sprintf("upload=0; download=%d; ", used) + v
+	}
+	if !expire.IsZero() {
+		v += "; expire=" + strconv.FormatInt(expire.Unix(), 10)
+	}
+	return v
+}
+
+// setProfileHeaders adds the userinfo / update-interval / filename headers
+// for the token that authenticated the request. s is the unfiltered store.
+//
+// IPAccounting 是整个 unit 的计数，同一节点上所有用户混在一起，分不到人：
+// labelled token 只给额度和到期日，不报已用流量，免得朋友看到的"已用"
+// 其实是别人跑的。
+func setProfileHeaders(h http.Header, r *http.Request, s *store.Store, scope *store.AccessToken, now time.Time) {
+	total, expire := s.Subscribe.QuotaBytes, s.Subscribe.PlanExpiresAt
+	used := int64(-1)
+	if scope != nil {
+		total, expire = scope.QuotaBytes, scope.ExpiresAt
+	} else {
+		used = traffic.used(store.Published(s.Nodes, nil), now)
+	}
+	h.Set("Subscription-Userinfo", userinfo(used, total, expire))
+	h.Set("Profile-Update-Interval", strconv.Itoa(s.Subscribe.UpdateInterval()))
+	h.Set("Content-Disposition", contentDisposition(profileName(r, s.Subscribe, scope)))
+}
+
+// profileName 是客户端导入后显示的配置名：订阅域名，labelled token 再加上
+// label，方便一台设备导入多份时区分。
+func profileName(r *http.Request, cfg store.SubscribeConfig, scope *store.AccessToken) string {
+	name := cfg.Domain
+	if name == "" {
+		name = r.Host
+		if host, _, err := net.SplitHostPort(name); err == nil {
+			name = host
+		}
+	}
+	if name == "" {
+		name = "proxy-manager"
+	}
+	if scope != nil {
+		name += "-" + scope.Label
+	}
+	return name
+}
+
+// contentDisposition gives both the ASCII filename= (老客户端) and the RFC
+// 5987 filename*= (label 里可能有中文)。
+func contentDisposition(name string) string {
+	ascii := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, ascii, url.PathEscape(name))
+}
@@ -0,0 +1,61 @@
+package subscribe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mamaaz/proxy-manager/internal/services"
+	"github.com/Mamaaz/proxy-manager/internal/store"
+)
+
+func TestSetProfileHeaders(t *testing.T) {
+	orig := unitTraffic
+	defer func() { unitTraffic, traffic = orig, trafficCache{} }()
+	unitTraffic = func(svc services.Service) (int64, int64, bool) {
+		switch svc.NodeType {
+		case store.TypeHysteria2:
+			return 100, 300, true
+		case store.TypeVLESSReality:
+			return 1000, 1000, true
+		}
+		return 0, 0, false
+	}
+	traffic = trafficCache{}
+
+	expire := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	s := &store.Store{
+		Subscribe: store.SubscribeConfig{Domain: "sub.example.com", QuotaBytes: 1 << 30, PlanExpiresAt: expire},
+		Nodes: []store.Node{
+			{ID: "hysteria2-1.2.3.4", Type: store.TypeHysteria2},
+			{ID: "vless-reality-1.2.3.4", Type: store.TypeVLESSReality, Tags: []string{"region=hk"}},
+			{ID: "ext-hysteria2-jp.example.com-443", Type: store.TypeHysteria2, External: true},
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/s/auto/tok", nil)
+	now := time.Now()
+
+	h := http.Header{}
+	setProfileHeaders(h, r, s, nil, now)
+	if got, want := h.Get("Subscription-Userinfo"), "upload=0; download=1200; total=1073741824; expire=1798675200"; got != want {
+		t.Errorf("main userinfo = %q, want %q", got, want)
+	}
+	if got := h.Get("Profile-Update-Interval"); got != "24" {
+		t.Errorf("update interval = %q", got)
+	}
+	if got, want := h.Get("Content-Disposition"), `attachment; filename="sub.example.com"; filename*=UTF-8''sub.example.com`; got != want {
+		t.Errorf("disposition = %q", got)
+	}
+
+	scope := &store.AccessToken{Label: "朋友", Tags: []string{"hysteria2"}, QuotaBytes: 500}
+	h = http.Header{}
+	setProfileHeaders(h, r, s, scope, now)
+	// unit 的流量是所有用户混在一起的，labelled token 不报 download
+	if got, want := h.Get("Subscription-Userinfo"), "total=500"; got != want {
+		t.Errorf("token userinfo = %q, want %q", got, want)
+	}
+	if got, want := h.Get("Content-Disposition"), `attachment; filename="sub.example.com-__"; filename*=UTF-8''sub.example.com-%E6%9C%8B%E5%8F%8B`; got != want {
+		t.Errorf("disposition = %q", got)
+	}
+}
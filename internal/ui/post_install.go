@@ -13,30 +13,23 @@ import (
 
 // printNodeShareURL 给一个新装的节点打分享 URL + QR。客户端扫码即导入
 // 单个节点，不依赖订阅服务（测试用、或 subscribe 还没启用时）。
-//
-// 当前只支持 VLESS Reality (`vless://...`)。其他协议的标准 share URL 格式
-// 各异 (Hy2 是 `hy2://`、AnyTLS 没标准) — 待真有需求再加。
+// vless:// / hysteria2:// / anytls:// 都由 format.ShareURL 生成。
 func printNodeShareURL(nodeType store.NodeType) {
 	s, err := store.LoadOrMigrate()
 	if err != nil {
 		return
 	}
-	var node *store.Node
-	for i := range s.Nodes {
-		if s.Nodes[i].Type == nodeType {
-			node = &s.Nodes[i]
-			break
-		}
-	}
-	if node == nil {
+	node, ok := s.LocalNode(nodeType)
+	if !ok {
 		return
 	}
-	if nodeType != store.TypeVLESSReality {
-		return // 其他协议暂未实现 share URL
+	share, err := format.ShareURL(node)
+	if err != nil {
+		utils.PrintWarn("节点参数不完整，无法生成分享链接: %v", err)
+		return
 	}
-	share := format.VlessRealityShareURL(node)
 	fmt.Println()
-	fmt.Printf("%s单节点分享链接%s（扫码导入到任意 Reality 客户端，不需要订阅服务）:\n",
+	fmt.Printf("%s单节点分享链接%s（扫码导入 v2rayN / NekoBox / Shadowrocket 等，不需要订阅服务）:\n",
 		utils.ColorCyan, utils.ColorReset)
 	fmt.Printf("  %s%s%s\n", utils.ColorGreen, share, utils.ColorReset)
 	fmt.Println()
@@ -68,13 +61,13 @@ func printSubscribeURLs() {
 	fmt.Println()
 	fmt.Printf("%s订阅 URL%s（客户端添加这些 URL 即可自动同步配置）:\n",
 		utils.ColorCyan, utils.ColorReset)
-	for _, k := range []string{"surge", "clash", "mihomo", "singbox", "xray", "qx", "json"} {
+	for _, k := range subscribe.URLNames() {
 		if v, ok := urls[k]; ok {
-			fmt.Printf("  %-8s %s\n", k+":", v)
+			fmt.Printf("  %-16s %s\n", k+":", v)
 		}
 	}
 	fmt.Println()
-	fmt.Printf("%sXSurge (Mac 状态栏 app)%s 直接复制上面 json URL 添加订阅即可。\n",
+	fmt.Printf("%s一般只需要 auto URL%s：按客户端 User-Agent 自动给 Surge / Clash / sing-box / XSurge ... 对应的格式。\n",
 		utils.ColorGreen, utils.ColorReset)
 	// 不再打 json 订阅 URL 的 QR — XSurge 用复制粘贴 URL 字符串，QR 没用；
 	// 上面 printNodeShareURL 给的 vless:// 单节点 share 才是手机扫码场景。
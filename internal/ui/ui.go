@@ -265,6 +265,7 @@ func doInstallAnyTLS() {
 	if err != nil {
 		utils.PrintError("安装失败: %v", err)
 	} else {
+		printNodeShareURL(store.TypeAnyTLS)
 		printSubscribeURLs()
 	}
 	waitForEnter()
@@ -275,6 +276,7 @@ func doInstallAnyTLSReality() {
 	if err != nil {
 		utils.PrintError("安装失败: %v", err)
 	} else {
+		printNodeShareURL(store.TypeAnyTLSReality)
 		printSubscribeURLs()
 	}
 	waitForEnter()